@@ -10,6 +10,7 @@ import (
 func main() {
 	self := bootstrap.Load(&config.AppConfig{
 		LoggerName: "api",
+		UseDb:      true,
 	})
 	self.Services = container.LoadApiServices(self.Conf)
 	self.WatchForReload()
@@ -55,7 +55,7 @@ func writeFile(file *os.File, lines uint, batchSize uint) {
 
 	color.Yellow("Generating %d lines...\n", lines)
 	for i := 1; i <= total; i++ {
-		phone := "07" + strconv.Itoa(rand.Intn(100000000))
+		phone := fmt.Sprintf("07%08d", rand.Intn(100000000)) // Valid french mobile number
 		first := "Customer " + strconv.Itoa(i)
 		last := "Doe " + strconv.Itoa(i)
 
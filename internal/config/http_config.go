@@ -1,14 +1,34 @@
 package config
 
-import "time"
+import (
+	"runtime"
+	"strings"
+	"time"
+)
 
 type HttpConfig struct {
-	Host             string        // Hostname or IP address (default: "http://localhost")
-	Port             string        // Log level (default: ":8080")
-	MaxContentLength int64         // Max request size for a request in byte (default: "10485760" -> 10 Mo)
-	FileChunkLimit   uint          // Split uploaded file after reached number of rows limit (default: "6000")
-	BatchInsert      uint          // Number of contact rows inserted by query (default: "3000")
-	FileTimeout      time.Duration // Lifetime in seconds for file processing (default: 30)
+	Host              string        // Hostname or IP address (default: "http://localhost")
+	Port              string        // Log level (default: ":8080")
+	MaxContentLength  int64         // Max request size for a request in byte (default: "10485760" -> 10 Mo)
+	FileChunkLimit    uint          // Split uploaded file after reached number of rows limit (default: "6000")
+	BatchInsert       uint          // Number of contact rows inserted by query when an import starts (default: "3000")
+	BatchInsertMin    uint          // Min number of contact rows inserted by query, as adjusted to the insert latency (default: BatchInsert / 4)
+	BatchInsertMax    uint          // Max number of contact rows inserted by query, as adjusted to the insert latency (default: BatchInsert * 4)
+	BatchLatency      time.Duration // Target duration of the query of a batch, larger batches are inserted while under it (default: "500" ms)
+	FileTimeout       time.Duration // Lifetime in seconds for file processing (default: 30)
+	PhoneCountry      string        // Default ISO 3166-1 alpha-2 country of national phone numbers (default: "FR")
+	PhoneValidation   string        // Phone numbers which cannot be normalized are rejected with "strict" or kept with "lenient" (default: "strict")
+	LegacyReplace     bool          // Uploads without phonebook replace the default phonebook, as imports used to replace every contact (default: false)
+	ArchiveMaxRatio   uint          // Max ratio between uncompressed and compressed sizes of uploaded archives (default: "100")
+	ArchiveMaxEntries uint          // Max number of files within an uploaded zip archive (default: "100")
+	ArchiveMaxSize    uint64        // Max uncompressed size in bytes of the contents of an uploaded archive (default: "1073741824")
+	WorkerImports     uint          // Number of imports processed at once by a worker (default: "2")
+	WorkerParsers     uint          // Number of parse and validate goroutines run at once by a worker, shared by the FileParts of its imports (default: CPU cores, minus 2 above 4 cores)
+	WorkerWriters     uint          // Number of batches written to the database at once by a worker (default: WorkerParsers)
+	StageParsers      uint          // Number of goroutines mapping the records of each FilePart to contacts (default: "1")
+	StageValidators   uint          // Number of goroutines normalizing and validating the contacts of each FilePart (default: "2")
+	StageQueue        uint          // Number of rows waiting before each stage of the pipeline of a FilePart (default: "1000")
+	Loader            string        // Writes contacts of imports with GORM "insert" statements, "prepared" statements, or "load-data" for the insert mode only (default: "insert")
 }
 
 func (c *HttpConfig) Load() {
@@ -20,7 +40,23 @@ func (c *HttpConfig) Load() {
 	c.MaxContentLength = int64(GetUint("HTTP_MAX_CONTENT_LENGTH", 10<<20))
 	c.FileChunkLimit = uint(GetUint("FILE_CHUNK_LIMIT", 6000))
 	c.BatchInsert = uint(GetUint("BATCH_INSERT", 3000))
+	c.BatchInsertMin = uint(GetUint("BATCH_INSERT_MIN", uint64(max(c.BatchInsert/4, 1))))
+	c.BatchInsertMax = uint(GetUint("BATCH_INSERT_MAX", uint64(c.BatchInsert)*4))
+	c.BatchLatency = time.Duration(GetUint("BATCH_LATENCY_TARGET", 500)) * time.Millisecond
 	c.FileTimeout = time.Duration(GetUint("FILE_UPLOAD_TIMEOUT", 30)) * time.Second
+	c.PhoneCountry = strings.ToUpper(Get("PHONE_DEFAULT_COUNTRY", "FR"))
+	c.PhoneValidation = strings.ToLower(Get("PHONE_VALIDATION", "strict"))
+	c.LegacyReplace = GetBool("IMPORT_LEGACY_REPLACE", false)
+	c.ArchiveMaxRatio = uint(GetUint("ARCHIVE_MAX_RATIO", 100))
+	c.ArchiveMaxEntries = uint(GetUint("ARCHIVE_MAX_ENTRIES", 100))
+	c.ArchiveMaxSize = GetUint("ARCHIVE_MAX_SIZE", 1<<30)
+	c.Loader = strings.ToLower(Get("IMPORT_LOADER", "insert"))
+	c.WorkerImports = uint(GetUint("WORKER_IMPORTS", 2))
+	c.WorkerParsers = uint(GetUint("WORKER_PARSERS", defaultParsers()))
+	c.WorkerWriters = uint(GetUint("WORKER_DB_WRITERS", uint64(c.WorkerParsers)))
+	c.StageParsers = uint(GetUint("STAGE_PARSE_WORKERS", 1))
+	c.StageValidators = uint(GetUint("STAGE_VALIDATE_WORKERS", 2))
+	c.StageQueue = uint(GetUint("STAGE_QUEUE_SIZE", 1000))
 
 	c.validate()
 }
@@ -41,8 +77,47 @@ func (c *HttpConfig) validate() {
 	if c.BatchInsert == 0 {
 		panicInvalidConfig("ENV var BATCH_INSERT must be greater than zero")
 	}
+	if c.BatchInsertMin == 0 || c.BatchInsertMin > c.BatchInsert || c.BatchInsert > c.BatchInsertMax {
+		panicInvalidConfig("ENV vars BATCH_INSERT_MIN, BATCH_INSERT and BATCH_INSERT_MAX must be ordered and greater than zero")
+	}
+	if c.BatchLatency <= 0 {
+		panicInvalidConfig("ENV var BATCH_LATENCY_TARGET must be greater than zero")
+	}
 	if c.FileTimeout <= 0 {
 		panicInvalidConfig("ENV var FILE_UPLOAD_TIMEOUT must be greater than zero")
 	}
+	if c.ArchiveMaxRatio == 0 {
+		panicInvalidConfig("ENV var ARCHIVE_MAX_RATIO must be greater than zero")
+	}
+	if c.ArchiveMaxEntries == 0 {
+		panicInvalidConfig("ENV var ARCHIVE_MAX_ENTRIES must be greater than zero")
+	}
+	if c.ArchiveMaxSize == 0 {
+		panicInvalidConfig("ENV var ARCHIVE_MAX_SIZE must be greater than zero")
+	}
+	if len(c.PhoneCountry) != 2 {
+		panicInvalidConfig("ENV var PHONE_DEFAULT_COUNTRY must be an ISO 3166-1 alpha-2 country code")
+	}
+	if c.PhoneValidation != "strict" && c.PhoneValidation != "lenient" {
+		panicInvalidConfig("ENV var PHONE_VALIDATION must be strict or lenient")
+	}
+	if c.WorkerImports == 0 || c.WorkerParsers == 0 || c.WorkerWriters == 0 {
+		panicInvalidConfig("ENV vars WORKER_IMPORTS, WORKER_PARSERS and WORKER_DB_WRITERS must be greater than zero")
+	}
+	if c.StageParsers == 0 || c.StageValidators == 0 || c.StageQueue == 0 {
+		panicInvalidConfig("ENV vars STAGE_PARSE_WORKERS, STAGE_VALIDATE_WORKERS and STAGE_QUEUE_SIZE must be greater than zero")
+	}
+	if c.Loader != "insert" && c.Loader != "prepared" && c.Loader != "load-data" {
+		panicInvalidConfig("ENV var IMPORT_LOADER must be insert, prepared or load-data")
+	}
 
 }
+
+// defaultParsers returns the number of CPU cores, leaving 2 cores to the database writes and the API above 4 cores.
+func defaultParsers() uint64 {
+	cpu := runtime.NumCPU()
+	if cpu > 4 {
+		cpu -= 2
+	}
+	return uint64(cpu)
+}
@@ -4,11 +4,17 @@ import (
 	"go-csv-import/internal/config"
 	"go-csv-import/internal/handlers/worker"
 	"go-csv-import/internal/logger"
+	"go-csv-import/internal/repository"
 	"go-csv-import/internal/service/phonebook"
 )
 
 type Services struct {
 	PhonebookUploader *phonebook.PhonebookHandler
+	Contacts          *repository.ContactRepository         // Reads imported contacts, for the API only
+	Layouts           *repository.LayoutRepository          // Stores layouts of fixed-width files, for the API only
+	Fields            *repository.FieldDefinitionRepository // Stores custom fields of phonebooks, for the API only
+	Templates         *repository.TemplateRepository        // Stores versioned import templates, for the API only
+	Imports           *repository.ImportRepository          // Reads imports and the step of their staged contacts, for the API only
 }
 
 // LoadServices initializes and returns the services for the application.
@@ -25,6 +31,11 @@ func LoadConsumerServices(a *config.AppConfig, p *worker.MessageProgressStore) *
 func LoadApiServices(a *config.AppConfig) *Services {
 	s := &Services{
 		PhonebookUploader: phonebook.NewPhonebookPublisher(&a.Amqp, &a.Http),
+		Contacts:          repository.NewContactRepository(),
+		Layouts:           repository.NewLayoutRepository(),
+		Fields:            repository.NewFieldDefinitionRepository(),
+		Templates:         repository.NewTemplateRepository(),
+		Imports:           repository.NewImportRepository(),
 	}
 
 	logger.Trace("API Services Loaded")
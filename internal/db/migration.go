@@ -0,0 +1,112 @@
+package db
+
+import (
+	"fmt"
+	"go-csv-import/internal/logger"
+	"go-csv-import/internal/model"
+)
+
+// onlineColumn is a column added to an existing table without rebuilding it.
+type onlineColumn struct {
+	Model      any
+	Name       string
+	Definition string // Type of the column, as AutoMigrate would create it so that it is not altered afterwards
+}
+
+/*
+onlineColumns lists the columns added to the contacts table after it has been filled.
+
+AutoMigrate would add them with the default algorithm, which may copy the whole table:
+they are added beforehand with ALGORITHM=INSTANT, which only changes the table metadata,
+or with ALGORITHM=INPLACE, LOCK=NONE on servers which cannot add them instantly, so that imports are never blocked.
+Columns are nullable without default and without index, as required by INSTANT.
+*/
+var onlineColumns = []onlineColumn{
+	{Model: &model.Contact{}, Name: "phonebook", Definition: "varchar(100)"},
+	{Model: &model.Contact{}, Name: "phone_e164", Definition: "varchar(16)"},
+	{Model: &model.Contact{}, Name: "natural_key", Definition: "varchar(120)"},
+	{Model: &model.Contact{}, Name: "email", Definition: "varchar(254)"},
+	{Model: &model.Contact{}, Name: "company", Definition: "varchar(255)"},
+	{Model: &model.Contact{}, Name: "title", Definition: "varchar(255)"},
+	{Model: &model.Contact{}, Name: "attributes", Definition: "json"},
+}
+
+// addOnlineColumns adds the missing onlineColumns of existing tables. New tables are left to AutoMigrate.
+func addOnlineColumns() error {
+	migrator := DB.Migrator()
+	for _, column := range onlineColumns {
+		if !migrator.HasTable(column.Model) || migrator.HasColumn(column.Model, column.Name) {
+			continue
+		}
+
+		stmt := DB.Model(column.Model).Statement
+		if err := stmt.Parse(column.Model); err != nil {
+			return err
+		}
+		table := stmt.Schema.Table
+
+		alter := fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s NULL", table, column.Name, column.Definition)
+		logger.Info("Adding column", "table", table, "column", column.Name)
+		if err := DB.Exec(alter + ", ALGORITHM=INSTANT").Error; err != nil {
+			logger.Warn("Column cannot be added instantly, adding it in place", "table", table, "column", column.Name, "error", err)
+			if err := DB.Exec(alter + ", ALGORITHM=INPLACE, LOCK=NONE").Error; err != nil {
+				return fmt.Errorf("cannot add column %s.%s: %w", table, column.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// onlineIndex is an index added to an existing table without blocking its writes.
+type onlineIndex struct {
+	Model      any
+	Name       string
+	Definition string // Index as AutoMigrate would create it, so that it is not created again afterwards
+}
+
+/*
+onlineIndexes lists the indexes added to the contacts table after it has been filled.
+
+AutoMigrate would add them with the default lock, which may block the writes of imports while the index is built:
+they are added beforehand with ALGORITHM=INPLACE, LOCK=NONE, once their columns have been added by addOnlineColumns.
+*/
+var onlineIndexes = []onlineIndex{
+	{Model: &model.Contact{}, Name: "idx_phonebook", Definition: "INDEX `idx_phonebook` (`phonebook`)"},
+	{Model: &model.Contact{}, Name: "idx_natural_key", Definition: "UNIQUE INDEX `idx_natural_key` (`natural_key`)"},
+}
+
+// addOnlineIndexes adds the missing onlineIndexes of existing tables. New tables are left to AutoMigrate.
+func addOnlineIndexes() error {
+	migrator := DB.Migrator()
+	for _, index := range onlineIndexes {
+		if !migrator.HasTable(index.Model) || migrator.HasIndex(index.Model, index.Name) {
+			continue
+		}
+
+		stmt := DB.Model(index.Model).Statement
+		if err := stmt.Parse(index.Model); err != nil {
+			return err
+		}
+		table := stmt.Schema.Table
+
+		logger.Info("Adding index", "table", table, "index", index.Name)
+		alter := fmt.Sprintf("ALTER TABLE `%s` ADD %s, ALGORITHM=INPLACE, LOCK=NONE", table, index.Definition)
+		if err := DB.Exec(alter).Error; err != nil {
+			return fmt.Errorf("cannot add index %s.%s: %w", table, index.Name, err)
+		}
+	}
+	return nil
+}
+
+// releaseDeletedNaturalKeys clears the natural keys of the contacts soft deleted while deletes kept them,
+// so that these contacts can be imported again by upsert and skip-existing imports.
+func releaseDeletedNaturalKeys() error {
+	res := DB.Exec("UPDATE contacts SET natural_key = NULL WHERE natural_key IS NOT NULL AND deleted_at IS NOT NULL")
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		logger.Info("Natural keys of deleted contacts released", "contacts", res.RowsAffected)
+	}
+	return nil
+}
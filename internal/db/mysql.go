@@ -2,6 +2,7 @@ package db
 
 import (
 	"go-csv-import/internal/config"
+	"go-csv-import/internal/logger"
 	"go-csv-import/internal/model"
 	"time"
 
@@ -44,6 +45,14 @@ func Close() error {
 
 func AutoMigrate() {
 	if Connected {
-		DB.AutoMigrate(&model.Contact{})
+		if err := addOnlineColumns(); err != nil {
+			logger.Error("Failed to add columns", "error", err)
+		} else if err := addOnlineIndexes(); err != nil {
+			logger.Error("Failed to add indexes", "error", err)
+		}
+		DB.AutoMigrate(&model.Contact{}, &model.Layout{}, &model.FieldDefinition{}, &model.Template{}, &model.Import{}, &model.Checkpoint{})
+		if err := releaseDeletedNaturalKeys(); err != nil {
+			logger.Error("Failed to release natural keys of deleted contacts", "error", err)
+		}
 	}
 }
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"go-csv-import/internal/logger"
+	"go-csv-import/internal/model"
+	"go-csv-import/internal/repository"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Page sizes of contact searches.
+const (
+	searchDefaultLimit = 100
+	searchMaxLimit     = 1000
+)
+
+// contactResponse is a contact returned by the API.
+type contactResponse struct {
+	Id         uint           `json:"id,omitempty"`   // Empty for previewed contacts
+	Uuid       string         `json:"uuid,omitempty"` // Empty for previewed contacts
+	Phonebook  string         `json:"phonebook"`
+	Phone      string         `json:"phone"`
+	PhoneE164  string         `json:"phone_e164,omitempty"`
+	Firstname  string         `json:"firstname"`
+	Lastname   string         `json:"lastname"`
+	Email      string         `json:"email,omitempty"`
+	Company    string         `json:"company,omitempty"`
+	Title      string         `json:"title,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+func newContactResponse(c *model.Contact) contactResponse {
+	return contactResponse{
+		Id:         c.ID,
+		Uuid:       c.ReqId,
+		Phonebook:  c.Phonebook,
+		Phone:      c.Phone,
+		PhoneE164:  c.PhoneE164,
+		Firstname:  c.Firstname,
+		Lastname:   c.Lastname,
+		Email:      c.Email,
+		Company:    c.Company,
+		Title:      c.Title,
+		Attributes: c.Attributes,
+	}
+}
+
+/*
+SearchContacts returns a page of the contacts of an import or a phonebook,
+filtered by email, company and custom attributes: "attributes[Customer Id]=C-42".
+
+The next page is read with the "after" identifier returned with a full page.
+Contacts of an import staged for review are read with "staged=true", before they are committed.
+*/
+func SearchContacts(contacts *repository.ContactRepository, imports *repository.ImportRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Info("Call endpoint /contacts", "query", c.Request.URL.RawQuery)
+
+		query := repository.ContactQuery{
+			ReqId:      c.Query("uuid"),
+			Email:      c.Query("email"),
+			Company:    c.Query("company"),
+			Attributes: c.QueryMap("attributes"),
+			Limit:      searchDefaultLimit,
+		}
+		if name, ok := c.GetQuery("phonebook"); ok {
+			query.Phonebook = &name
+		}
+
+		if query.ReqId == "" && query.Phonebook == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "A uuid or a phonebook is required"})
+			return
+		}
+		if query.ReqId != "" {
+			if _, err := uuid.Parse(query.ReqId); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid uuid"})
+				return
+			}
+		}
+
+		if v := c.Query("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > searchMaxLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid limit <" + v + ">: expected 1 to " + strconv.Itoa(searchMaxLimit)})
+				return
+			}
+			query.Limit = limit
+		}
+		if v := c.Query("after"); v != "" {
+			after, err := strconv.ParseUint(v, 10, 0)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid after <" + v + ">: expected a contact id"})
+				return
+			}
+			query.AfterId = uint(after)
+		}
+
+		ctx := c.Request.Context()
+		if v := c.Query("staged"); v != "" {
+			staged, err := strconv.ParseBool(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid staged <" + v + ">: expected a boolean"})
+				return
+			}
+			if staged {
+				if query.ReqId == "" {
+					c.JSON(http.StatusBadRequest, gin.H{"message": "A uuid is required to read staged contacts"})
+					return
+				}
+				if status, err := checkStagingReview(c, imports, query.ReqId); err != nil {
+					c.JSON(status, gin.H{"message": err.Error()})
+					return
+				}
+				ctx = repository.WithTable(ctx, repository.StagingTable(query.ReqId))
+			}
+		}
+
+		found, err := contacts.Search(ctx, query)
+		if err != nil {
+			logger.Error("Error searching contacts", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot search contacts"})
+			return
+		}
+
+		page := make([]contactResponse, len(found))
+		for i := range found {
+			page[i] = newContactResponse(&found[i])
+		}
+
+		response := gin.H{"contacts": page}
+		if len(found) == query.Limit {
+			response["after"] = found[len(found)-1].ID
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
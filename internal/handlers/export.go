@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"bufio"
+	"go-csv-import/internal/config"
+	"go-csv-import/internal/logger"
+	"go-csv-import/internal/model"
+	"go-csv-import/internal/repository"
+	"go-csv-import/internal/service/phonebook"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+/*
+ExportVCard streams the contacts of an import as a vCard file, which phones and mail clients can import back.
+
+Contacts are read from the database by batches and written while they are read,
+so the headers are only sent once the first batch is found: an import without contacts gives a 404.
+*/
+func ExportVCard(contacts *repository.ContactRepository, h *config.HttpConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqId := c.Param("uuid")
+		logger.Info("Call endpoint /upload/vcf", "uuid", reqId)
+
+		if _, err := uuid.Parse(reqId); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid uuid"})
+			return
+		}
+
+		var w *bufio.Writer
+		count := 0
+		err := contacts.FindByReqIdInBatches(c.Request.Context(), reqId, int(h.BatchInsert), func(batch []*model.Contact) error {
+			if w == nil {
+				c.Header("Content-Type", "text/vcard; charset=utf-8")
+				c.Header("Content-Disposition", `attachment; filename="`+reqId+`.vcf"`)
+				c.Status(http.StatusOK)
+				w = bufio.NewWriter(c.Writer)
+			}
+
+			for _, contact := range batch {
+				if err := phonebook.WriteVCard(w, contact); err != nil {
+					return err
+				}
+			}
+			count += len(batch)
+			return w.Flush()
+		})
+
+		if err != nil {
+			logger.Error("Error exporting contacts", "uuid", reqId, "exported", count, "error", err)
+			if w == nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot export contacts"})
+			}
+			// Headers have been sent: the truncated file is all the client gets
+			return
+		}
+
+		if w == nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "No contacts"})
+			return
+		}
+		logger.Info("Contacts exported", "uuid", reqId, "exported", count)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"go-csv-import/internal/logger"
+	"go-csv-import/internal/repository"
+	"go-csv-import/internal/service/phonebook"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SaveField creates or replaces the custom field named in the path, for the phonebook of the query, from the JSON body.
+func SaveField(fields *repository.FieldDefinitionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, phonebookName := c.Param("name"), c.Query("phonebook")
+		logger.Info("Call endpoint PUT /fields", "name", name, "phonebook", phonebookName)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Cannot read field definition"})
+			return
+		}
+
+		field, err := phonebook.ParseFieldDefinition(phonebookName, name, body)
+		if err != nil {
+			logger.Error("Error parsing field definition", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if err := fields.Save(c.Request.Context(), field); err != nil {
+			logger.Error("Error saving field definition", "name", name, "phonebook", phonebookName, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot save field definition"})
+			return
+		}
+
+		logger.Info("Field definition saved", "name", name, "phonebook", phonebookName)
+		c.JSON(http.StatusOK, field)
+	}
+}
+
+// ListFields returns the custom fields of the phonebook of the query, ordered by name.
+func ListFields(fields *repository.FieldDefinitionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		phonebookName := c.Query("phonebook")
+		logger.Info("Call endpoint GET /fields", "phonebook", phonebookName)
+
+		list, err := fields.FindByPhonebook(c.Request.Context(), phonebookName)
+		if err != nil {
+			logger.Error("Error listing field definitions", "phonebook", phonebookName, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot list field definitions"})
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// DeleteField removes the custom field named in the path, for the phonebook of the query.
+func DeleteField(fields *repository.FieldDefinitionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, phonebookName := c.Param("name"), c.Query("phonebook")
+		logger.Info("Call endpoint DELETE /fields", "name", name, "phonebook", phonebookName)
+
+		found, err := fields.Delete(c.Request.Context(), phonebookName, name)
+		if err != nil {
+			logger.Error("Error deleting field definition", "name", name, "phonebook", phonebookName, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot delete field definition"})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Field definition not found"})
+			return
+		}
+
+		logger.Info("Field definition deleted", "name", name, "phonebook", phonebookName)
+		c.JSON(http.StatusOK, gin.H{"message": "Field definition deleted"})
+	}
+}
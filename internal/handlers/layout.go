@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"go-csv-import/internal/logger"
+	"go-csv-import/internal/repository"
+	"go-csv-import/internal/service/phonebook"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SaveLayout creates or replaces the fixed-width layout named in the path, from the JSON body.
+func SaveLayout(layouts *repository.LayoutRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		logger.Info("Call endpoint PUT /layouts", "name", name)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Cannot read layout"})
+			return
+		}
+
+		layout, err := phonebook.ParseLayout(name, body)
+		if err != nil {
+			logger.Error("Error parsing layout", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if err := layouts.Save(c.Request.Context(), layout); err != nil {
+			logger.Error("Error saving layout", "name", name, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot save layout"})
+			return
+		}
+
+		logger.Info("Layout saved", "name", name)
+		c.JSON(http.StatusOK, layout)
+	}
+}
+
+// GetLayout returns the fixed-width layout named in the path.
+func GetLayout(layouts *repository.LayoutRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		logger.Info("Call endpoint GET /layouts", "name", name)
+
+		layout, err := layouts.FindByName(c.Request.Context(), name)
+		if err != nil {
+			logger.Error("Error reading layout", "name", name, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot read layout"})
+			return
+		}
+		if layout == nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Layout not found"})
+			return
+		}
+		c.JSON(http.StatusOK, layout)
+	}
+}
+
+// ListLayouts returns every fixed-width layout, ordered by name.
+func ListLayouts(layouts *repository.LayoutRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Info("Call endpoint GET /layouts")
+
+		list, err := layouts.List(c.Request.Context())
+		if err != nil {
+			logger.Error("Error listing layouts", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot list layouts"})
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// DeleteLayout removes the fixed-width layout named in the path.
+func DeleteLayout(layouts *repository.LayoutRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		logger.Info("Call endpoint DELETE /layouts", "name", name)
+
+		found, err := layouts.Delete(c.Request.Context(), name)
+		if err != nil {
+			logger.Error("Error deleting layout", "name", name, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot delete layout"})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Layout not found"})
+			return
+		}
+
+		logger.Info("Layout deleted", "name", name)
+		c.JSON(http.StatusOK, gin.H{"message": "Layout deleted"})
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"go-csv-import/internal/logger"
+	"go-csv-import/internal/repository"
+	"go-csv-import/internal/service/phonebook"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	previewDefaultRows   = 10   // Contacts returned by a preview
+	previewMaxRows       = 100  // Max contacts returned by a preview
+	previewDefaultSample = 1000 // Rows validated by a preview, unless the whole file is
+)
+
+/*
+PreviewUpload parses an upload like /upload and returns its first contacts with a validation summary,
+over the first "sample" rows or over the whole file with "sample=all".
+
+Nothing is written to the database nor published, and the uploaded file is removed once previewed.
+*/
+func PreviewUpload(publisher *phonebook.PhonebookHandler, layouts *repository.LayoutRepository, fields *repository.FieldDefinitionRepository, templates *repository.TemplateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Info("Call endpoint /upload/preview")
+
+		rows, err := parsePreviewLimit(c.PostForm("rows"), previewDefaultRows)
+		if err != nil || rows > previewMaxRows {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid rows <" + c.PostForm("rows") + ">: expected 1 to " + strconv.Itoa(previewMaxRows)})
+			return
+		}
+
+		sample := 0
+		if v := c.PostForm("sample"); v != "all" {
+			if sample, err = parsePreviewLimit(v, previewDefaultSample); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid sample <" + v + ">: expected a number of rows or all"})
+				return
+			}
+		}
+
+		upload, ok := bindUpload(c, publisher, uploadRepositories{layouts, fields, templates}, "preview-"+uuid.New().String()+"-")
+		if !ok {
+			return
+		}
+		job := upload.Resolved
+		defer job.Remove()
+
+		preview, err := publisher.Preview(job, upload.Mapping, rows, sample)
+		if err != nil {
+			logger.Error("Error previewing file", "error", err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+			return
+		}
+
+		contacts := make([]contactResponse, 0, len(preview.Contacts))
+		for _, contact := range preview.Contacts {
+			contacts = append(contacts, newContactResponse(contact))
+		}
+
+		logger.Info("File previewed", "file", job.FilePath, "checked", preview.Summary.Checked, "rejected", preview.Summary.Rejected)
+		response := describeUpload(upload)
+		response["contacts"] = contacts
+		response["summary"] = preview.Summary
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// parsePreviewLimit reads a positive number of rows, or returns the default one when empty.
+func parsePreviewLimit(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, strconv.ErrSyntax
+	}
+	return n, nil
+}
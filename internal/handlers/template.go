@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"go-csv-import/internal/logger"
+	"go-csv-import/internal/repository"
+	"go-csv-import/internal/service/phonebook"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SaveTemplate creates the next version of the import template named in the path, from the JSON body.
+func SaveTemplate(templates *repository.TemplateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		logger.Info("Call endpoint PUT /templates", "name", name)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Cannot read template"})
+			return
+		}
+
+		template, err := phonebook.ParseTemplate(name, body)
+		if err != nil {
+			logger.Error("Error parsing template", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if err := templates.Save(c.Request.Context(), template); err != nil {
+			logger.Error("Error saving template", "name", name, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot save template"})
+			return
+		}
+
+		logger.Info("Template saved", "name", name, "version", template.Version)
+		c.JSON(http.StatusOK, template)
+	}
+}
+
+// GetTemplate returns the latest version of the import template named in the path, or the version of the query.
+func GetTemplate(templates *repository.TemplateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		logger.Info("Call endpoint GET /templates", "name", name, "version", c.Query("version"))
+
+		version := 0
+		if v := c.Query("version"); v != "" {
+			var err error
+			if version, err = strconv.Atoi(v); err != nil || version < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid version"})
+				return
+			}
+		}
+
+		template, err := templates.FindByName(c.Request.Context(), name, version)
+		if err != nil {
+			logger.Error("Error reading template", "name", name, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot read template"})
+			return
+		}
+		if template == nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Template not found"})
+			return
+		}
+		c.JSON(http.StatusOK, template)
+	}
+}
+
+// ListTemplates returns the latest version of every import template, ordered by name.
+func ListTemplates(templates *repository.TemplateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Info("Call endpoint GET /templates")
+
+		list, err := templates.List(c.Request.Context())
+		if err != nil {
+			logger.Error("Error listing templates", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot list templates"})
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// ListTemplateVersions returns every version of the import template named in the path, the latest first.
+func ListTemplateVersions(templates *repository.TemplateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		logger.Info("Call endpoint GET /templates/versions", "name", name)
+
+		list, err := templates.Versions(c.Request.Context(), name)
+		if err != nil {
+			logger.Error("Error listing template versions", "name", name, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot list template versions"})
+			return
+		}
+		if len(list) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Template not found"})
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// DeleteTemplate removes every version of the import template named in the path.
+func DeleteTemplate(templates *repository.TemplateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		logger.Info("Call endpoint DELETE /templates", "name", name)
+
+		found, err := templates.Delete(c.Request.Context(), name)
+		if err != nil {
+			logger.Error("Error deleting template", "name", name, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot delete template"})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Template not found"})
+			return
+		}
+
+		logger.Info("Template deleted", "name", name)
+		c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+	}
+}
@@ -8,17 +8,25 @@ import (
 	"go-csv-import/internal/cache"
 	"go-csv-import/internal/handlers/worker"
 	"go-csv-import/internal/logger"
+	"go-csv-import/internal/model"
+	"go-csv-import/internal/repository"
 	"go-csv-import/internal/service/phonebook"
 	"go-csv-import/internal/validation"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// uploadDir is the shared volume where uploaded files are saved for the worker
+const uploadDir = "/shared"
+
 type JobPublisher interface {
 	PublishImportJob(path string, maxRows int) error
 }
@@ -30,44 +38,67 @@ func HtmlUpload() gin.HandlerFunc {
 }
 
 // Upload contacts file webservice
-func Upload(publisher *phonebook.PhonebookHandler) gin.HandlerFunc {
+func Upload(publisher *phonebook.PhonebookHandler, layouts *repository.LayoutRepository, fields *repository.FieldDefinitionRepository, templates *repository.TemplateRepository) gin.HandlerFunc {
 	// TODO: Check file size to limit
 	// TODO: handle Go channels to get errors and limit go routine for a lot of files
 	return func(c *gin.Context) {
 		logger.Info("Call endpoint /upload")
-		logger.Trace("Get file from form data")
-		file, err := c.FormFile("file")
+
+		dryRun := false
+		if v := c.PostForm("dry_run"); v != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				logger.Error("Error parsing dry_run", "error", err)
+				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid dry_run <" + v + ">: expected a boolean"})
+				return
+			}
+		}
+
+		staging, err := phonebook.ParseStaging(c.PostForm("staging"))
 		if err != nil {
-			logger.Error("Error uploading file", "error", err)
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Missing File"})
+			logger.Error("Error parsing staging", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if staging != "" && dryRun {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid staging: dry runs are never committed"})
 			return
 		}
 
-		logger.Trace("Validating file type", "filename", file.Filename)
-		err = validation.IsValidCSV(file.Filename)
+		loader, err := phonebook.ParseLoader(c.PostForm("loader"))
 		if err != nil {
-			logger.Error("Error validating file type is a .csv", "error", err)
-			c.JSON(http.StatusUnsupportedMediaType, gin.H{"message": err.Error()})
+			logger.Error("Error parsing loader", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 			return
 		}
 
-		// Save uploaded file through shared volume
-		dst := filepath.Join("/shared", file.Filename)
-		logger.Debug("Saving uploaded file", "filepath", dst)
-		if err := c.SaveUploadedFile(file, dst); err != nil {
-			logger.Error("Error saving file", "message", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot save file"})
+		maxParallelism, err := phonebook.ParseMaxParallelism(c.PostForm("max_parallelism"))
+		if err != nil {
+			logger.Error("Error parsing max_parallelism", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 			return
 		}
 
+		// Uploads with the same filename must not overwrite each other on the shared volume
 		uuid := uuid.New().String()
+		upload, ok := bindUpload(c, publisher, uploadRepositories{layouts, fields, templates}, uuid+"-")
+		if !ok {
+			return
+		}
+		if err := phonebook.ValidateLoader(loader, upload.Resolved.Mode); err != nil {
+			os.Remove(upload.Job.FilePath)
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		job := upload.Job
+		job.DryRun = dryRun
+		job.Staging = staging
+		job.Loader = loader
+		job.MaxParallelism = maxParallelism
 
 		// Send file path to RabbitMQ
-		job := &phonebook.FileMessage{
-			Uuid:     uuid,
-			FilePath: dst,
-			MaxRows:  int(publisher.HttpConfig.FileChunkLimit),
-		}
+		job.Uuid = uuid
 
 		logger.Trace("Publishing message to queue", "message", job)
 		if err := publisher.Publish(job, phonebook.MessageTypeUpload); err != nil {
@@ -78,16 +109,384 @@ func Upload(publisher *phonebook.PhonebookHandler) gin.HandlerFunc {
 
 		statusUrl := publisher.HttpConfig.Host + publisher.HttpConfig.Port + "/upload/status/" + uuid
 		deleteUrl := publisher.HttpConfig.Host + publisher.HttpConfig.Port + "/delete/" + uuid
-		logger.Info("File is being processed", "file", file.Filename, "uuid", uuid, "status_url", statusUrl)
-		c.JSON(http.StatusAccepted, gin.H{
-			"message":    "File is being processed",
-			"status_url": statusUrl,
-			"delete_url": deleteUrl,
-			"uuid":       uuid,
-		})
+		logger.Info("File is being processed", "file", job.FilePath, "uuid", uuid, "status_url", statusUrl, "dry_run", dryRun)
+		response := describeUpload(upload)
+		response["message"] = "File is being processed"
+		response["status_url"] = statusUrl
+		response["delete_url"] = deleteUrl
+		response["uuid"] = uuid
+		if dryRun {
+			response["dry_run"] = true
+		}
+		if staging != "" {
+			response["staging"] = staging
+		}
+		if loader != "" {
+			response["loader"] = loader
+		}
+		if maxParallelism > 0 {
+			response["max_parallelism"] = maxParallelism
+		}
+		if staging == phonebook.StagingReview {
+			response["commit_url"] = publisher.HttpConfig.Host + publisher.HttpConfig.Port + "/upload/" + uuid + "/commit"
+			response["discard_url"] = publisher.HttpConfig.Host + publisher.HttpConfig.Port + "/upload/" + uuid + "/discard"
+		}
+		if upload.Resolved.ContinueOnError {
+			response["rejects_url"] = publisher.HttpConfig.Host + publisher.HttpConfig.Port + "/upload/" + uuid + "/rejects"
+		}
+		c.JSON(http.StatusAccepted, response)
 	}
 }
 
+// uploadRepositories are the stored settings read by uploads.
+type uploadRepositories struct {
+	Layouts   *repository.LayoutRepository
+	Fields    *repository.FieldDefinitionRepository
+	Templates *repository.TemplateRepository
+}
+
+// uploadRequest is an upload read from its form.
+type uploadRequest struct {
+	Job      *phonebook.FileMessage // Message to publish, with the options of the form
+	Resolved *phonebook.FileMessage // Message with the options of its template, resolved against the file
+	Mapping  *phonebook.Mapping     // Column mapping resolved against the headers of the file
+}
+
+/*
+bindUpload reads the upload form and saves its file to the shared volume, named with the prefix.
+
+The dialect and the mapping are resolved so that files which cannot be imported are refused before being queued.
+The error response is written when the upload is refused.
+*/
+func bindUpload(c *gin.Context, publisher *phonebook.PhonebookHandler, repositories uploadRepositories, prefix string) (*uploadRequest, bool) {
+	logger.Trace("Get file from form data")
+	file, err := c.FormFile("file")
+	if err != nil {
+		logger.Error("Error uploading file", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Missing File"})
+		return nil, false
+	}
+
+	logger.Trace("Validating file type", "filename", file.Filename)
+	err = validation.IsValidCSV(file.Filename)
+	if err != nil {
+		logger.Error("Error validating file type is a .csv", "error", err)
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	logger.Trace("Validating file content", "filename", file.Filename)
+	if err := validateUploadContent(file); err != nil {
+		logger.Error("Error validating file content", "error", err)
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	template, status, err := resolveUploadTemplate(c, repositories.Templates, c.PostForm("template"), c.PostForm("template_version"))
+	if err != nil {
+		logger.Error("Error resolving template", "error", err)
+		c.JSON(status, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	mapping, err := phonebook.ParseColumnMapping(c.PostForm("mapping"))
+	if err != nil {
+		logger.Error("Error parsing column mapping", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	dialectOptions, err := phonebook.ParseDialectOptions(
+		c.PostForm("delimiter"),
+		c.PostForm("quote"),
+		c.PostForm("comment"),
+		c.PostForm("lazy_quotes"),
+		c.PostForm("trim_leading_space"),
+		c.PostForm("has_header"),
+	)
+	if err != nil {
+		logger.Error("Error parsing dialect options", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	encoding, err := phonebook.ParseEncoding(c.PostForm("encoding"))
+	if err != nil {
+		logger.Error("Error parsing encoding", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	continueOnError := false
+	if v := c.PostForm("continue_on_error"); v != "" {
+		if continueOnError, err = strconv.ParseBool(v); err != nil {
+			logger.Error("Error parsing continue_on_error", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid continue_on_error <" + v + ">: expected a boolean"})
+			return nil, false
+		}
+	}
+
+	country, err := phonebook.ParseCountry(c.PostForm("country"))
+	if err != nil {
+		logger.Error("Error parsing country", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	phoneValidation, err := phonebook.ParsePhoneValidation(c.PostForm("phone_validation"))
+	if err != nil {
+		logger.Error("Error parsing phone validation", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	mode, err := phonebook.ParseImportMode(c.PostForm("mode"))
+	if err != nil {
+		logger.Error("Error parsing import mode", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	if template != nil && c.PostForm("mode") == "" {
+		mode = "" // Left to the template
+	}
+
+	phonebookName, err := phonebook.ParsePhonebook(c.PostForm("phonebook"))
+	if err != nil {
+		logger.Error("Error parsing phonebook", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	replace, err := phonebook.ParseReplace(c.PostForm("replace"), phonebookName, publisher.HttpConfig.LegacyReplace)
+	if err != nil {
+		logger.Error("Error parsing replace", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+	if replace && phonebookName == "" {
+		logger.Warn("Legacy replace of the default phonebook, set IMPORT_LEGACY_REPLACE=false once clients send phonebook and replace")
+	}
+
+	sheet, err := phonebook.ParseSheet(c.PostForm("sheet"))
+	if err != nil {
+		logger.Error("Error parsing sheet", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	vCardPhones, err := phonebook.ParseVCardPhones(c.PostForm("vcard_phones"))
+	if err != nil {
+		logger.Error("Error parsing vcard_phones", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	if template != nil && c.PostForm("vcard_phones") == "" {
+		vCardPhones = "" // Left to the template
+	}
+
+	format := phonebook.FormatOf(file.Filename)
+	layoutName := c.PostForm("layout")
+	if layoutName == "" && template != nil && format == phonebook.FormatFixed {
+		layoutName = template.Options.Layout
+	}
+	layout, status, err := resolveUploadLayout(c, repositories.Layouts, format, layoutName)
+	if err != nil {
+		logger.Error("Error resolving layout", "error", err)
+		c.JSON(status, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	fieldDefinitions, err := repositories.Fields.FindByPhonebook(c.Request.Context(), phonebookName)
+	if err != nil {
+		logger.Error("Error reading field definitions", "phonebook", phonebookName, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Cannot read field definitions"})
+		return nil, false
+	}
+
+	// Save uploaded file through shared volume
+	dst := filepath.Join(uploadDir, prefix+file.Filename)
+	logger.Debug("Saving uploaded file", "filepath", dst)
+	if err := c.SaveUploadedFile(file, dst); err != nil {
+		logger.Error("Error saving file", "message", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot save file"})
+		return nil, false
+	}
+
+	// Resolve dialect and mapping now to reject files that cannot be imported before queueing them
+	job := &phonebook.FileMessage{
+		FilePath:       dst,
+		MaxRows:        int(publisher.HttpConfig.FileChunkLimit),
+		Format:         format,
+		Sheet:          sheet,
+		VCardPhones:    vCardPhones,
+		Layout:         layout,
+		Mapping:        mapping,
+		Fields:         fieldDefinitions,
+		DialectOptions: dialectOptions,
+		Encoding:       encoding,
+
+		ContinueOnError: continueOnError,
+		Country:         country,
+		PhoneValidation: phoneValidation,
+
+		Mode:      mode,
+		Phonebook: phonebookName,
+		Replace:   replace,
+
+		Compression: phonebook.CompressionOf(file.Filename),
+		MaxRatio:    int(publisher.HttpConfig.ArchiveMaxRatio),
+		MaxEntries:  int(publisher.HttpConfig.ArchiveMaxEntries),
+		MaxSize:     int64(publisher.HttpConfig.ArchiveMaxSize),
+	}
+
+	// The template is applied by the worker: a copy of the message is resolved to check the file
+	resolved := job
+	if template != nil {
+		job.Template, job.TemplateVersion = template.Name, template.Version
+		applied := *job
+		resolved = &applied
+		if err := resolved.ApplyTemplate(template); err != nil {
+			logger.Error("Error applying template", "error", err)
+			os.Remove(dst)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+			return nil, false
+		}
+	}
+
+	if err := phonebook.ValidateReplace(resolved.Replace, resolved.Mode); err != nil {
+		logger.Error("Error validating replace", "error", err)
+		os.Remove(dst)
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	m, err := resolveUploadMapping(resolved)
+	if err != nil {
+		logger.Error("Error resolving column mapping", "error", err)
+		os.Remove(dst)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return nil, false
+	}
+
+	// The file is read by the worker with the dialect detected here
+	job.Encoding, job.Dialect = resolved.Encoding, resolved.Dialect
+
+	return &uploadRequest{Job: job, Resolved: resolved, Mapping: m}, true
+}
+
+// describeUpload returns the settings of an upload, as resolved against its file.
+func describeUpload(upload *uploadRequest) gin.H {
+	job := upload.Resolved
+	response := gin.H{
+		"format":    job.Format,
+		"mapping":   upload.Mapping.Describe(),
+		"encoding":  job.Encoding,
+		"phonebook": job.Phonebook,
+		"replace":   job.Replace,
+	}
+	if job.Dialect != nil {
+		response["dialect"] = job.Dialect.Describe()
+	}
+	if job.Template != "" {
+		response["template"] = job.Template
+		response["template_version"] = job.TemplateVersion
+	}
+	switch job.Format {
+	case phonebook.FormatXLSX:
+		response["sheet"] = job.Sheet
+	case phonebook.FormatVCF:
+		response["vcard_phones"] = job.VCardPhones
+	case phonebook.FormatFixed:
+		response["layout"] = job.Layout.Name
+	}
+	return response
+}
+
+// validateUploadContent checks that the first bytes of the uploaded file match its extension.
+func validateUploadContent(file *multipart.FileHeader) error {
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	head := make([]byte, validation.ContentSampleSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return validation.IsValidContent(file.Filename, head[:n])
+}
+
+/*
+resolveUploadLayout reads the stored layout of a fixed-width upload.
+
+The layout is sent with the upload message, so that changing or deleting it
+does not affect uploads already queued.
+*/
+func resolveUploadLayout(c *gin.Context, layouts *repository.LayoutRepository, format string, name string) (*model.Layout, int, error) {
+	if format != phonebook.FormatFixed {
+		if name != "" {
+			return nil, http.StatusBadRequest, errors.New("invalid layout: layouts only apply to fixed-width .txt, .dat or .fwf files")
+		}
+		return nil, 0, nil
+	}
+	if name == "" {
+		return nil, http.StatusBadRequest, errors.New("invalid layout: a layout is required to import fixed-width files")
+	}
+
+	layout, err := layouts.FindByName(c.Request.Context(), name)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("cannot read layout")
+	}
+	if layout == nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid layout: layout <%s> not found", name)
+	}
+	return layout, 0, nil
+}
+
+/*
+resolveUploadTemplate reads the version of the template of an upload, the latest one by default.
+
+The version is sent with the upload message, so that saving a new version does not affect uploads already queued.
+*/
+func resolveUploadTemplate(c *gin.Context, templates *repository.TemplateRepository, name string, version string) (*model.Template, int, error) {
+	if name == "" {
+		if version != "" {
+			return nil, http.StatusBadRequest, errors.New("invalid template_version: a template is required")
+		}
+		return nil, 0, nil
+	}
+
+	v := 0
+	if version != "" {
+		var err error
+		if v, err = strconv.Atoi(version); err != nil || v < 1 {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid template_version <%s>: expected a positive number", version)
+		}
+	}
+
+	template, err := templates.FindByName(c.Request.Context(), name, v)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("cannot read template")
+	}
+	if template == nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid template: template <%s> not found", name)
+	}
+	return template, 0, nil
+}
+
+// resolveUploadMapping detects the uploaded file encoding and dialect, then resolves the column mapping against its headers.
+func resolveUploadMapping(job *phonebook.FileMessage) (*phonebook.Mapping, error) {
+	header, err := job.ReadHeader()
+	if err != nil {
+		return nil, err
+	}
+	return job.ResolveMapping(header)
+}
+
 /*
 UploadStatus returns the status of the file upload process.
 It checks how many rows have been processed and calculates the percentage of completion.
@@ -161,6 +560,110 @@ func internalUploadStatus(uuid string) (message *worker.MessageProgressResponse,
 	return ps, resp.StatusCode, nil
 }
 
+// UploadRejects sends the CSV file of the rows rejected by an import run with "continue_on_error".
+func UploadRejects() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqId := c.Param("uuid")
+		logger.Info("Call endpoint /upload/rejects", "uuid", reqId)
+
+		// Parsing the uuid also prevents to read files outside of the upload directory
+		if _, err := uuid.Parse(reqId); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid uuid"})
+			return
+		}
+
+		path := filepath.Join(uploadDir, phonebook.RejectsFileName(reqId))
+		if _, err := os.Stat(path); err != nil {
+			logger.Warn("Rejects file not found", "file", path, "error", err)
+			c.JSON(http.StatusNotFound, gin.H{"message": "No rejected rows"})
+			return
+		}
+
+		c.FileAttachment(path, phonebook.RejectsFileName(reqId))
+	}
+}
+
+/*
+CommitStaging queues the commit of the contacts of an import staged for review into contacts.
+
+Contacts are committed by the worker, the upload status reports when they have been.
+*/
+func CommitStaging(publisher *phonebook.PhonebookHandler, imports *repository.ImportRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqId := c.Param("uuid")
+		logger.Info("Call endpoint /upload/commit", "uuid", reqId)
+
+		if status, err := checkStagingReview(c, imports, reqId); err != nil {
+			c.JSON(status, gin.H{"message": err.Error()})
+			return
+		}
+
+		job := &phonebook.FileMessage{
+			Uuid: reqId,
+		}
+
+		logger.Trace("Publishing message to queue", "message", job)
+		if err := publisher.Publish(job, phonebook.MessageTypeCommit); err != nil {
+			logger.Error("Error publishing message to queue", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish job"})
+			return
+		}
+
+		logger.Info("Staged contacts are being committed", "uuid", reqId)
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":    "Staged contacts are being committed",
+			"status_url": publisher.HttpConfig.Host + publisher.HttpConfig.Port + "/upload/status/" + reqId,
+		})
+	}
+}
+
+// DiscardStaging queues the removal of the contacts of an import staged for review, which are never committed.
+func DiscardStaging(publisher *phonebook.PhonebookHandler, imports *repository.ImportRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqId := c.Param("uuid")
+		logger.Info("Call endpoint /upload/discard", "uuid", reqId)
+
+		if status, err := checkStagingReview(c, imports, reqId); err != nil {
+			c.JSON(status, gin.H{"message": err.Error()})
+			return
+		}
+
+		job := &phonebook.FileMessage{
+			Uuid: reqId,
+		}
+
+		logger.Trace("Publishing message to queue", "message", job)
+		if err := publisher.Publish(job, phonebook.MessageTypeDiscard); err != nil {
+			logger.Error("Error publishing message to queue", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish job"})
+			return
+		}
+
+		logger.Info("Staged contacts are being discarded", "uuid", reqId)
+		c.JSON(http.StatusAccepted, gin.H{"message": "Staged contacts are being discarded"})
+	}
+}
+
+// checkStagingReview checks that the contacts of an import are staged and wait for review, and returns the error status otherwise.
+func checkStagingReview(c *gin.Context, imports *repository.ImportRepository, reqId string) (int, error) {
+	if _, err := uuid.Parse(reqId); err != nil {
+		return http.StatusBadRequest, errors.New("invalid uuid")
+	}
+
+	imp, err := imports.FindByUuid(c.Request.Context(), reqId)
+	if err != nil {
+		logger.Error("Error reading import", "uuid", reqId, "error", err)
+		return http.StatusInternalServerError, errors.New("cannot read import")
+	}
+	if imp == nil || imp.Staging == "" {
+		return http.StatusNotFound, errors.New("no staged contacts")
+	}
+	if imp.StagingStatus != phonebook.StagingStatusReview {
+		return http.StatusConflict, fmt.Errorf("staged contacts are %s, not waiting for review", imp.StagingStatus)
+	}
+	return 0, nil
+}
+
 func Delete(publisher *phonebook.PhonebookHandler) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uuid := c.Param("uuid")
@@ -27,11 +27,78 @@ type MessageProgressStore struct {
 
 // MessageProgress stores current file progress infos.
 type MessageProgress struct {
-	Inserted  atomic.Int64
-	Total     atomic.Int64
-	Duration  atomic.Int64
-	StartTime time.Time
-	Error     error
+	Inserted        atomic.Int64
+	Updated         atomic.Int64
+	Skipped         atomic.Int64
+	Rejected        atomic.Int64
+	Replaced        atomic.Int64
+	BatchSize       atomic.Int64 // Current number of contacts by query
+	Total           atomic.Int64
+	Duration        atomic.Int64
+	StartTime       time.Time
+	Error           error
+	Mapping         map[string]string         // Resolved column mapping, as contact field to source header
+	Encoding        string                    // Character encoding of the file, requested or detected
+	DryRun          bool                      // Inserts are rolled back
+	Template        string                    // Template applied to the upload
+	TemplateVersion int                       // Version of the template applied to the upload
+	Staging         string                    // Staging of the contacts: atomic or review, empty when written directly
+	StagingStatus   string                    // Step of the staged contacts
+	Entries         map[string]*EntryProgress // Progress of each file of a zip archive
+	Stages          map[string]*StageProgress // Activity of each stage of the import pipeline
+
+	mu sync.RWMutex // Guards the fields which are not atomic
+}
+
+// EntryProgress stores the progress of a file within an uploaded zip archive.
+type EntryProgress struct {
+	Processed atomic.Int64
+	Total     int64
+	Error     error // Guarded by the MessageProgress mutex
+}
+
+// StageProgress stores the activity of a stage of the import pipeline, over the FileParts of the import.
+type StageProgress struct {
+	Processed atomic.Int64 // Rows processed by the stage
+	Queued    atomic.Int64 // Rows waiting for the stage
+	Busy      atomic.Int64 // Time spent processing rows in nanoseconds, summed over the goroutines of the stage
+	Workers   atomic.Int64 // Goroutines running the stage
+}
+
+// Enqueue counts a row sent to the stage. A nil stage counts nothing.
+func (p *StageProgress) Enqueue() {
+	if p != nil {
+		p.Queued.Add(1)
+	}
+}
+
+// Dequeue counts a row received by the stage.
+func (p *StageProgress) Dequeue() {
+	if p != nil {
+		p.Queued.Add(-1)
+	}
+}
+
+// Done counts rows processed by the stage in the elapsed time.
+func (p *StageProgress) Done(rows int64, elapsed time.Duration) {
+	if p != nil {
+		p.Processed.Add(rows)
+		p.Busy.Add(elapsed.Nanoseconds())
+	}
+}
+
+// Start counts a goroutine running the stage, until Stop.
+func (p *StageProgress) Start() {
+	if p != nil {
+		p.Workers.Add(1)
+	}
+}
+
+// Stop counts a goroutine of the stage which has ended.
+func (p *StageProgress) Stop() {
+	if p != nil {
+		p.Workers.Add(-1)
+	}
 }
 
 // MessageProgressResponse is the interface contract
@@ -40,8 +107,45 @@ type MessageProgressResponse struct {
 	Status     string  `json:"Status"`
 	Total      int64   `json:"Total"`
 	Inserted   int64   `json:"Inserted"`
+	Updated    int64   `json:"Updated"`
+	Skipped    int64   `json:"Skipped"`
+	Rejected   int64   `json:"Rejected"`
+	Replaced   int64   `json:"Replaced"`
+	BatchSize  int64   `json:"BatchSize,omitempty"`
 	Percentile float64 `json:"Percentile"`
 	Duration   string  `json:"Duration"`
+
+	Mapping         map[string]string                `json:"Mapping,omitempty"`
+	Encoding        string                           `json:"Encoding,omitempty"`
+	DryRun          bool                             `json:"DryRun,omitempty"`
+	Template        string                           `json:"Template,omitempty"`
+	TemplateVersion int                              `json:"TemplateVersion,omitempty"`
+	Staging         string                           `json:"Staging,omitempty"`
+	StagingStatus   string                           `json:"StagingStatus,omitempty"`
+	Entries         map[string]EntryProgressResponse `json:"Entries,omitempty"`
+	Stages          map[string]StageProgressResponse `json:"Stages,omitempty"`
+}
+
+// EntryProgressResponse is the progress of a file within an uploaded zip archive.
+type EntryProgressResponse struct {
+	Status     string  `json:"Status"`
+	Total      int64   `json:"Total"`
+	Processed  int64   `json:"Processed"`
+	Percentile float64 `json:"Percentile"`
+}
+
+/*
+StageProgressResponse is the activity of a stage of the import pipeline.
+
+Busy is the average number of goroutines of the stage processing rows since the import started:
+a stage whose Busy is close to its Workers, with rows queued before it, limits the import.
+*/
+type StageProgressResponse struct {
+	Processed int64   `json:"Processed"`
+	Rate      float64 `json:"Rate"` // Rows processed by second
+	Queued    int64   `json:"Queued"`
+	Workers   int64   `json:"Workers"`
+	Busy      float64 `json:"Busy"`
 }
 
 func NewMessageProgressStore() *MessageProgressStore {
@@ -58,32 +162,283 @@ func (s *MessageProgressStore) Init(reqId string, total int64) {
 
 // Increment updates the total of inserted messages
 func (s *MessageProgressStore) Increment(reqId string, batch int64) {
-	if val, ok := s.counter.Load(reqId); ok {
-		if progress, ok := val.(*MessageProgress); ok {
-			progress.Inserted.Add(batch)
-			dur := time.Since(progress.StartTime)
-			progress.Duration.Store(dur.Nanoseconds())
-		}
+	s.IncrementBatch(reqId, batch, 0, 0)
+}
+
+// IncrementBatch updates the totals of inserted, updated and skipped messages
+func (s *MessageProgressStore) IncrementBatch(reqId string, inserted int64, updated int64, skipped int64) {
+	if progress, ok := s.load(reqId); ok {
+		progress.Inserted.Add(inserted)
+		progress.Updated.Add(updated)
+		progress.Skipped.Add(skipped)
+		dur := time.Since(progress.StartTime)
+		progress.Duration.Store(dur.Nanoseconds())
+	}
+}
+
+// Reject updates the total of rejected rows
+func (s *MessageProgressStore) Reject(reqId string, rows int64) {
+	if progress, ok := s.load(reqId); ok {
+		progress.Rejected.Add(rows)
+		dur := time.Since(progress.StartTime)
+		progress.Duration.Store(dur.Nanoseconds())
+	}
+}
+
+// Replace updates the total of contacts removed from the phonebook replaced by the import
+func (s *MessageProgressStore) Replace(reqId string, rows int64) {
+	if progress, ok := s.load(reqId); ok {
+		progress.Replaced.Add(rows)
+		dur := time.Since(progress.StartTime)
+		progress.Duration.Store(dur.Nanoseconds())
+	}
+}
+
+// SetBatchSize stores the current number of contacts inserted by query
+func (s *MessageProgressStore) SetBatchSize(reqId string, size int64) {
+	if progress, ok := s.load(reqId); ok {
+		progress.BatchSize.Store(size)
 	}
 }
 
 // SetError stores last error to track status details
 func (s *MessageProgressStore) SetError(reqId string, err error) {
-	if val, ok := s.counter.Load(reqId); ok {
-		if progress, ok := val.(*MessageProgress); ok {
-			progress.Error = err
+	if progress, ok := s.load(reqId); ok {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		progress.Error = err
+	}
+}
+
+// SetMapping stores the column mapping resolved for the file headers
+func (s *MessageProgressStore) SetMapping(reqId string, mapping map[string]string) {
+	if progress, ok := s.load(reqId); ok {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		progress.Mapping = mapping
+	}
+}
+
+// SetEncoding stores the character encoding of the file
+func (s *MessageProgressStore) SetEncoding(reqId string, encoding string) {
+	if progress, ok := s.load(reqId); ok {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		progress.Encoding = encoding
+	}
+}
+
+// SetDryRun stores whether the inserts of the import are rolled back
+func (s *MessageProgressStore) SetDryRun(reqId string, dryRun bool) {
+	if progress, ok := s.load(reqId); ok {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		progress.DryRun = dryRun
+	}
+}
+
+// SetTemplate stores the template version applied to the upload
+func (s *MessageProgressStore) SetTemplate(reqId string, template string, version int) {
+	if progress, ok := s.load(reqId); ok {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		progress.Template = template
+		progress.TemplateVersion = version
+	}
+}
+
+// SetStaging stores the staging of the contacts of the import and its current step
+func (s *MessageProgressStore) SetStaging(reqId string, staging string, status string) {
+	if progress, ok := s.load(reqId); ok {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		progress.Staging = staging
+		progress.StagingStatus = status
+	}
+}
+
+// Commit updates the totals once the staged contacts have been committed:
+// staged contacts are counted as inserted until they are compared with the committed ones
+func (s *MessageProgressStore) Commit(reqId string, inserted int64, updated int64, skipped int64) {
+	if progress, ok := s.load(reqId); ok {
+		progress.Inserted.Store(inserted)
+		progress.Updated.Add(updated)
+		progress.Skipped.Add(skipped)
+	}
+}
+
+// InitEntry sets total rows to process for a file of a zip archive
+func (s *MessageProgressStore) InitEntry(reqId string, entry string, total int64) {
+	if progress, ok := s.load(reqId); ok {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		if progress.Entries == nil {
+			progress.Entries = make(map[string]*EntryProgress)
+		}
+		progress.Entries[entry] = &EntryProgress{Total: total}
+	}
+}
+
+// IncrementEntry updates the total of processed rows of a file of a zip archive
+func (s *MessageProgressStore) IncrementEntry(reqId string, entry string, rows int64) {
+	if e, ok := s.loadEntry(reqId, entry); ok {
+		e.Processed.Add(rows)
+	}
+}
+
+// SetEntryError stores the error which stopped a file of a zip archive
+func (s *MessageProgressStore) SetEntryError(reqId string, entry string, err error) {
+	if progress, ok := s.load(reqId); ok {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		if e, ok := progress.Entries[entry]; ok {
+			e.Error = err
 		}
 	}
 }
 
+// InitStages sets the stages of the import pipeline, whose activity is reported with the progress
+func (s *MessageProgressStore) InitStages(reqId string, stages ...string) {
+	if progress, ok := s.load(reqId); ok {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		progress.Stages = make(map[string]*StageProgress, len(stages))
+		for _, stage := range stages {
+			progress.Stages[stage] = &StageProgress{}
+		}
+	}
+}
+
+// Stage retrieves the activity of a stage of the import pipeline, nil when unknown
+func (s *MessageProgressStore) Stage(reqId string, stage string) *StageProgress {
+	progress, ok := s.load(reqId)
+	if !ok {
+		return nil
+	}
+
+	progress.mu.RLock()
+	defer progress.mu.RUnlock()
+	return progress.Stages[stage]
+}
+
 // Get retrieves file progress status from his identifier
 func (s *MessageProgressStore) Get(reqId string) (inserted int64, total int64, duration int64, err error, ok bool) {
+	if progress, ok := s.load(reqId); ok {
+		progress.mu.RLock()
+		defer progress.mu.RUnlock()
+		return progress.Inserted.Load(), progress.Total.Load(), progress.Duration.Load(), progress.Error, true
+	}
+	return 0, 0, 0, nil, false
+}
+
+// Response builds the progress status to deliver from API, and the last error if any
+func (s *MessageProgressStore) Response(reqId string) (*MessageProgressResponse, error, bool) {
+	progress, ok := s.load(reqId)
+	if !ok {
+		return nil, nil, false
+	}
+
+	progress.mu.RLock()
+	defer progress.mu.RUnlock()
+
+	inserted, updated, skipped, rejected := progress.Inserted.Load(), progress.Updated.Load(), progress.Skipped.Load(), progress.Rejected.Load()
+	processed := inserted + updated + skipped + rejected
+	total := progress.Total.Load()
+
+	return &MessageProgressResponse{
+		Total:           total,
+		Inserted:        inserted,
+		Updated:         updated,
+		Skipped:         skipped,
+		Rejected:        rejected,
+		Replaced:        progress.Replaced.Load(),
+		BatchSize:       progress.BatchSize.Load(),
+		Percentile:      utils.MathRound(float64(processed)/float64(total)*100, 3),
+		Status:          s.getStatus(processed, total, progress.Error),
+		Duration:        time.Duration(progress.Duration.Load()).Round(time.Millisecond).String(),
+		Mapping:         progress.Mapping,
+		Encoding:        progress.Encoding,
+		DryRun:          progress.DryRun,
+		Template:        progress.Template,
+		TemplateVersion: progress.TemplateVersion,
+		Staging:         progress.Staging,
+		StagingStatus:   progress.StagingStatus,
+		Entries:         s.entriesResponse(progress.Entries),
+		Stages:          s.stagesResponse(progress.Stages, time.Since(progress.StartTime)),
+	}, progress.Error, true
+}
+
+// stagesResponse builds the activity of each stage of the import pipeline, over the elapsed time of the import
+func (s *MessageProgressStore) stagesResponse(stages map[string]*StageProgress, elapsed time.Duration) map[string]StageProgressResponse {
+	if len(stages) == 0 || elapsed <= 0 {
+		return nil
+	}
+
+	resp := make(map[string]StageProgressResponse, len(stages))
+	for name, stage := range stages {
+		processed := stage.Processed.Load()
+		resp[name] = StageProgressResponse{
+			Processed: processed,
+			Rate:      utils.MathRound(float64(processed)/elapsed.Seconds(), 1),
+			Queued:    stage.Queued.Load(),
+			Workers:   stage.Workers.Load(),
+			Busy:      utils.MathRound(float64(stage.Busy.Load())/float64(elapsed.Nanoseconds()), 2),
+		}
+	}
+	return resp
+}
+
+// entriesResponse builds the progress status of each file of a zip archive
+func (s *MessageProgressStore) entriesResponse(entries map[string]*EntryProgress) map[string]EntryProgressResponse {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	resp := make(map[string]EntryProgressResponse, len(entries))
+	for name, e := range entries {
+		processed := e.Processed.Load()
+		status := s.getStatus(processed, e.Total, e.Error)
+		percentile := 100.0
+		if e.Total > 0 {
+			percentile = utils.MathRound(float64(processed)/float64(e.Total)*100, 3)
+		} else if e.Error == nil {
+			status = string(StatusCompleted) // Nothing to import
+		}
+		if e.Error != nil {
+			status += ": " + e.Error.Error()
+		}
+
+		resp[name] = EntryProgressResponse{
+			Status:     status,
+			Total:      e.Total,
+			Processed:  processed,
+			Percentile: percentile,
+		}
+	}
+	return resp
+}
+
+// load retrieves file progress from his identifier
+func (s *MessageProgressStore) load(reqId string) (*MessageProgress, bool) {
 	if val, ok := s.counter.Load(reqId); ok {
 		if progress, ok := val.(*MessageProgress); ok {
-			return progress.Inserted.Load(), progress.Total.Load(), progress.Duration.Load(), progress.Error, true
+			return progress, true
 		}
 	}
-	return 0, 0, 0, nil, false
+	return nil, false
+}
+
+// loadEntry retrieves the progress of a file of a zip archive
+func (s *MessageProgressStore) loadEntry(reqId string, entry string) (*EntryProgress, bool) {
+	progress, ok := s.load(reqId)
+	if !ok {
+		return nil, false
+	}
+
+	progress.mu.RLock()
+	defer progress.mu.RUnlock()
+	e, ok := progress.Entries[entry]
+	return e, ok
 }
 
 // Handler retrieves progress file infos from file request identifier
@@ -93,15 +448,7 @@ func (s *MessageProgressStore) Handler() http.Handler {
 		uuid := c.Param("uuid")
 		logger.Info("Call endpoint /upload/status", "uuid", uuid)
 
-		if inserted, total, duration, err, ok := s.Get(uuid); ok {
-			resp := &MessageProgressResponse{
-				Total:      total,
-				Inserted:   inserted,
-				Percentile: utils.MathRound(float64(inserted)/float64(total)*100, 3),
-				Status:     s.getStatus(inserted, total, err),
-				Duration:   time.Duration(duration).Round(time.Millisecond).String(),
-			}
-
+		if resp, err, ok := s.Response(uuid); ok {
 			statusCode := http.StatusOK
 			if resp.Status == string(StatusError) {
 				statusCode = http.StatusMultiStatus
@@ -120,12 +467,12 @@ func (s *MessageProgressStore) Handler() http.Handler {
 }
 
 // getStatus defines progress status as string following file progress state
-func (s *MessageProgressStore) getStatus(inserted int64, total int64, err error) string {
+func (s *MessageProgressStore) getStatus(processed int64, total int64, err error) string {
 	if err != nil {
 		return string(StatusError)
-	} else if inserted == 0 {
+	} else if processed == 0 {
 		return string(StatusScheduled)
-	} else if inserted < total {
+	} else if processed < total {
 		return string(StatusProcessing)
 	}
 	return string(StatusCompleted)
@@ -0,0 +1,16 @@
+package model
+
+import "gorm.io/gorm"
+
+// Checkpoint is the progress of a FilePart, committed along with its contacts to resume an import after a worker crash.
+type Checkpoint struct {
+	gorm.Model
+	Uuid     string `gorm:"size:36;uniqueIndex:idx_checkpoint"`
+	Entry    string `gorm:"size:255;uniqueIndex:idx_checkpoint"` // File of the FilePart within a zip archive, if any
+	Offset   int64  `gorm:"uniqueIndex:idx_checkpoint"`          // Byte offset of the FilePart in the uploaded file
+	ReadRows int    // Rows of the FilePart read when its last batch was committed, rejected rows included
+	Inserted int64
+	Updated  int64
+	Skipped  int64
+	Done     bool // Every row of the FilePart has been committed
+}
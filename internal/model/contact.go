@@ -4,8 +4,21 @@ import "gorm.io/gorm"
 
 type Contact struct {
 	gorm.Model
-	ReqId     string `gorm:"index:idx_req_id"`
-	Phone     string
-	Firstname string
-	Lastname  string
+	ReqId      string `gorm:"index:idx_req_id"`
+	Phonebook  string `gorm:"size:100;index:idx_phonebook"` // Name of the contact list, empty for the default list
+	Phone      string // Phone number as written in the uploaded file
+	PhoneE164  string `gorm:"size:16"` // Normalized phone number, empty when it cannot be normalized
+	Firstname  string
+	Lastname   string
+	Email      string         `gorm:"size:254"`
+	Company    string         `gorm:"size:255"`
+	Title      string         `gorm:"size:255"`
+	Attributes map[string]any `gorm:"serializer:json;type:json"`            // Custom fields by name, and other source columns which are not mapped to a field by header
+	NaturalKey *string        `gorm:"size:120;uniqueIndex:idx_natural_key"` // Phonebook and normalized phone, set by upsert and skip-existing imports
+}
+
+// NewNaturalKey returns the key identifying a contact within a phonebook from its normalized phone number.
+func NewNaturalKey(phonebook string, phoneE164 string) *string {
+	key := phonebook + "|" + phoneE164
+	return &key
 }
@@ -0,0 +1,15 @@
+package model
+
+import "gorm.io/gorm"
+
+// FieldDefinition is a typed custom field of the contacts of a phonebook, stored in their attributes.
+type FieldDefinition struct {
+	gorm.Model `json:"-"`
+	Phonebook  string   `gorm:"size:100;uniqueIndex:idx_field_definition" json:"phonebook"`
+	Name       string   `gorm:"size:100;uniqueIndex:idx_field_definition" json:"name"` // Attribute name, matched against the source headers
+	Type       string   `gorm:"size:20" json:"type"`                                   // string, integer, decimal, date, boolean or enum
+	Format     string   `gorm:"size:50" json:"format,omitempty"`                       // Layout of date values, such as DD/MM/YYYY
+	Values     []string `gorm:"serializer:json" json:"values,omitempty"`               // Allowed values of enum fields
+	Required   bool     `json:"required"`                                              // Rows without value are rejected
+	MaxLength  int      `json:"max_length,omitempty"`                                  // Max number of characters of values, unlimited when zero
+}
@@ -0,0 +1,16 @@
+package model
+
+import "gorm.io/gorm"
+
+// Import records an upload processed by the worker.
+type Import struct {
+	gorm.Model
+	Uuid            string `gorm:"size:36;uniqueIndex:idx_import_uuid"`
+	Phonebook       string `gorm:"size:100"`
+	Template        string `gorm:"size:100"` // Template of the upload, empty when uploaded without template
+	TemplateVersion int    // Version of the template applied to the upload
+	Mode            string `gorm:"size:20"` // Import mode, applied when staged contacts are committed
+	Replace         bool   // The phonebook is replaced when staged contacts are committed
+	Staging         string `gorm:"size:20"` // Staging of the contacts before they are committed: atomic or review, empty when written directly
+	StagingStatus   string `gorm:"size:20"` // Step of the staged contacts: loading, review, committing, committed or discarded
+}
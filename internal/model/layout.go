@@ -0,0 +1,20 @@
+package model
+
+import "gorm.io/gorm"
+
+// Layout describes the fields of a fixed-width file, stored to be reused by name.
+type Layout struct {
+	gorm.Model `json:"-"`
+	Name       string        `gorm:"size:100;uniqueIndex:idx_layout_name" json:"name"`
+	SkipLines  int           `json:"skip_lines"` // Number of header lines before the first record
+	Fields     []LayoutField `gorm:"serializer:json" json:"fields"`
+}
+
+// LayoutField is a field of a fixed-width record, sliced from its columns.
+type LayoutField struct {
+	Name  string `json:"name"`           // Name of the field, matched by the column mapping like a CSV header
+	Start int    `json:"start"`          // Column of the first character of the field, starting at 1
+	Width int    `json:"width"`          // Number of characters of the field
+	Trim  string `json:"trim,omitempty"` // Sides the padding is removed from: both (default), left, right or none
+	Pad   string `json:"pad,omitempty"`  // Padding character removed from the field, a space by default
+}
@@ -0,0 +1,30 @@
+package model
+
+import "gorm.io/gorm"
+
+// Template is a named set of upload options. Templates are versioned: saving a template creates its next version.
+type Template struct {
+	gorm.Model `json:"-"`
+	Name       string          `gorm:"size:100;uniqueIndex:idx_template_version" json:"name"`
+	Version    int             `gorm:"uniqueIndex:idx_template_version" json:"version"`
+	Options    TemplateOptions `gorm:"serializer:json;type:json" json:"options"`
+}
+
+// TemplateOptions are the options of an upload stored by a template, named after the upload form fields.
+type TemplateOptions struct {
+	Mapping          map[string]string `json:"mapping,omitempty"`
+	Delimiter        string            `json:"delimiter,omitempty"`
+	Quote            string            `json:"quote,omitempty"`
+	Comment          string            `json:"comment,omitempty"`
+	LazyQuotes       *bool             `json:"lazy_quotes,omitempty"`
+	TrimLeadingSpace *bool             `json:"trim_leading_space,omitempty"`
+	HasHeader        *bool             `json:"has_header,omitempty"`
+	Encoding         string            `json:"encoding,omitempty"`
+	Mode             string            `json:"mode,omitempty"`
+	ContinueOnError  *bool             `json:"continue_on_error,omitempty"`
+	Country          string            `json:"country,omitempty"`
+	PhoneValidation  string            `json:"phone_validation,omitempty"`
+	Sheet            string            `json:"sheet,omitempty"`
+	VCardPhones      string            `json:"vcard_phones,omitempty"`
+	Layout           string            `json:"layout,omitempty"` // Name of the layout of fixed-width files
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/model"
+
+	"gorm.io/gorm/clause"
+)
+
+// CheckpointRepository stores the progress of the FileParts of imports being processed.
+type CheckpointRepository struct{}
+
+func NewCheckpointRepository() *CheckpointRepository {
+	return &CheckpointRepository{}
+}
+
+// Save creates or updates the checkpoint of the FilePart, within the transaction of the context if any.
+func (r *CheckpointRepository) Save(ctx context.Context, checkpoint *model.Checkpoint) error {
+	return conn(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "uuid"}, {Name: "entry"}, {Name: "offset"}},
+			DoUpdates: clause.AssignmentColumns([]string{"read_rows", "inserted", "updated", "skipped", "done", "updated_at"}),
+		}).
+		Create(checkpoint).
+		Error
+}
+
+// FindByUuid returns the checkpoints of the FileParts of an import.
+func (r *CheckpointRepository) FindByUuid(ctx context.Context, uuid string) ([]model.Checkpoint, error) {
+	var checkpoints []model.Checkpoint
+	err := db.DB.WithContext(ctx).Where("uuid = ?", uuid).Find(&checkpoints).Error
+	return checkpoints, err
+}
+
+// DeleteByUuid removes the checkpoints of an import for good, once it does not need to be resumed.
+func (r *CheckpointRepository) DeleteByUuid(ctx context.Context, uuid string) error {
+	return db.DB.WithContext(ctx).Unscoped().Where("uuid = ?", uuid).Delete(&model.Checkpoint{}).Error
+}
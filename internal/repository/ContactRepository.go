@@ -2,17 +2,65 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"go-csv-import/internal/db"
 	"go-csv-import/internal/logger"
 	"go-csv-import/internal/model"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/hints"
 )
 
+// ImportMode defines how imported contacts are written when they already exist.
+type ImportMode string
+
+const (
+	ImportModeInsert       ImportMode = "insert"        // Always insert, duplicates are allowed without natural key
+	ImportModeUpsert       ImportMode = "upsert"        // Update contacts with the same natural key
+	ImportModeSkipExisting ImportMode = "skip-existing" // Keep contacts with the same natural key untouched
+)
+
+// IsInsert tells whether contacts are always inserted, which is the default mode.
+func (m ImportMode) IsInsert() bool {
+	return m == "" || m == ImportModeInsert
+}
+
+// contactColumns are the contact columns written by the loaders which bypass GORM, identifiers excepted.
+var contactColumns = []string{"created_at", "updated_at", "req_id", "phonebook", "phone", "phone_e164", "firstname", "lastname", "email", "company", "title", "attributes", "natural_key"}
+
+// BatchResult counts how the contacts of a batch have been written.
+type BatchResult struct {
+	Inserted int64
+	Updated  int64
+	Skipped  int64
+}
+
+// ContactQuery filters the contacts to search, within an import or a phonebook.
+type ContactQuery struct {
+	ReqId      string            // Import of the contacts, when not empty
+	Phonebook  *string           // Phonebook of the contacts, when set: the default phonebook is the empty name
+	Email      string            // Exact email, when not empty
+	Company    string            // Exact company, when not empty
+	Attributes map[string]string // Exact values of custom attributes, by source header
+	AfterId    uint              // Contacts after this identifier, to read the next page
+	Limit      int               // Max number of contacts
+}
+
 type Repository interface {
 	Insert(contact *model.Contact) error
 	InsertBatch(ctx context.Context, contacts []*model.Contact) error
-	Truncate() error
+	SaveBatch(ctx context.Context, mode ImportMode, contacts []*model.Contact) (BatchResult, error)
+	LoadBatch(ctx context.Context, contacts []*model.Contact) (BatchResult, error)
+	DeletePhonebookExcept(ctx context.Context, phonebook string, reqId string, batch int) (int64, error)
+	FindByReqIdInBatches(ctx context.Context, reqId string, batch int, fn func(contacts []*model.Contact) error) error
+	Search(ctx context.Context, query ContactQuery) ([]model.Contact, error)
 }
 
 type ContactRepository struct{}
@@ -26,14 +74,168 @@ func (r *ContactRepository) Insert(c *model.Contact) error {
 }
 
 func (r *ContactRepository) InsertBatch(ctx context.Context, c []*model.Contact) error {
-	return db.DB.Clauses(hints.IgnoreIndex("idx_req_id")).Create(c).Error
+	return contactsTable(ctx).Clauses(hints.IgnoreIndex("idx_req_id")).Create(c).Error
+}
+
+/*
+SaveBatch writes contacts following the import mode, with the database native upsert.
+
+Contacts sharing a natural key within the batch are merged first: the last one wins
+on upsert, the first one on skip-existing. Contacts without natural key are inserted.
+*/
+func (r *ContactRepository) SaveBatch(ctx context.Context, mode ImportMode, c []*model.Contact) (BatchResult, error) {
+	if mode == "" || mode == ImportModeInsert {
+		err := r.InsertBatch(ctx, c)
+		if err != nil {
+			return BatchResult{}, err
+		}
+		return BatchResult{Inserted: int64(len(c))}, nil
+	}
+
+	contacts, merged := mergeNaturalKeys(c, mode == ImportModeUpsert)
+
+	var onConflict clause.OnConflict
+	if mode == ImportModeUpsert {
+		// updated_at always changes, so MySQL reports 2 affected rows for every updated contact
+		onConflict = clause.OnConflict{
+			Columns:   []clause.Column{{Name: "natural_key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"updated_at", "deleted_at", "req_id", "phone", "phone_e164", "firstname", "lastname", "email", "company", "title", "attributes"}),
+		}
+	} else {
+		onConflict = clause.OnConflict{
+			Columns:   []clause.Column{{Name: "natural_key"}},
+			DoNothing: true,
+		}
+	}
+
+	res := contactsTable(ctx).Clauses(onConflict).Create(contacts)
+	if res.Error != nil {
+		return BatchResult{}, res.Error
+	}
+
+	total := int64(len(contacts))
+	if mode == ImportModeUpsert {
+		updated := res.RowsAffected - total
+		return BatchResult{Inserted: total - updated, Updated: updated + merged}, nil
+	}
+	return BatchResult{Inserted: res.RowsAffected, Skipped: total - res.RowsAffected + merged}, nil
+}
+
+/*
+LoadBatch inserts contacts with LOAD DATA LOCAL INFILE, streamed from memory to the server,
+which saves the statement building and the reflection of GORM inserts. The server must allow local_infile.
+
+With LOCAL, MySQL skips the rows it cannot write with a warning: the batch fails unless every contact has been inserted,
+and is loaded within a transaction so that it is never written partially.
+*/
+func (r *ContactRepository) LoadBatch(ctx context.Context, c []*model.Contact) (BatchResult, error) {
+	rows, err := loadDataRows(c, time.Now())
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	name := "contacts-" + strconv.FormatUint(loadDataReaders.Add(1), 10)
+	mysql.RegisterReaderHandler(name, func() io.Reader { return rows })
+	defer mysql.DeregisterReaderHandler(name)
+
+	query := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE `%s` CHARACTER SET utf8mb4 "+
+		"FIELDS TERMINATED BY '\\t' ESCAPED BY '\\\\' LINES TERMINATED BY '\\n' (%s)",
+		name, contactsTableName(ctx), strings.Join(contactColumns, ", "))
+
+	var inserted int64
+	err = Transaction(ctx, func(ctx context.Context) error {
+		res := conn(ctx).Exec(query)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		inserted = res.RowsAffected
+		if inserted < int64(len(c)) {
+			var level, message string
+			var code int
+			conn(ctx).Raw("SHOW WARNINGS LIMIT 1").Row().Scan(&level, &code, &message)
+			return fmt.Errorf("%d of %d contacts loaded: %s", inserted, len(c), message)
+		}
+		return nil
+	})
+	if err != nil {
+		return BatchResult{}, err
+	}
+	return BatchResult{Inserted: inserted}, nil
+}
+
+/*
+ReleaseTakenKeys clears the natural key of the contacts of an insert batch which cannot take it:
+the contacts sharing the key of an earlier contact of the batch, and those whose key is held by a contact of the table.
+They are inserted as duplicates, which are never matched.
+*/
+func (r *ContactRepository) ReleaseTakenKeys(ctx context.Context, c []*model.Contact) error {
+	keys := make([]string, 0, len(c))
+	seen := make(map[string]bool, len(c))
+	for _, contact := range c {
+		if contact.NaturalKey == nil {
+			continue
+		}
+		if seen[*contact.NaturalKey] {
+			contact.NaturalKey = nil
+			continue
+		}
+		seen[*contact.NaturalKey] = true
+		keys = append(keys, *contact.NaturalKey)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var taken []string
+	if err := contactsTable(ctx).Model(&model.Contact{}).Unscoped().Where("natural_key IN ?", keys).Pluck("natural_key", &taken).Error; err != nil {
+		return err
+	}
+	if len(taken) == 0 {
+		return nil
+	}
+
+	held := make(map[string]bool, len(taken))
+	for _, key := range taken {
+		held[key] = true
+	}
+	for _, contact := range c {
+		if contact.NaturalKey != nil && held[*contact.NaturalKey] {
+			contact.NaturalKey = nil
+		}
+	}
+	return nil
+}
+
+// mergeNaturalKeys removes the contacts of a batch sharing a natural key, and returns how many were removed.
+func mergeNaturalKeys(c []*model.Contact, keepLast bool) ([]*model.Contact, int64) {
+	positions := make(map[string]int, len(c))
+	contacts := make([]*model.Contact, 0, len(c))
+
+	for _, contact := range c {
+		if contact.NaturalKey == nil {
+			contacts = append(contacts, contact)
+			continue
+		}
+
+		if i, exists := positions[*contact.NaturalKey]; exists {
+			if keepLast {
+				contacts[i] = contact
+			}
+			continue
+		}
+		positions[*contact.NaturalKey] = len(contacts)
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, int64(len(c) - len(contacts))
 }
 
-func (r *ContactRepository) Truncate() error {
-	logger.Trace("Truncating contacts table...")
-	err := db.DB.Exec("TRUNCATE TABLE contacts").Error
-	logger.Trace("...Contacts table trucated")
-	return err
+// MaxAllowedPacket returns the size in bytes of the largest statement accepted by the server.
+func (r *ContactRepository) MaxAllowedPacket(ctx context.Context) (int64, error) {
+	var size int64
+	err := db.DB.WithContext(ctx).Raw("SELECT @@max_allowed_packet").Scan(&size).Error
+	return size, err
 }
 
 func (r *ContactRepository) CountByReqId(reqId string) (int, error) {
@@ -45,10 +247,90 @@ func (r *ContactRepository) CountByReqId(reqId string) (int, error) {
 	return int(count), nil
 }
 
+/*
+FindByReqIdInBatches reads the contacts written by an import, in their insertion order,
+by chunks of batch contacts so that large imports are never loaded at once.
+
+Reading stops at the first error returned by fn.
+*/
+func (r *ContactRepository) FindByReqIdInBatches(ctx context.Context, reqId string, batch int, fn func(contacts []*model.Contact) error) error {
+	var contacts []*model.Contact
+	return db.DB.
+		WithContext(ctx).
+		Where("req_id = ?", reqId).
+		FindInBatches(&contacts, batch, func(tx *gorm.DB, _ int) error {
+			return fn(contacts)
+		}).
+		Error
+}
+
+/*
+Search returns the contacts matching the query, ordered by identifier.
+
+Attributes are matched with JSON_EXTRACT, which cannot use an index:
+queries are expected to be scoped by an import or a phonebook, which are indexed.
+*/
+func (r *ContactRepository) Search(ctx context.Context, query ContactQuery) ([]model.Contact, error) {
+	tx := contactsTable(ctx).Where("id > ?", query.AfterId)
+	if query.ReqId != "" {
+		tx = tx.Where("req_id = ?", query.ReqId)
+	}
+	if query.Phonebook != nil {
+		tx = tx.Where("phonebook = ?", *query.Phonebook)
+	}
+	if query.Email != "" {
+		tx = tx.Where("email = ?", query.Email)
+	}
+	if query.Company != "" {
+		tx = tx.Where("company = ?", query.Company)
+	}
+	for key, value := range query.Attributes {
+		path, err := json.Marshal(key) // Keys are quoted to hold any character
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where("JSON_UNQUOTE(JSON_EXTRACT(attributes, ?)) = ?", "$."+string(path), value)
+	}
+
+	var contacts []model.Contact
+	err := tx.Order("id").Limit(query.Limit).Find(&contacts).Error
+	return contacts, err
+}
+
+/*
+DeleteByReqId soft deletes the contacts written by an import.
+
+Their natural keys are cleared, as the unique index still holds soft deleted rows:
+upsert and skip-existing imports would otherwise match the deleted contacts instead of importing them again.
+*/
 func (r *ContactRepository) DeleteByReqId(ctx context.Context, reqId string) error {
 	return db.DB.
 		WithContext(ctx).
+		Model(&model.Contact{}).
 		Where("req_id = ?", reqId).
-		Delete(&model.Contact{}).
+		Updates(map[string]any{"deleted_at": time.Now(), "natural_key": nil}).
 		Error
 }
+
+/*
+DeletePhonebookExcept removes the contacts of a phonebook which have not been written by the import,
+so that the phonebook only holds the contacts of this import.
+
+Contacts are removed for good, by chunks of batch rows to keep locks short, so their natural keys can be imported again.
+Contacts of other phonebooks are never touched. Within the transaction of the context, the phonebook is replaced at once.
+*/
+func (r *ContactRepository) DeletePhonebookExcept(ctx context.Context, phonebook string, reqId string, batch int) (int64, error) {
+	var deleted int64
+	for {
+		res := conn(ctx).Exec("DELETE FROM contacts WHERE phonebook = ? AND req_id <> ? LIMIT ?", phonebook, reqId, batch)
+		if res.Error != nil {
+			return deleted, res.Error
+		}
+
+		deleted += res.RowsAffected
+		logger.Trace("Replaced contacts deleted", "phonebook", phonebook, "deleted", deleted)
+		if res.RowsAffected < int64(batch) {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"go-csv-import/internal/config"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/model"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestMergeNaturalKeys(t *testing.T) {
+	contacts := []*model.Contact{
+		{Firstname: "John", NaturalKey: model.NewNaturalKey("", "+33701020300")},
+		{Firstname: "Jane", NaturalKey: model.NewNaturalKey("", "+33701020301")},
+		{Firstname: "Johnny", NaturalKey: model.NewNaturalKey("", "+33701020300")},
+		{Firstname: "Unknown"},
+		{Firstname: "Unknown"},
+	}
+
+	last, merged := mergeNaturalKeys(contacts, true)
+	assert.Equal(t, int64(1), merged)
+	assert.Len(t, last, 4)
+	assert.Equal(t, "Johnny", last[0].Firstname)
+
+	first, merged := mergeNaturalKeys(contacts, false)
+	assert.Equal(t, int64(1), merged)
+	assert.Len(t, first, 4)
+	assert.Equal(t, "John", first[0].Firstname)
+}
+
+func TestDeleteByReqId_ReleasesNaturalKeys(t *testing.T) {
+	recorder := dryRun(t)
+
+	assert.NoError(t, NewContactRepository().DeleteByReqId(context.Background(), "uuid"))
+	assert.Len(t, recorder.statements, 1)
+	assert.Contains(t, recorder.statements[0], "`natural_key`=NULL")
+	assert.Contains(t, recorder.statements[0], "`deleted_at`=")
+	assert.Contains(t, recorder.statements[0], "`deleted_at` IS NULL")
+}
+
+func TestReleaseTakenKeys(t *testing.T) {
+	recorder := dryRun(t)
+	contacts := []*model.Contact{
+		{Firstname: "John", NaturalKey: model.NewNaturalKey("", "+33701020300")},
+		{Firstname: "Johnny", NaturalKey: model.NewNaturalKey("", "+33701020300")},
+		{Firstname: "Unknown"},
+	}
+
+	// Later contacts of the batch with the same key are inserted without key
+	assert.NoError(t, NewContactRepository().ReleaseTakenKeys(context.Background(), contacts))
+	assert.NotNil(t, contacts[0].NaturalKey)
+	assert.Nil(t, contacts[1].NaturalKey)
+	assert.Len(t, recorder.statements, 1)
+	assert.Contains(t, recorder.statements[0], "natural_key IN ('|+33701020300')")
+}
+
+func TestSaveBatch_MatchesInsertedContacts(t *testing.T) {
+	testDB(t)
+	ctx := context.Background()
+	repository := NewContactRepository()
+	phonebook := "test-" + uuid.NewString()
+	defer db.DB.Unscoped().Where("phonebook = ?", phonebook).Delete(&model.Contact{})
+
+	contact := func(reqId string, firstname string) []*model.Contact {
+		return []*model.Contact{{ReqId: reqId, Phonebook: phonebook, Firstname: firstname, Phone: "0701020300", PhoneE164: "+33701020300", NaturalKey: model.NewNaturalKey(phonebook, "+33701020300")}}
+	}
+
+	// A duplicate of an insert import is kept without key
+	for _, firstname := range []string{"Jonh", "John"} {
+		batch := contact(uuid.NewString(), firstname)
+		assert.NoError(t, repository.ReleaseTakenKeys(ctx, batch))
+		res, err := repository.SaveBatch(ctx, ImportModeInsert, batch)
+		assert.NoError(t, err)
+		assert.Equal(t, BatchResult{Inserted: 1}, res)
+	}
+
+	// The corrected file matches the contact keyed by the first insert import
+	res, err := repository.SaveBatch(ctx, ImportModeUpsert, contact(uuid.NewString(), "John"))
+	assert.NoError(t, err)
+	assert.Equal(t, BatchResult{Updated: 1}, res)
+
+	var keyed int64
+	assert.NoError(t, db.DB.Model(&model.Contact{}).Where("phonebook = ? AND natural_key IS NOT NULL", phonebook).Count(&keyed).Error)
+	assert.Equal(t, int64(1), keyed)
+}
+
+func TestSaveBatch_ImportsDeletedContactAgain(t *testing.T) {
+	testDB(t)
+	ctx := context.Background()
+	repository := NewContactRepository()
+	phonebook := "test-" + uuid.NewString()
+	defer db.DB.Unscoped().Where("phonebook = ?", phonebook).Delete(&model.Contact{})
+
+	contact := func(reqId string) []*model.Contact {
+		return []*model.Contact{{ReqId: reqId, Phonebook: phonebook, Phone: "0701020300", PhoneE164: "+33701020300", NaturalKey: model.NewNaturalKey(phonebook, "+33701020300")}}
+	}
+
+	first := uuid.NewString()
+	res, err := repository.SaveBatch(ctx, ImportModeSkipExisting, contact(first))
+	assert.NoError(t, err)
+	assert.Equal(t, BatchResult{Inserted: 1}, res)
+	assert.NoError(t, repository.DeleteByReqId(ctx, first))
+
+	for _, mode := range []ImportMode{ImportModeSkipExisting, ImportModeUpsert} {
+		reqId := uuid.NewString()
+		res, err := repository.SaveBatch(ctx, mode, contact(reqId))
+		assert.NoError(t, err)
+		assert.Equal(t, BatchResult{Inserted: 1}, res, mode)
+
+		count, err := repository.CountByReqId(reqId)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count, mode)
+		assert.NoError(t, repository.DeleteByReqId(ctx, reqId))
+	}
+}
+
+func TestDeletePhonebookExcept_KeepsContactsOfTheFile(t *testing.T) {
+	testDB(t)
+	ctx := context.Background()
+	repository := NewContactRepository()
+	phonebook := "test-" + uuid.NewString()
+	defer db.DB.Unscoped().Where("phonebook = ?", phonebook).Delete(&model.Contact{})
+
+	contact := func(reqId string, phone string) *model.Contact {
+		e164 := "+33" + phone[1:]
+		return &model.Contact{ReqId: reqId, Phonebook: phonebook, Phone: phone, PhoneE164: e164, NaturalKey: model.NewNaturalKey(phonebook, e164)}
+	}
+
+	previous := uuid.NewString()
+	_, err := repository.SaveBatch(ctx, ImportModeUpsert, []*model.Contact{contact(previous, "0701020300"), contact(previous, "0701020301")})
+	assert.NoError(t, err)
+
+	// The file holds one of the existing contacts
+	reqId := uuid.NewString()
+	res, err := repository.SaveBatch(ctx, ImportModeUpsert, []*model.Contact{contact(reqId, "0701020300"), contact(reqId, "0701020302")})
+	assert.NoError(t, err)
+	assert.Equal(t, BatchResult{Inserted: 1, Updated: 1}, res)
+
+	deleted, err := repository.DeletePhonebookExcept(ctx, phonebook, reqId, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	var phones []string
+	assert.NoError(t, db.DB.Model(&model.Contact{}).Where("phonebook = ?", phonebook).Order("phone").Pluck("phone", &phones).Error)
+	assert.Equal(t, []string{"0701020300", "0701020302"}, phones)
+}
+
+// sqlRecorder records the statements built by GORM.
+type sqlRecorder struct {
+	gormlogger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// dryRun replaces the database with a connection which records the statements instead of running them.
+func dryRun(t *testing.T) *sqlRecorder {
+	recorder := &sqlRecorder{Interface: gormlogger.Discard}
+	conn, err := gorm.Open(
+		mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/contactdb", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: recorder},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+	return recorder
+}
+
+// testDB connects to the database whose DSN is read from TEST_DB_DSN, or skips the test.
+func testDB(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	if err := db.Connect(&config.DbConfig{Dsn: dsn}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.AutoMigrate()
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/model"
+
+	"gorm.io/gorm/clause"
+)
+
+// FieldDefinitionRepository stores the custom fields of each phonebook.
+type FieldDefinitionRepository struct{}
+
+func NewFieldDefinitionRepository() *FieldDefinitionRepository {
+	return &FieldDefinitionRepository{}
+}
+
+// Save creates the field definition, or replaces the definition with the same name in the phonebook.
+func (r *FieldDefinitionRepository) Save(ctx context.Context, field *model.FieldDefinition) error {
+	return db.DB.
+		WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "phonebook"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"type", "format", "values", "required", "max_length", "updated_at"}),
+		}).
+		Create(field).
+		Error
+}
+
+// FindByPhonebook returns the field definitions of a phonebook, ordered by name.
+func (r *FieldDefinitionRepository) FindByPhonebook(ctx context.Context, phonebook string) ([]model.FieldDefinition, error) {
+	var fields []model.FieldDefinition
+	err := db.DB.WithContext(ctx).Where("phonebook = ?", phonebook).Order("name").Find(&fields).Error
+	return fields, err
+}
+
+// Delete removes the field definition for good, and tells whether it existed.
+// Attributes already imported are kept.
+func (r *FieldDefinitionRepository) Delete(ctx context.Context, phonebook string, name string) (bool, error) {
+	res := db.DB.WithContext(ctx).Unscoped().Where("phonebook = ? AND name = ?", phonebook, name).Delete(&model.FieldDefinition{})
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/model"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImportRepository records the uploads processed by the worker.
+type ImportRepository struct{}
+
+func NewImportRepository() *ImportRepository {
+	return &ImportRepository{}
+}
+
+// finalStagingStatuses are the steps staged contacts never leave: committed and discarded.
+var finalStagingStatuses = []string{"committed", "discarded"}
+
+/*
+Save records the import, or updates the import with the same uuid when its message is processed again.
+
+The staging of an import whose staged contacts have already been committed or discarded is left as is.
+*/
+func (r *ImportRepository) Save(ctx context.Context, imp *model.Import) error {
+	// Assignments are evaluated in order: staging is compared with the staging status before it is updated
+	updates := clause.AssignmentColumns([]string{"phonebook", "template", "template_version", "mode", "replace", "updated_at"})
+	for _, column := range []string{"staging", "staging_status"} {
+		updates = append(updates, clause.Assignment{
+			Column: clause.Column{Name: column},
+			Value:  gorm.Expr("IF(staging_status IN ?, ?, VALUES(?))", finalStagingStatuses, clause.Column{Name: column}, clause.Column{Name: column}),
+		})
+	}
+
+	return db.DB.
+		WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "uuid"}},
+			DoUpdates: updates,
+		}).
+		Create(imp).
+		Error
+}
+
+// IsFinalStagingStatus tells whether staged contacts with the status have been committed or discarded.
+func IsFinalStagingStatus(status string) bool {
+	return slices.Contains(finalStagingStatuses, status)
+}
+
+// FindByUuid returns the import with the uuid, or nil when there is none.
+func (r *ImportRepository) FindByUuid(ctx context.Context, uuid string) (*model.Import, error) {
+	var imp model.Import
+	err := db.DB.WithContext(ctx).Where("uuid = ?", uuid).First(&imp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// SetStagingStatus moves the staged contacts of the import to the status, and tells whether they had the expected one.
+// Concurrent commits and discards of the same import are thus processed once, within the transaction of the context if any.
+func (r *ImportRepository) SetStagingStatus(ctx context.Context, uuid string, from string, to string) (bool, error) {
+	res := conn(ctx).
+		Model(&model.Import{}).
+		Where("uuid = ? AND staging_status = ?", uuid, from).
+		Update("staging_status", to)
+	return res.RowsAffected > 0, res.Error
+}
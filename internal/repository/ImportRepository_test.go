@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportRepository_SaveKeepsFinalStaging(t *testing.T) {
+	recorder := dryRun(t)
+
+	imp := &model.Import{Uuid: "uuid", Staging: "atomic", StagingStatus: "loading"}
+	assert.NoError(t, NewImportRepository().Save(context.Background(), imp))
+	assert.Len(t, recorder.statements, 1)
+	assert.Contains(t, recorder.statements[0], "`staging`=IF(staging_status IN ('committed','discarded'), `staging`, VALUES(`staging`))")
+	assert.Contains(t, recorder.statements[0], "`staging_status`=IF(staging_status IN ('committed','discarded'), `staging_status`, VALUES(`staging_status`))")
+}
+
+func TestImportRepository_SaveRedelivered(t *testing.T) {
+	testDB(t)
+	ctx := context.Background()
+	repository := NewImportRepository()
+	id := uuid.NewString()
+	defer db.DB.Unscoped().Where("uuid = ?", id).Delete(&model.Import{})
+
+	assert.NoError(t, repository.Save(ctx, &model.Import{Uuid: id, Staging: "review", StagingStatus: "loading"}))
+	assert.NoError(t, repository.Save(ctx, &model.Import{Uuid: id, Phonebook: "friends", Staging: "review", StagingStatus: "loading"}))
+	imp, err := repository.FindByUuid(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "friends", imp.Phonebook)
+	assert.Equal(t, "loading", imp.StagingStatus)
+
+	claimed, err := repository.SetStagingStatus(ctx, id, "loading", "committed")
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	assert.NoError(t, repository.Save(ctx, &model.Import{Uuid: id, Staging: "atomic", StagingStatus: "loading"}))
+	imp, err = repository.FindByUuid(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "review", imp.Staging)
+	assert.Equal(t, "committed", imp.StagingStatus)
+}
+
+func TestImportRepository_SetStagingStatusOnce(t *testing.T) {
+	testDB(t)
+	ctx := context.Background()
+	repository := NewImportRepository()
+	id := uuid.NewString()
+	defer db.DB.Unscoped().Where("uuid = ?", id).Delete(&model.Import{})
+
+	assert.NoError(t, repository.Save(ctx, &model.Import{Uuid: id, Staging: "review", StagingStatus: "review"}))
+	claimed, err := repository.SetStagingStatus(ctx, id, "review", "committing")
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = repository.SetStagingStatus(ctx, id, "review", "committing")
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LayoutRepository stores the layouts of fixed-width files by name.
+type LayoutRepository struct{}
+
+func NewLayoutRepository() *LayoutRepository {
+	return &LayoutRepository{}
+}
+
+// Save creates the layout, or replaces the layout with the same name.
+func (r *LayoutRepository) Save(ctx context.Context, layout *model.Layout) error {
+	return db.DB.
+		WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"skip_lines", "fields", "updated_at"}),
+		}).
+		Create(layout).
+		Error
+}
+
+// FindByName returns the layout with the name, or nil when there is none.
+func (r *LayoutRepository) FindByName(ctx context.Context, name string) (*model.Layout, error) {
+	var layout model.Layout
+	err := db.DB.WithContext(ctx).Where("name = ?", name).First(&layout).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// List returns every layout, ordered by name.
+func (r *LayoutRepository) List(ctx context.Context) ([]model.Layout, error) {
+	var layouts []model.Layout
+	err := db.DB.WithContext(ctx).Order("name").Find(&layouts).Error
+	return layouts, err
+}
+
+// Delete removes the layout with the name for good, and tells whether it existed.
+// Uploads already queued keep the layout they have been sent with.
+func (r *LayoutRepository) Delete(ctx context.Context, name string) (bool, error) {
+	res := db.DB.WithContext(ctx).Unscoped().Where("name = ?", name).Delete(&model.Layout{})
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/model"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// mysqlMaxPlaceholders is the max number of placeholders of a MySQL prepared statement.
+const mysqlMaxPlaceholders = 65535
+
+// preparedMaxStmts is the max number of cached statements, batches of other sizes are written with ad-hoc statements.
+const preparedMaxStmts = 16
+
+// preparedKey identifies a cached INSERT statement by its table and its number of rows.
+type preparedKey struct {
+	table string
+	rows  int
+}
+
+/*
+PreparedContactRepository inserts contacts with multi-row INSERT statements prepared with database/sql,
+bypassing the statement building and the reflection of GORM. Other queries are those of ContactRepository.
+
+Every batch is written with one statement of its number of rows, split only above the configured size.
+Statements are cached by number of rows, up to preparedMaxStmts of them: the sizes of full batches repeat
+while the batch size is stable, and batches of other sizes are written with an ad-hoc statement.
+
+Within a transaction, contacts are written with an ad-hoc statement on the connection of the transaction,
+prepared once per batch, as statements prepared on the pool would be prepared again on that connection.
+*/
+type PreparedContactRepository struct {
+	*ContactRepository
+
+	batch int // Rows of the largest statement, the max batch size
+
+	mu    sync.Mutex
+	stmts map[preparedKey]*sql.Stmt
+
+	args sync.Pool // Reused arguments of statements, as *[]any
+}
+
+func NewPreparedContactRepository(batch uint) *PreparedContactRepository {
+	rows := int(batch)
+	if limit := mysqlMaxPlaceholders / len(contactColumns); rows > limit {
+		rows = limit
+	}
+
+	return &PreparedContactRepository{
+		ContactRepository: NewContactRepository(),
+		batch:             rows,
+		stmts:             make(map[preparedKey]*sql.Stmt),
+	}
+}
+
+// SaveBatch writes contacts with the prepared statements in insert mode, with the database native upsert otherwise.
+func (r *PreparedContactRepository) SaveBatch(ctx context.Context, mode ImportMode, c []*model.Contact) (BatchResult, error) {
+	if mode != "" && mode != ImportModeInsert {
+		return r.ContactRepository.SaveBatch(ctx, mode, c)
+	}
+
+	if err := r.InsertBatch(ctx, c); err != nil {
+		return BatchResult{}, err
+	}
+	return BatchResult{Inserted: int64(len(c))}, nil
+}
+
+// InsertBatch inserts contacts with the cached statements, within the transaction of the context if any.
+func (r *PreparedContactRepository) InsertBatch(ctx context.Context, c []*model.Contact) error {
+	now := time.Now()
+	table := contactsTableName(ctx)
+
+	for len(c) > 0 {
+		rows := min(len(c), r.batch)
+		if err := r.insert(ctx, table, c[:rows], now); err != nil {
+			return err
+		}
+		c = c[rows:]
+	}
+	return nil
+}
+
+// insert writes the contacts with one statement, the cached one of their number of rows when possible.
+func (r *PreparedContactRepository) insert(ctx context.Context, table string, c []*model.Contact, now time.Time) error {
+	args, err := r.rowArgs(c, now)
+	if err != nil {
+		return err
+	}
+	defer r.putArgs(args)
+
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		_, err = tx.Statement.ConnPool.ExecContext(ctx, insertQuery(table, len(c)), *args...)
+		return err
+	}
+
+	stmt, err := r.prepare(ctx, table, len(c))
+	if err != nil {
+		return err
+	}
+	if stmt == nil {
+		sqlDB, err := db.DB.DB()
+		if err != nil {
+			return err
+		}
+		_, err = sqlDB.ExecContext(ctx, insertQuery(table, len(c)), *args...)
+		return err
+	}
+	_, err = stmt.ExecContext(ctx, *args...)
+	return err
+}
+
+// rowArgs returns the arguments of the statement inserting the contacts, to be returned with putArgs.
+func (r *PreparedContactRepository) rowArgs(c []*model.Contact, now time.Time) (*[]any, error) {
+	args := r.getArgs(len(c) * len(contactColumns))
+	for _, contact := range c {
+		var attributes any
+		if contact.Attributes != nil {
+			raw, err := json.Marshal(contact.Attributes)
+			if err != nil {
+				r.putArgs(args)
+				return nil, err
+			}
+			attributes = string(raw) // MySQL refuses JSON values from binary strings
+		}
+
+		var naturalKey any
+		if contact.NaturalKey != nil {
+			naturalKey = *contact.NaturalKey
+		}
+
+		*args = append(*args,
+			now,
+			now,
+			contact.ReqId,
+			contact.Phonebook,
+			contact.Phone,
+			contact.PhoneE164,
+			contact.Firstname,
+			contact.Lastname,
+			contact.Email,
+			contact.Company,
+			contact.Title,
+			attributes,
+			naturalKey,
+		)
+	}
+
+	return args, nil
+}
+
+/*
+prepare returns the cached statement inserting rows contacts into the table, prepared on first use,
+or nil once preparedMaxStmts statements are cached.
+*/
+func (r *PreparedContactRepository) prepare(ctx context.Context, table string, rows int) (*sql.Stmt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := preparedKey{table, rows}
+	if stmt, ok := r.stmts[key]; ok {
+		return stmt, nil
+	}
+	if len(r.stmts) >= preparedMaxStmts {
+		return nil, nil
+	}
+
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := sqlDB.PrepareContext(ctx, insertQuery(table, rows))
+	if err != nil {
+		return nil, err
+	}
+	r.stmts[key] = stmt
+	return stmt, nil
+}
+
+// insertQuery returns the INSERT statement of rows contacts into the table.
+func insertQuery(table string, rows int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(contactColumns)), ", ") + ")"
+	return fmt.Sprintf("INSERT INTO `%s` (%s) VALUES %s", table, strings.Join(contactColumns, ", "),
+		strings.TrimSuffix(strings.Repeat(row+", ", rows), ", "))
+}
+
+// getArgs returns an empty slice of statement arguments, reused from the pool when possible.
+func (r *PreparedContactRepository) getArgs(size int) *[]any {
+	if args, ok := r.args.Get().(*[]any); ok && cap(*args) >= size {
+		*args = (*args)[:0]
+		return args
+	}
+	args := make([]any, 0, size)
+	return &args
+}
+
+// putArgs returns statement arguments to the pool, without keeping the contacts values alive.
+func (r *PreparedContactRepository) putArgs(args *[]any) {
+	clear(*args)
+	r.args.Put(args)
+}
+
+// Release closes the cached statements of a table, once no more contacts are inserted into it.
+func (r *PreparedContactRepository) Release(table string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, stmt := range r.stmts {
+		if key.table == table {
+			stmt.Close()
+			delete(r.stmts, key)
+		}
+	}
+}
+
+// Close releases the cached statements.
+func (r *PreparedContactRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	for key, stmt := range r.stmts {
+		if e := stmt.Close(); e != nil {
+			err = e
+		}
+		delete(r.stmts, key)
+	}
+	return err
+}
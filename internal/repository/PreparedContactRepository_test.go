@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"encoding/csv"
+	"go-csv-import/internal/config"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/model"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// benchBatch is the default number of contacts inserted by query.
+const benchBatch = 3000
+
+// benchOddBatch is a number of contacts inserted by query once adjusted to the insert latency.
+const benchOddBatch = 2999
+
+func TestInsertQuery(t *testing.T) {
+	query := insertQuery("contacts", 3)
+	assert.True(t, strings.HasPrefix(query, "INSERT INTO `contacts` (created_at, "))
+	assert.Equal(t, 3*len(contactColumns), strings.Count(query, "?"))
+	assert.Equal(t, 3, strings.Count(query, "(?, "))
+}
+
+/*
+BenchmarkInsertBatch compares the GORM inserts with the prepared statements on testdata/contacts_100k.csv,
+with batches of the default size, and with batches of an odd size each written within a transaction
+as with checkpoints. It needs a database, whose DSN is read from BENCH_DB_DSN:
+
+	BENCH_DB_DSN='appuser:apppass@tcp(localhost:3306)/contactdb?charset=utf8mb4&parseTime=True&loc=Local' \
+		go test ./internal/repository -run '^$' -bench InsertBatch -benchmem
+*/
+func BenchmarkInsertBatch(b *testing.B) {
+	dsn := os.Getenv("BENCH_DB_DSN")
+	if dsn == "" {
+		b.Skip("BENCH_DB_DSN is not set")
+	}
+	if err := db.Connect(&config.DbConfig{Dsn: dsn}); err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	db.AutoMigrate()
+
+	contacts := readBenchContacts(b, "../../testdata/contacts_100k.csv")
+	prepared := NewPreparedContactRepository(benchBatch * 4)
+	defer prepared.Close()
+
+	inTransaction := func(insert func(ctx context.Context, contacts []*model.Contact) error) func(ctx context.Context, contacts []*model.Contact) error {
+		return func(ctx context.Context, contacts []*model.Contact) error {
+			return Transaction(ctx, func(ctx context.Context) error {
+				return insert(ctx, contacts)
+			})
+		}
+	}
+
+	repositories := []struct {
+		name   string
+		batch  int
+		insert func(ctx context.Context, contacts []*model.Contact) error
+	}{
+		{"gorm", benchBatch, NewContactRepository().InsertBatch},
+		{"prepared", benchBatch, prepared.InsertBatch},
+		{"gorm-checkpoints", benchOddBatch, inTransaction(NewContactRepository().InsertBatch)},
+		{"prepared-checkpoints", benchOddBatch, inTransaction(prepared.InsertBatch)},
+	}
+
+	for _, repository := range repositories {
+		b.Run(repository.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for start := 0; start < len(contacts); start += repository.batch {
+					end := min(start+repository.batch, len(contacts))
+					if err := repository.insert(context.Background(), contacts[start:end]); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				b.StopTimer()
+				if err := db.DB.Exec("DELETE FROM contacts WHERE req_id = ?", contacts[0].ReqId).Error; err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(len(contacts)*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
+
+// readBenchContacts reads the contacts of a semicolon-separated file with a Phone, Lastname and Firstname header.
+func readBenchContacts(b *testing.B, path string) []*model.Contact {
+	f, err := os.Open(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.Comma = ';'
+	records, err := reader.ReadAll()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	reqId := uuid.New().String()
+	contacts := make([]*model.Contact, 0, len(records)-1)
+	for _, record := range records[1:] {
+		contacts = append(contacts, &model.Contact{ReqId: reqId, Phone: record[0], Lastname: record[1], Firstname: record[2]})
+	}
+	return contacts
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/model"
+	"strings"
+)
+
+// stagingColumns are the contact columns moved from a staging table, identifiers excepted.
+var stagingColumns = []string{"created_at", "updated_at", "deleted_at", "req_id", "phonebook", "phone", "phone_e164", "firstname", "lastname", "email", "company", "title", "attributes", "natural_key"}
+
+// StagingRepository manages the tables holding the contacts of imports until they are committed.
+type StagingRepository struct{}
+
+func NewStagingRepository() *StagingRepository {
+	return &StagingRepository{}
+}
+
+// StagingTable returns the name of the table staging the contacts of an import.
+func StagingTable(uuid string) string {
+	return "contacts_staging_" + strings.ReplaceAll(uuid, "-", "")
+}
+
+// Create creates the staging table with the columns and indexes of contacts, unless it already exists.
+// A resumed import keeps loading the table it started with.
+func (r *StagingRepository) Create(ctx context.Context, table string) error {
+	return db.DB.WithContext(ctx).Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` LIKE `contacts`", table)).Error
+}
+
+// Drop removes the staging table, once committed or discarded.
+func (r *StagingRepository) Drop(ctx context.Context, table string) error {
+	return db.DB.WithContext(ctx).Exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s`", table)).Error
+}
+
+/*
+Commit moves the contacts of the staging table into contacts with a single statement,
+following the import mode, within the transaction of the context if any.
+
+Contacts have been merged by natural key while loaded, as the staging table has the same unique index:
+the counts are those of SaveBatch for one batch of every staged contact.
+With the insert mode, staged contacts whose natural key is held by a contact are moved without key, like by SaveBatch.
+*/
+func (r *StagingRepository) Commit(ctx context.Context, table string, mode ImportMode) (BatchResult, error) {
+	var total int64
+	if err := conn(ctx).Table(table).Model(&model.Contact{}).Count(&total).Error; err != nil {
+		return BatchResult{}, err
+	}
+
+	if mode.IsInsert() {
+		release := fmt.Sprintf("UPDATE `%s` AS s JOIN `contacts` AS c ON c.natural_key = s.natural_key SET s.natural_key = NULL", table)
+		if err := conn(ctx).Exec(release).Error; err != nil {
+			return BatchResult{}, err
+		}
+	}
+
+	columns := strings.Join(stagingColumns, ", ")
+	query := fmt.Sprintf("INSERT INTO `contacts` (%s) SELECT %s FROM `%s` AS s", columns, columns, table)
+	switch mode {
+	case ImportModeUpsert:
+		// updated_at always changes, so MySQL reports 2 affected rows for every updated contact
+		updates := make([]string, 0, len(stagingColumns))
+		for _, column := range stagingColumns {
+			if column != "created_at" && column != "natural_key" {
+				updates = append(updates, column+" = s."+column)
+			}
+		}
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	case ImportModeSkipExisting:
+		query += " ON DUPLICATE KEY UPDATE id = id"
+	}
+
+	res := conn(ctx).Exec(query)
+	if res.Error != nil {
+		return BatchResult{}, res.Error
+	}
+
+	switch mode {
+	case ImportModeUpsert:
+		updated := res.RowsAffected - total
+		return BatchResult{Inserted: total - updated, Updated: updated}, nil
+	case ImportModeSkipExisting:
+		return BatchResult{Inserted: res.RowsAffected, Skipped: total - res.RowsAffected}, nil
+	}
+	return BatchResult{Inserted: res.RowsAffected}, nil
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStagingTable(t *testing.T) {
+	// MySQL names are limited to 64 characters and dashes must be quoted
+	table := StagingTable("3f2b8c1e-6d4a-4e7b-9a51-0c2d7e8f9a10")
+	assert.Equal(t, "contacts_staging_3f2b8c1e6d4a4e7b9a510c2d7e8f9a10", table)
+	assert.LessOrEqual(t, len(table), 64)
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// TemplateRepository stores the versions of the import templates by name.
+type TemplateRepository struct{}
+
+func NewTemplateRepository() *TemplateRepository {
+	return &TemplateRepository{}
+}
+
+/*
+Save creates the next version of the template, numbered from 1.
+
+Versions are never updated, so that imports keep referencing the options they used.
+Concurrent saves of the same template are refused by the unique index on name and version.
+*/
+func (r *TemplateRepository) Save(ctx context.Context, template *model.Template) error {
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var last int
+		err := tx.Model(&model.Template{}).
+			Where("name = ?", template.Name).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&last).
+			Error
+		if err != nil {
+			return err
+		}
+
+		template.Version = last + 1
+		return tx.Create(template).Error
+	})
+}
+
+// FindByName returns the version of the template, the latest one when version is 0, or nil when there is none.
+func (r *TemplateRepository) FindByName(ctx context.Context, name string, version int) (*model.Template, error) {
+	tx := db.DB.WithContext(ctx).Where("name = ?", name)
+	if version > 0 {
+		tx = tx.Where("version = ?", version)
+	}
+
+	var template model.Template
+	err := tx.Order("version DESC").First(&template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// List returns the latest version of every template, ordered by name.
+func (r *TemplateRepository) List(ctx context.Context) ([]model.Template, error) {
+	latest := db.DB.Model(&model.Template{}).Select("name, MAX(version)").Group("name")
+
+	var templates []model.Template
+	err := db.DB.WithContext(ctx).Where("(name, version) IN (?)", latest).Order("name").Find(&templates).Error
+	return templates, err
+}
+
+// Versions returns every version of the template, the latest first.
+func (r *TemplateRepository) Versions(ctx context.Context, name string) ([]model.Template, error) {
+	var templates []model.Template
+	err := db.DB.WithContext(ctx).Where("name = ?", name).Order("version DESC").Find(&templates).Error
+	return templates, err
+}
+
+// Delete removes every version of the template for good, and tells whether it existed.
+// Imports keep the name and version of their template.
+func (r *TemplateRepository) Delete(ctx context.Context, name string) (bool, error) {
+	res := db.DB.WithContext(ctx).Unscoped().Where("name = ?", name).Delete(&model.Template{})
+	return res.RowsAffected > 0, res.Error
+}
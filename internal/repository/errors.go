@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL errors of statements which waited for the locks of concurrent transactions.
+const (
+	mysqlLockWaitTimeout = 1205
+	mysqlDeadlock        = 1213
+)
+
+// mysqlDuplicateEntry is the MySQL error of a statement writing a value already held by a unique index.
+const mysqlDuplicateEntry = 1062
+
+// IsLockError tells whether a statement failed on a lock wait timeout or a deadlock.
+func IsLockError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlLockWaitTimeout || mysqlErr.Number == mysqlDeadlock
+	}
+	return false
+}
+
+// IsDuplicateKeyError tells whether a statement failed on a value already held by a unique index.
+func IsDuplicateKeyError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-csv-import/internal/model"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// loadDataNull is the LOAD DATA value of a NULL column.
+const loadDataNull = `\N`
+
+// loadDataEscaper escapes the characters of a LOAD DATA value which would be read as a separator or an escape.
+var loadDataEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+// loadDataReaders numbers the readers registered to the MySQL driver, so that concurrent batches never share a name.
+var loadDataReaders atomic.Uint64
+
+/*
+loadDataRows writes the contacts as LOAD DATA rows with the default format:
+tab-separated contactColumns, one contact by line, and backslash escapes.
+
+Contacts are created at the time given, as GORM sets CreatedAt and UpdatedAt on insert.
+*/
+func loadDataRows(contacts []*model.Contact, now time.Time) (*bytes.Buffer, error) {
+	timestamp := now.Format("2006-01-02 15:04:05.000")
+
+	var buf bytes.Buffer
+	for _, c := range contacts {
+		attributes := loadDataNull
+		if c.Attributes != nil {
+			raw, err := json.Marshal(c.Attributes)
+			if err != nil {
+				return nil, err
+			}
+			attributes = loadDataEscaper.Replace(string(raw))
+		}
+
+		naturalKey := loadDataNull
+		if c.NaturalKey != nil {
+			naturalKey = loadDataEscaper.Replace(*c.NaturalKey)
+		}
+
+		values := []string{
+			timestamp,
+			timestamp,
+			loadDataEscaper.Replace(c.ReqId),
+			loadDataEscaper.Replace(c.Phonebook),
+			loadDataEscaper.Replace(c.Phone),
+			loadDataEscaper.Replace(c.PhoneE164),
+			loadDataEscaper.Replace(c.Firstname),
+			loadDataEscaper.Replace(c.Lastname),
+			loadDataEscaper.Replace(c.Email),
+			loadDataEscaper.Replace(c.Company),
+			loadDataEscaper.Replace(c.Title),
+			attributes,
+			naturalKey,
+		}
+		buf.WriteString(strings.Join(values, "\t"))
+		buf.WriteByte('\n')
+	}
+	return &buf, nil
+}
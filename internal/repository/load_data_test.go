@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"go-csv-import/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDataRows(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	contacts := []*model.Contact{
+		{ReqId: "42", Phone: "0701020300", PhoneE164: "+33701020300", Firstname: "John\tJr", Lastname: `O\Brien`},
+		{ReqId: "42", Phonebook: "customers", Phone: "0701020301", Company: "Line\nbreak", Attributes: map[string]any{"Customer Id": "C-42"}, NaturalKey: model.NewNaturalKey("customers", "+33701020301")},
+	}
+
+	rows, err := loadDataRows(contacts, now)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"2024-05-01 10:30:00.000\t2024-05-01 10:30:00.000\t42\t\t0701020300\t+33701020300\tJohn\\tJr\tO\\\\Brien\t\t\t\t\\N\t\\N\n"+
+			"2024-05-01 10:30:00.000\t2024-05-01 10:30:00.000\t42\tcustomers\t0701020301\t\t\t\t\tLine\\nbreak\t\t{\"Customer Id\":\"C-42\"}\tcustomers|+33701020301\n",
+		rows.String())
+}
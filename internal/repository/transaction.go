@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"go-csv-import/internal/db"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+type tableKey struct{}
+
+// WithTx returns a context whose repository calls run within the transaction.
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// conn returns the transaction of the context, or the database connection.
+func conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.DB.WithContext(ctx)
+}
+
+// Transaction runs fn within a transaction, committed unless fn returns an error.
+// Repository calls given the context of fn run within the transaction.
+func Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(WithTx(ctx, tx))
+	})
+}
+
+// WithTable returns a context whose contact repository calls read and write the table instead of contacts.
+func WithTable(ctx context.Context, table string) context.Context {
+	return context.WithValue(ctx, tableKey{}, table)
+}
+
+// contactsTable returns the connection of the context to the contacts table, or to the table set by WithTable.
+func contactsTable(ctx context.Context) *gorm.DB {
+	if table, ok := ctx.Value(tableKey{}).(string); ok {
+		return conn(ctx).Table(table)
+	}
+	return conn(ctx)
+}
+
+// contactsTableName returns the name of the contacts table, or of the table set by WithTable.
+func contactsTableName(ctx context.Context) string {
+	if table, ok := ctx.Value(tableKey{}).(string); ok {
+		return table
+	}
+	return "contacts"
+}
@@ -23,9 +23,27 @@ type UploadRouter struct {
 
 func (r UploadRouter) Load(s *gin.Engine) {
 	s.GET("/ping", handlers.HealthCheck)
-	s.POST("/upload", middleware.Timeout(5*time.Second), middleware.LimitRequestSize(r.HttpConfig.MaxContentLength), handlers.Upload(r.Services.PhonebookUploader))
+	s.POST("/upload", middleware.Timeout(5*time.Second), middleware.LimitRequestSize(r.HttpConfig.MaxContentLength), handlers.Upload(r.Services.PhonebookUploader, r.Services.Layouts, r.Services.Fields, r.Services.Templates))
+	s.POST("/upload/preview", middleware.Timeout(r.HttpConfig.FileTimeout), middleware.LimitRequestSize(r.HttpConfig.MaxContentLength), handlers.PreviewUpload(r.Services.PhonebookUploader, r.Services.Layouts, r.Services.Fields, r.Services.Templates))
 	s.GET("/upload/status/:uuid", handlers.UploadStatus(r.Services.PhonebookUploader))
+	s.GET("/upload/:uuid/rejects", handlers.UploadRejects())
+	s.GET("/upload/:uuid/vcf", handlers.ExportVCard(r.Services.Contacts, r.HttpConfig))
+	s.POST("/upload/:uuid/commit", handlers.CommitStaging(r.Services.PhonebookUploader, r.Services.Imports))
+	s.POST("/upload/:uuid/discard", handlers.DiscardStaging(r.Services.PhonebookUploader, r.Services.Imports))
+	s.GET("/contacts", handlers.SearchContacts(r.Services.Contacts, r.Services.Imports))
 	s.DELETE("/delete/:uuid", handlers.Delete(r.Services.PhonebookUploader))
+	s.GET("/layouts", handlers.ListLayouts(r.Services.Layouts))
+	s.GET("/layouts/:name", handlers.GetLayout(r.Services.Layouts))
+	s.PUT("/layouts/:name", handlers.SaveLayout(r.Services.Layouts))
+	s.DELETE("/layouts/:name", handlers.DeleteLayout(r.Services.Layouts))
+	s.GET("/fields", handlers.ListFields(r.Services.Fields))
+	s.PUT("/fields/:name", handlers.SaveField(r.Services.Fields))
+	s.DELETE("/fields/:name", handlers.DeleteField(r.Services.Fields))
+	s.GET("/templates", handlers.ListTemplates(r.Services.Templates))
+	s.GET("/templates/:name", handlers.GetTemplate(r.Services.Templates))
+	s.GET("/templates/:name/versions", handlers.ListTemplateVersions(r.Services.Templates))
+	s.PUT("/templates/:name", handlers.SaveTemplate(r.Services.Templates))
+	s.DELETE("/templates/:name", handlers.DeleteTemplate(r.Services.Templates))
 	// todo: add routes to search, cancel, delete from uuid. use amqp tags
 	s.LoadHTMLGlob("templates/*")
 	s.GET("/upload-form", handlers.HtmlUpload())
@@ -0,0 +1,255 @@
+package phonebook
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Compression formats of uploaded files.
+const (
+	CompressionGzip = "gzip" // A single CSV file compressed with gzip
+	CompressionZip  = "zip"  // A zip archive of CSV files
+)
+
+// ErrCompressionRatio is returned when a compressed file expands beyond the allowed ratio.
+var ErrCompressionRatio = errors.New("compression ratio exceeded")
+
+// ErrUncompressedSize is returned when the contents of a compressed upload exceed the allowed size.
+var ErrUncompressedSize = errors.New("uncompressed size exceeded")
+
+// CompressionOf returns the compression of an uploaded file from its name, or an empty string for a plain file.
+func CompressionOf(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".gz":
+		return CompressionGzip
+	case ".zip":
+		return CompressionZip
+	}
+	return ""
+}
+
+/*
+fileEntry is a CSV content of an upload: the uploaded file itself,
+the file compressed with gzip, or a file within a zip archive.
+
+Compressed contents are decompressed while they are read: they are decompressed once,
+to a file of their own read by their FilePart, when the upload is split.
+*/
+type fileEntry struct {
+	Name string // Name of the file within the archive, empty unless the upload is a zip archive
+	open func() (io.ReadCloser, error)
+}
+
+// entries lists the CSV contents of the upload, checking the archive limits.
+func (j *FileMessage) entries() ([]fileEntry, error) {
+	switch j.Compression {
+	case CompressionGzip:
+		return []fileEntry{{open: j.openGzip}}, nil
+	case CompressionZip:
+		return j.zipEntries()
+	}
+
+	return []fileEntry{{open: func() (io.ReadCloser, error) {
+		return os.Open(j.FilePath)
+	}}}, nil
+}
+
+// openSource opens the first CSV content of the upload, which dialect and header are detected from.
+func (j *FileMessage) openSource() (io.ReadCloser, error) {
+	entries, err := j.entries()
+	if err != nil {
+		return nil, err
+	}
+	return entries[0].open()
+}
+
+// openGzip opens the uploaded file decompressed with gzip.
+func (j *FileMessage) openGzip() (io.ReadCloser, error) {
+	f, err := os.Open(j.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("invalid gzip file: %w", err)
+	}
+
+	return &entryReader{
+		Reader:  newRatioReader(gz, i.Size(), j.MaxRatio),
+		closers: []io.Closer{gz, f},
+	}, nil
+}
+
+/*
+zipEntries lists the CSV files of the zip archive.
+
+Directories, hidden files and files which are not CSV files are ignored.
+The archive is refused when it holds more than MaxEntries files, when the sizes declared by an entry exceed MaxRatio,
+or when the sizes declared by its CSV files exceed MaxSize.
+*/
+func (j *FileMessage) zipEntries() ([]fileEntry, error) {
+	r, err := zip.OpenReader(j.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip file: %w", err)
+	}
+	defer r.Close()
+
+	if j.MaxEntries > 0 && len(r.File) > j.MaxEntries {
+		return nil, fmt.Errorf("zip archive holds %d files: at most %d files are allowed", len(r.File), j.MaxEntries)
+	}
+
+	var entries []fileEntry
+	var size uint64
+	for index, f := range r.File {
+		name := path.Base(f.Name)
+		if f.FileInfo().IsDir() || strings.HasPrefix(name, ".") || strings.HasPrefix(f.Name, "__MACOSX/") || !strings.EqualFold(path.Ext(name), ".csv") {
+			continue
+		}
+
+		if j.MaxRatio > 0 && f.UncompressedSize64 > f.CompressedSize64*uint64(j.MaxRatio) {
+			return nil, fmt.Errorf("%w: entry %s expands more than %d times its compressed size", ErrCompressionRatio, f.Name, j.MaxRatio)
+		}
+		if size += f.UncompressedSize64; j.MaxSize > 0 && size > uint64(j.MaxSize) {
+			return nil, fmt.Errorf("%w: files expand to more than %d bytes", ErrUncompressedSize, j.MaxSize)
+		}
+
+		index := index
+		entries = append(entries, fileEntry{
+			Name: f.Name,
+			open: func() (io.ReadCloser, error) {
+				return j.openZipEntry(index)
+			},
+		})
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("zip archive holds no .csv file")
+	}
+	return entries, nil
+}
+
+// openZipEntry opens a file of the zip archive, decompressed while it is read.
+func (j *FileMessage) openZipEntry(index int) (io.ReadCloser, error) {
+	r, err := zip.OpenReader(j.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	f := r.File[index]
+	rc, err := f.Open()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return &entryReader{
+		Reader:  newRatioReader(rc, int64(f.CompressedSize64), j.MaxRatio),
+		closers: []io.Closer{rc, r},
+	}, nil
+}
+
+// entryReader reads a decompressed content and closes its underlying readers.
+type entryReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (e *entryReader) Close() error {
+	var err error
+	for _, c := range e.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+/*
+ratioReader stops reading a decompressed content once it exceeds
+ratio times its compressed size, against decompression bombs.
+
+Sizes declared by archives are not trusted: the limit applies to the bytes actually read.
+*/
+type ratioReader struct {
+	reader io.Reader
+	ratio  int
+	limit  int64
+	read   int64
+}
+
+// newRatioReader limits the reader to ratio times the compressed size, without limit for a ratio of zero.
+func newRatioReader(r io.Reader, compressed int64, ratio int) io.Reader {
+	if ratio <= 0 {
+		return r
+	}
+	return &ratioReader{reader: r, ratio: ratio, limit: compressed * int64(ratio)}
+}
+
+func (r *ratioReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	if r.read > r.limit {
+		return n, fmt.Errorf("%w: content expands more than %d times its compressed size", ErrCompressionRatio, r.ratio)
+	}
+	return n, err
+}
+
+/*
+spoolEntry decompresses a CSV content of the upload to path, while its header is read and its records are counted,
+so that the FilePart of the content reads it without decompressing it again.
+The raw bytes are kept: the content is transcoded while it is read, like uploaded files.
+
+The content is refused once more than limit bytes have been decompressed, without limit when zero.
+It returns the header, the number of data records and the size of the decompressed content.
+*/
+func spoolEntry(entry fileEntry, path string, encoding string, dialect Dialect, limit int64) ([]string, int, int64, error) {
+	src, err := entry.open()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer dst.Close()
+
+	var r io.Reader = src
+	if limit > 0 {
+		r = io.LimitReader(src, limit+1)
+	}
+	tee := io.TeeReader(r, dst)
+
+	header, rows, scanErr := scanRecords(NewDecodedReader(tee, encoding), dialect)
+	if _, err := io.Copy(io.Discard, tee); err != nil && scanErr == nil {
+		scanErr = err
+	}
+
+	i, err := dst.Stat()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if limit > 0 && i.Size() > limit {
+		return nil, 0, 0, fmt.Errorf("%w: files expand to more than %d bytes", ErrUncompressedSize, limit)
+	}
+	if scanErr != nil {
+		return nil, 0, 0, scanErr
+	}
+	return header, rows, i.Size(), nil
+}
@@ -0,0 +1,141 @@
+package phonebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const archiveSample = "Phone;Lastname;Firstname\n0701020300;Doe;John\n0701020301;Smith;Jane\n"
+
+// writeZip creates a zip archive holding the files, in order.
+func writeZip(t *testing.T, path string, names []string, contents []string) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i, name := range names {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+		_, err = f.Write([]byte(contents[i]))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+}
+
+func TestSplitFile_Gzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.csv.gz")
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(archiveSample))
+	gz.Close()
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxRows: 1, MaxRatio: 100, Compression: CompressionOf(path)}
+	dialect, err := file.ResolveDialect()
+	assert.NoError(t, err)
+	assert.Equal(t, ';', dialect.Delimiter)
+
+	header, err := file.ReadHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Phone", "Lastname", "Firstname"}, header)
+
+	// Compressed contents are streamed as a single FilePart
+	parts, total, err := (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, parts, 1)
+
+	rows, lines := readPart(t, parts[0], dialect)
+	assert.Equal(t, [][]string{{"0701020300", "Doe", "John"}, {"0701020301", "Smith", "Jane"}}, rows)
+	assert.Equal(t, []int{2, 3}, lines)
+}
+
+func TestSplitFile_Zip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.zip")
+	writeZip(t, path,
+		[]string{"export/part1.csv", "export/", "__MACOSX/export/._part1.csv", "README.txt", "export/part2.CSV"},
+		[]string{archiveSample, "", "junk", "junk", "Nom;Prénom;Téléphone\nMartin;Paul;0701020302\n"},
+	)
+
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxRatio: 100, MaxEntries: 10, Compression: CompressionZip}
+	dialect, err := file.ResolveDialect()
+	assert.NoError(t, err)
+
+	parts, total, err := (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, parts, 2)
+	assert.Equal(t, "export/part1.csv", parts[0].Entry)
+	assert.Equal(t, 2, parts[0].Rows)
+	assert.Equal(t, "export/part2.CSV", parts[1].Entry)
+	assert.Equal(t, 1, parts[1].Rows)
+
+	// Each file has its own header
+	rows, _ := readPart(t, parts[1], dialect)
+	assert.Equal(t, "0701020302", parts[1].mapping.Value(rows[0], FieldPhone))
+	assert.Equal(t, "Paul", parts[1].mapping.Value(rows[0], FieldFirstname))
+
+	// Files are decompressed once, and removed with the upload
+	assert.Equal(t, file.entriesDir(), filepath.Dir(parts[0].FilePath))
+	assert.Equal(t, int64(len(archiveSample)), parts[0].Size)
+	assert.NoError(t, file.Remove())
+	_, err = os.Stat(file.entriesDir())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestZipEntries_Limits(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "many.zip")
+	writeZip(t, path, []string{"a.csv", "b.csv", "c.csv"}, []string{archiveSample, archiveSample, archiveSample})
+	_, err := (&FileMessage{FilePath: path, MaxEntries: 2, Compression: CompressionZip}).entries()
+	assert.ErrorContains(t, err, "at most 2 files")
+
+	path = filepath.Join(dir, "bomb.zip")
+	writeZip(t, path, []string{"bomb.csv"}, []string{archiveSample + strings.Repeat("0701020300;Doe;John\n", 10000)})
+	_, err = (&FileMessage{FilePath: path, MaxRatio: 10, Compression: CompressionZip}).entries()
+	assert.True(t, errors.Is(err, ErrCompressionRatio))
+
+	path = filepath.Join(dir, "large.zip")
+	writeZip(t, path, []string{"a.csv", "b.csv"}, []string{archiveSample, archiveSample})
+	_, err = (&FileMessage{FilePath: path, MaxSize: int64(len(archiveSample)), Compression: CompressionZip}).entries()
+	assert.True(t, errors.Is(err, ErrUncompressedSize))
+}
+
+func TestSplitFile_MaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.csv.gz")
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(archiveSample))
+	gz.Close()
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+	// Sizes declared by archives are not trusted, decompressed contents are counted
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxSize: int64(len(archiveSample)) - 1, Compression: CompressionOf(path)}
+	_, _, err := (&ContactUploader{}).splitFile(file)
+	assert.True(t, errors.Is(err, ErrUncompressedSize))
+
+	file.MaxSize = int64(len(archiveSample))
+	_, total, err := (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+}
+
+func TestRatioReader(t *testing.T) {
+	content := strings.Repeat("a", 1000)
+
+	_, err := io.ReadAll(newRatioReader(strings.NewReader(content), 100, 10))
+	assert.NoError(t, err)
+
+	// Sizes declared by archives are not trusted
+	_, err = io.ReadAll(newRatioReader(strings.NewReader(content), 99, 10))
+	assert.True(t, errors.Is(err, ErrCompressionRatio))
+}
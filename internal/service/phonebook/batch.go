@@ -14,6 +14,7 @@ type BatchHandler interface {
 // Batch represents the current batch of data to insert. It implements the BatchHandler interface.
 type Batch struct {
 	Contacts []*model.Contact // Current rows ready to be batch inserted
+	Reads    []int            // Number of rows read from the FilePart once each contact was read, rejected rows included
 	Length   uint             // Number of Contacts rows
 }
 
@@ -24,16 +25,20 @@ func NewBatch() *Batch {
 
 func (b *Batch) Reset() {
 	b.Contacts = []*model.Contact{}
+	b.Reads = []int{}
 	b.Length = 0
 	logger.Trace("Batch reset")
 }
 
-func (b *Batch) Append(c *model.Contact) {
+// Append adds a contact read as the read-th row of its FilePart.
+func (b *Batch) Append(c *model.Contact, read int) {
 	b.Contacts = append(b.Contacts, c)
+	b.Reads = append(b.Reads, read)
 	b.Length++
 	logger.Trace("Contact appended to batch")
 }
 
+// IsReached tells whether the batch holds maxBatch contacts or more, as the batch size may shrink while it is filled.
 func (b *Batch) IsReached(maxBatch uint) bool {
-	return b.Length == maxBatch
+	return b.Length >= maxBatch
 }
@@ -0,0 +1,120 @@
+package phonebook
+
+import (
+	"fmt"
+	"go-csv-import/internal/model"
+	"go-csv-import/internal/repository"
+	"sync"
+	"time"
+)
+
+// contactOverhead is the estimated size of the columns of a contact insert which are not read from the file:
+// timestamps, identifiers, quotes and separators.
+const contactOverhead = 120
+
+// maxBatchAttempts is the number of lock wait timeouts or deadlocks after which a batch fails.
+const maxBatchAttempts = 4
+
+// batchRetryDelay is the delay before writing again the contacts of a batch failing on locks, multiplied by the attempt.
+const batchRetryDelay = 200 * time.Millisecond
+
+/*
+BatchSizer adjusts the number of contacts inserted by query to the insert latency of an import.
+
+The size grows by a quarter while full batches are inserted within half the target latency,
+and is halved when a batch is slower than the target or waits for locks.
+It is always kept between Min and Max, and under max_allowed_packet for the largest contact written so far.
+It is safe for concurrent use by the goroutines processing the FileParts of the import.
+*/
+type BatchSizer struct {
+	Min    uint          // Min number of contacts by query
+	Max    uint          // Max number of contacts by query
+	Target time.Duration // Target duration of the query of a batch
+
+	mu        sync.Mutex
+	size      uint  // Current number of contacts by query, before the packet limit
+	maxPacket int64 // Size of the largest statement accepted by the server, 0 when unknown
+	rowSize   int   // Estimated size of the largest contact inserted
+}
+
+// NewBatchSizer creates a BatchSizer starting with the initial size.
+func NewBatchSizer(initial uint, min uint, max uint, target time.Duration) *BatchSizer {
+	return &BatchSizer{Min: min, Max: max, Target: target, size: initial}
+}
+
+// SetMaxPacket sets the size in bytes of the largest statement accepted by the server.
+func (s *BatchSizer) SetMaxPacket(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxPacket = size
+}
+
+// Size returns the number of contacts of the next batches.
+func (s *BatchSizer) Size() uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit(s.size)
+}
+
+// Estimate records the size of contacts about to be written, and returns the number of contacts of the next batches,
+// so that the first batches of an import also stay under max_allowed_packet.
+func (s *BatchSizer) Estimate(contacts []*model.Contact) uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range contacts {
+		s.rowSize = max(s.rowSize, estimateContactSize(c))
+	}
+	return s.limit(s.size)
+}
+
+/*
+Observe adjusts the size to the duration of the query which wrote the contacts of a batch,
+and returns the new size with whether it changed.
+
+Batches smaller than the size, written at the end of a FilePart, never make it grow.
+*/
+func (s *BatchSizer) Observe(contacts []*model.Contact, elapsed time.Duration, err error) (uint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.limit(s.size)
+	for _, c := range contacts {
+		s.rowSize = max(s.rowSize, estimateContactSize(c))
+	}
+
+	switch {
+	case repository.IsLockError(err) || elapsed > s.Target:
+		s.size = max(s.size/2, s.Min)
+	case err == nil && elapsed < s.Target/2 && uint(len(contacts)) >= previous:
+		s.size = min(s.size+max(s.size/4, 1), s.Max)
+	}
+
+	size := s.limit(s.size)
+	return size, size != previous
+}
+
+// limit returns the size capped so that the statement of a batch stays under max_allowed_packet.
+// The packet limit prevails over Min, as larger statements are refused by the server.
+func (s *BatchSizer) limit(size uint) uint {
+	if s.maxPacket <= 0 || s.rowSize == 0 {
+		return size
+	}
+
+	// A quarter of the packet is left to the statement text and the escaping of values
+	rows := s.maxPacket * 3 / 4 / int64(s.rowSize)
+	return max(min(size, uint(rows)), 1)
+}
+
+// estimateContactSize returns the estimated size in bytes of the values of a contact within an insert statement.
+func estimateContactSize(c *model.Contact) int {
+	size := contactOverhead + len(c.ReqId) + len(c.Phonebook) + len(c.Phone) + len(c.PhoneE164) +
+		len(c.Firstname) + len(c.Lastname) + len(c.Email) + len(c.Company) + len(c.Title)
+	if c.NaturalKey != nil {
+		size += len(*c.NaturalKey)
+	}
+	for key, value := range c.Attributes {
+		size += len(key) + len(fmt.Sprint(value)) + 6 // JSON quotes, colon and comma
+	}
+	return size
+}
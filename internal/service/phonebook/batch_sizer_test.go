@@ -0,0 +1,70 @@
+package phonebook
+
+import (
+	"errors"
+	"go-csv-import/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchSizer_Observe(t *testing.T) {
+	sizer := NewBatchSizer(1000, 100, 1500, 500*time.Millisecond)
+	full := make([]*model.Contact, 1000)
+	for i := range full {
+		full[i] = &model.Contact{Phone: "0701020300"}
+	}
+
+	// Fast full batches grow up to Max
+	size, changed := sizer.Observe(full, 100*time.Millisecond, nil)
+	assert.True(t, changed)
+	assert.Equal(t, uint(1250), size)
+	sizer.Observe(append(full, full[:250]...), 100*time.Millisecond, nil)
+	assert.Equal(t, uint(1500), sizer.Size())
+
+	// Partial batches and latencies close to the target leave the size unchanged
+	_, changed = sizer.Observe(full[:10], 100*time.Millisecond, nil)
+	assert.False(t, changed)
+	_, changed = sizer.Observe(full, 400*time.Millisecond, nil)
+	assert.False(t, changed)
+
+	// Slow statements and lock waits shrink down to Min
+	size, _ = sizer.Observe(full, time.Second, nil)
+	assert.Equal(t, uint(750), size)
+	size, _ = sizer.Observe(full[:1], time.Millisecond, &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"})
+	assert.Equal(t, uint(375), size)
+	sizer.Observe(full, time.Second, nil)
+	sizer.Observe(full, time.Second, nil)
+	assert.Equal(t, uint(100), sizer.Size())
+
+	// Other errors are not related to the size
+	_, changed = sizer.Observe(full, time.Millisecond, errors.New("duplicate entry"))
+	assert.False(t, changed)
+}
+
+func TestBatchSizer_MaxPacket(t *testing.T) {
+	sizer := NewBatchSizer(3000, 1000, 5000, time.Second)
+	sizer.SetMaxPacket(1 << 20)
+	assert.Equal(t, uint(3000), sizer.Size())
+
+	// Large contacts cap the size below Min, so that statements are accepted by the server
+	large := &model.Contact{Phone: "0701020300", Attributes: map[string]any{"notes": strings.Repeat("x", 2000)}}
+	size, changed := sizer.Observe([]*model.Contact{large}, time.Millisecond, nil)
+	assert.True(t, changed)
+	assert.Equal(t, uint(1<<20*3/4/estimateContactSize(large)), size)
+	assert.Less(t, size, uint(1000))
+}
+
+func TestBatchSizer_Estimate(t *testing.T) {
+	sizer := NewBatchSizer(3000, 1000, 5000, time.Second)
+	sizer.SetMaxPacket(1 << 20)
+
+	// The first batch is capped before being written
+	large := &model.Contact{Phone: "0701020300", Attributes: map[string]any{"notes": strings.Repeat("x", 2000)}}
+	size := sizer.Estimate([]*model.Contact{{Phone: "0701020300"}, large})
+	assert.Equal(t, uint(1<<20*3/4/estimateContactSize(large)), size)
+	assert.Equal(t, size, sizer.Size())
+}
@@ -5,6 +5,9 @@ import (
 	"go-csv-import/internal/config"
 	"go-csv-import/internal/handlers/worker"
 	"go-csv-import/internal/logger"
+	"sync"
+
+	rabbit "github.com/streadway/amqp"
 )
 
 // NewPhonebookConsumer creates a new instance of PhonebookHandler for consuming messages from the AMQP queue.
@@ -18,11 +21,29 @@ func NewPhonebookConsumer(a *config.ApmqConfig, h *config.HttpConfig, d *config.
 	return self
 }
 
-// Consume listens for messages from the AMQP queue and processes them.
+/*
+Consume listens for messages from the AMQP queue and processes them.
+
+Up to WorkerImports messages are processed at once, their FileParts sharing the worker pool.
+*/
 func (p *PhonebookHandler) Consume(ctx context.Context) {
 	msgHandler := p.NewMessageHandler()
+	deliveries := p.Queue.Consume(false)
+
+	var wg sync.WaitGroup
+	for i := uint(0); i < p.HttpConfig.WorkerImports; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.consumeDeliveries(ctx, msgHandler, deliveries)
+		}()
+	}
+	wg.Wait()
+}
 
-	for msg := range p.Queue.Consume(false) {
+// consumeDeliveries processes the messages of the AMQP queue one after the other, until the worker is cancelled.
+func (p *PhonebookHandler) consumeDeliveries(ctx context.Context, msgHandler *MessageHandler, deliveries <-chan rabbit.Delivery) {
+	for msg := range deliveries {
 		ctxT, cancel := context.WithTimeout(ctx, p.AmqpConfig.Lifetime)
 		defer cancel()
 		logger.Trace("Message received from queue", "type", msg.Type, "message", msg.Body)
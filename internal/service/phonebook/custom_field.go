@@ -0,0 +1,189 @@
+package phonebook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-csv-import/internal/model"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Types of custom fields.
+const (
+	FieldTypeString  = "string"
+	FieldTypeInteger = "integer"
+	FieldTypeDecimal = "decimal"
+	FieldTypeDate    = "date"
+	FieldTypeBoolean = "boolean"
+	FieldTypeEnum    = "enum"
+)
+
+// defaultDateFormat is the format of date fields declared without format.
+const defaultDateFormat = "YYYY-MM-DD"
+
+// fieldNameMaxLength is the maximum length of a custom field name.
+const fieldNameMaxLength = 100
+
+// decimalPattern matches decimal values, once their decimal comma is replaced by a dot.
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+
+// dateTokens converts the tokens of date formats to the layout of the time package.
+var dateTokens = strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02", "HH", "15", "mm", "04", "ss", "05")
+
+/*
+ParseFieldDefinition decodes and validates the JSON definition of a custom field of a phonebook.
+
+	{"type": "date", "format": "DD/MM/YYYY", "required": true}
+	{"type": "enum", "values": ["gold", "silver"], "max_length": 10}
+*/
+func ParseFieldDefinition(phonebook string, name string, raw []byte) (*model.FieldDefinition, error) {
+	field := &model.FieldDefinition{}
+	if err := json.Unmarshal(raw, field); err != nil {
+		return nil, fmt.Errorf("invalid field definition: %w", err)
+	}
+	field.Phonebook = phonebook
+	field.Name = name
+
+	if err := ValidateFieldDefinition(field); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// ValidateFieldDefinition checks that the values of a custom field can be converted, and sets the default date format.
+func ValidateFieldDefinition(field *model.FieldDefinition) error {
+	if name := strings.TrimSpace(field.Name); name == "" || utf8.RuneCountInString(name) > fieldNameMaxLength {
+		return fmt.Errorf("invalid field definition: name must have 1 to %d characters", fieldNameMaxLength)
+	}
+	if ContactField(field.Name).IsValid() {
+		return fmt.Errorf("invalid field definition: <%s> is a contact field", field.Name)
+	}
+	if field.MaxLength < 0 {
+		return errors.New("invalid field definition: max_length must not be negative")
+	}
+
+	switch field.Type {
+	case FieldTypeString, FieldTypeInteger, FieldTypeDecimal, FieldTypeBoolean:
+	case FieldTypeDate:
+		if field.Format == "" {
+			field.Format = defaultDateFormat
+		}
+		if _, err := dateLayout(field.Format); err != nil {
+			return fmt.Errorf("invalid field definition: %w", err)
+		}
+	case FieldTypeEnum:
+		if len(field.Values) == 0 {
+			return errors.New("invalid field definition: enum fields require allowed values")
+		}
+		seen := make(map[string]bool)
+		for _, v := range field.Values {
+			if strings.TrimSpace(v) == "" || seen[strings.ToLower(v)] {
+				return fmt.Errorf("invalid field definition: allowed value <%s> is empty or declared twice", v)
+			}
+			seen[strings.ToLower(v)] = true
+		}
+	default:
+		return fmt.Errorf("invalid field definition: unknown type <%s>: expected %s, %s, %s, %s, %s or %s", field.Type,
+			FieldTypeString, FieldTypeInteger, FieldTypeDecimal, FieldTypeDate, FieldTypeBoolean, FieldTypeEnum)
+	}
+
+	if field.Format != "" && field.Type != FieldTypeDate {
+		return errors.New("invalid field definition: format only applies to date fields")
+	}
+	if len(field.Values) > 0 && field.Type != FieldTypeEnum {
+		return errors.New("invalid field definition: values only apply to enum fields")
+	}
+	return nil
+}
+
+// dateLayout converts a date format such as DD/MM/YYYY to the layout of the time package.
+func dateLayout(format string) (string, error) {
+	layout := dateTokens.Replace(format)
+	if !strings.Contains(layout, "06") || !strings.Contains(layout, "01") || !strings.Contains(layout, "02") {
+		return "", fmt.Errorf("date format <%s> requires a year (YYYY), a month (MM) and a day (DD)", format)
+	}
+	return layout, nil
+}
+
+/*
+convertField converts a value following the definition of its custom field.
+
+Values are stored with their JSON type: integers and decimals as numbers, booleans as booleans,
+dates as ISO 8601 strings and enum values as declared. Empty values are omitted, unless the field is required.
+*/
+func convertField(field *model.FieldDefinition, value string) (any, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if field.Required {
+			return nil, &FieldError{Field: field.Name, Code: FieldErrorRequired, Err: errors.New("value is required")}
+		}
+		return nil, nil
+	}
+
+	if field.MaxLength > 0 && utf8.RuneCountInString(value) > field.MaxLength {
+		return nil, &FieldError{Field: field.Name, Code: FieldErrorMaxLength, Value: value,
+			Err: fmt.Errorf("value exceeds %d characters", field.MaxLength)}
+	}
+
+	typeError := func(expected string) error {
+		return &FieldError{Field: field.Name, Code: FieldErrorType, Value: value,
+			Err: fmt.Errorf("expected %s, got <%s>", expected, value)}
+	}
+
+	switch field.Type {
+	case FieldTypeInteger:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, typeError("an integer")
+		}
+		return n, nil
+
+	case FieldTypeDecimal:
+		d := value
+		if !strings.Contains(d, ".") && strings.Count(d, ",") == 1 {
+			d = strings.Replace(d, ",", ".", 1) // Decimal comma
+		}
+		if !decimalPattern.MatchString(d) {
+			return nil, typeError("a decimal")
+		}
+		// Numbers are kept as written, without floating point rounding
+		return json.Number(strings.TrimPrefix(d, "+")), nil
+
+	case FieldTypeDate:
+		layout, err := dateLayout(field.Format)
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return nil, typeError("a date formatted as " + field.Format)
+		}
+		if strings.Contains(layout, "15") {
+			return t.Format("2006-01-02T15:04:05"), nil
+		}
+		return t.Format("2006-01-02"), nil
+
+	case FieldTypeBoolean:
+		switch strings.ToLower(value) {
+		case "true", "1", "yes", "y", "oui", "o":
+			return true, nil
+		case "false", "0", "no", "n", "non":
+			return false, nil
+		}
+		return nil, typeError("a boolean")
+
+	case FieldTypeEnum:
+		for _, allowed := range field.Values {
+			if strings.EqualFold(value, allowed) {
+				return allowed, nil
+			}
+		}
+		return nil, &FieldError{Field: field.Name, Code: FieldErrorEnum, Value: value,
+			Err: fmt.Errorf("expected one of <%s>, got <%s>", strings.Join(field.Values, ">, <"), value)}
+	}
+	return value, nil
+}
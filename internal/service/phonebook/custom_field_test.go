@@ -0,0 +1,108 @@
+package phonebook
+
+import (
+	"encoding/json"
+	"errors"
+	"go-csv-import/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFieldDefinition(t *testing.T) {
+	field, err := ParseFieldDefinition("vip", "Birthday", []byte(`{"type": "date"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "vip", field.Phonebook)
+	assert.Equal(t, "Birthday", field.Name)
+	assert.Equal(t, defaultDateFormat, field.Format)
+
+	field, err = ParseFieldDefinition("", "Tier", []byte(`{"type": "enum", "values": ["gold", "silver"], "required": true}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gold", "silver"}, field.Values)
+	assert.True(t, field.Required)
+
+	for _, raw := range []string{
+		`{"type": "text"}`,
+		`{"type": "enum"}`,
+		`{"type": "enum", "values": ["gold", "Gold"]}`,
+		`{"type": "date", "format": "MM/YYYY"}`,
+		`{"type": "integer", "format": "DD/MM/YYYY"}`,
+		`{"type": "string", "values": ["a"]}`,
+		`{"type": "string", "max_length": -1}`,
+		`{"type": `,
+	} {
+		_, err := ParseFieldDefinition("", "Tier", []byte(raw))
+		assert.Error(t, err, raw)
+	}
+
+	_, err = ParseFieldDefinition("", "email", []byte(`{"type": "string"}`))
+	assert.EqualError(t, err, "invalid field definition: <email> is a contact field")
+}
+
+func TestConvertField(t *testing.T) {
+	tests := []struct {
+		field    model.FieldDefinition
+		value    string
+		expected any
+		code     string
+	}{
+		{model.FieldDefinition{Type: FieldTypeString}, " Paris ", "Paris", ""},
+		{model.FieldDefinition{Type: FieldTypeString, MaxLength: 3}, "Paris", nil, FieldErrorMaxLength},
+		{model.FieldDefinition{Type: FieldTypeString}, "", nil, ""},
+		{model.FieldDefinition{Type: FieldTypeString, Required: true}, " ", nil, FieldErrorRequired},
+		{model.FieldDefinition{Type: FieldTypeInteger}, "-42", int64(-42), ""},
+		{model.FieldDefinition{Type: FieldTypeInteger}, "4.2", nil, FieldErrorType},
+		{model.FieldDefinition{Type: FieldTypeDecimal}, "12,50", json.Number("12.50"), ""},
+		{model.FieldDefinition{Type: FieldTypeDecimal}, "+0.125", json.Number("0.125"), ""},
+		{model.FieldDefinition{Type: FieldTypeDecimal}, "1,000.5", nil, FieldErrorType},
+		{model.FieldDefinition{Type: FieldTypeDate, Format: "DD/MM/YYYY"}, "31/12/1990", "1990-12-31", ""},
+		{model.FieldDefinition{Type: FieldTypeDate, Format: "YYYY-MM-DD HH:mm"}, "1990-12-31 08:30", "1990-12-31T08:30:00", ""},
+		{model.FieldDefinition{Type: FieldTypeDate, Format: "DD/MM/YYYY"}, "12/31/1990", nil, FieldErrorType},
+		{model.FieldDefinition{Type: FieldTypeBoolean}, "Oui", true, ""},
+		{model.FieldDefinition{Type: FieldTypeBoolean}, "0", false, ""},
+		{model.FieldDefinition{Type: FieldTypeBoolean}, "maybe", nil, FieldErrorType},
+		{model.FieldDefinition{Type: FieldTypeEnum, Values: []string{"Gold", "Silver"}}, "gold", "Gold", ""},
+		{model.FieldDefinition{Type: FieldTypeEnum, Values: []string{"Gold", "Silver"}}, "bronze", nil, FieldErrorEnum},
+	}
+
+	for _, test := range tests {
+		test.field.Name = "Field"
+		value, err := convertField(&test.field, test.value)
+		if test.code == "" {
+			assert.NoError(t, err, test.value)
+			assert.Equal(t, test.expected, value, test.value)
+			continue
+		}
+
+		var fieldErr *FieldError
+		if assert.True(t, errors.As(err, &fieldErr), test.value) {
+			assert.Equal(t, "Field", fieldErr.Field)
+			assert.Equal(t, test.code, fieldErr.Code, test.value)
+		}
+	}
+}
+
+func TestMapping_ResolveFields(t *testing.T) {
+	fields := []model.FieldDefinition{
+		{Name: "Customer Id", Type: FieldTypeInteger, Required: true},
+		{Name: "tier", Type: FieldTypeEnum, Values: []string{"gold", "silver"}},
+		{Name: "Birthday", Type: FieldTypeDate, Format: defaultDateFormat},
+	}
+	file := &FileMessage{Fields: fields}
+
+	m, err := file.ResolveMapping([]string{"Phone", "Firstname", "Lastname", "customer_id", "Tier", "Notes"})
+	assert.NoError(t, err)
+	assert.Equal(t, "customer_id", m.Describe()["Customer Id"])
+
+	attributes, err := m.Attributes([]string{"0701020300", "John", "Doe", "42", "Gold", "call back"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"Customer Id": int64(42), "tier": "gold", "Notes": "call back"}, attributes)
+
+	_, err = m.Attributes([]string{"0701020300", "John", "Doe", "", "gold", ""})
+	var fieldErr *FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, FieldErrorRequired, fieldErr.Code)
+
+	_, err = file.ResolveMapping([]string{"Phone", "Firstname", "Lastname", "Tier"})
+	assert.EqualError(t, err, "columns <Customer Id> is missing")
+}
@@ -0,0 +1,373 @@
+package phonebook
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// dialectSampleSize is the number of bytes read from the start of a file to sniff its dialect.
+const dialectSampleSize = 16 << 10
+
+// delimiterCandidates lists the delimiters tried when sniffing a file, by order of preference.
+var delimiterCandidates = []rune{';', ',', '\t', '|'}
+
+// Dialect describes how a CSV file is formatted.
+type Dialect struct {
+	Delimiter        rune `json:"delimiter"`          // Field delimiter
+	Quote            rune `json:"quote"`              // Quote character, must be a single-byte character
+	Comment          rune `json:"comment,omitempty"`  // Lines beginning with this character are ignored
+	LazyQuotes       bool `json:"lazy_quotes"`        // Allow quotes within unquoted fields
+	TrimLeadingSpace bool `json:"trim_leading_space"` // Ignore leading white space of fields
+	HasHeader        bool `json:"has_header"`         // First record holds column headers
+}
+
+/*
+DialectOptions are the dialect settings explicitly requested for an upload.
+
+Empty or nil values are left to the dialect detection.
+*/
+type DialectOptions struct {
+	Delimiter        string `json:"delimiter,omitempty"`
+	Quote            string `json:"quote,omitempty"`
+	Comment          string `json:"comment,omitempty"`
+	LazyQuotes       *bool  `json:"lazy_quotes,omitempty"`
+	TrimLeadingSpace *bool  `json:"trim_leading_space,omitempty"`
+	HasHeader        *bool  `json:"has_header,omitempty"`
+}
+
+// DefaultDialect returns the historical dialect of the uploaded files: semicolon separated with a header.
+func DefaultDialect() Dialect {
+	return Dialect{Delimiter: ';', Quote: '"', HasHeader: true}
+}
+
+/*
+ParseDialectOptions validates the dialect settings sent along an upload.
+
+Delimiters may be given literally or by name: "comma", "semicolon", "tab", "pipe" or "\t".
+*/
+func ParseDialectOptions(delimiter, quote, comment, lazyQuotes, trimLeadingSpace, hasHeader string) (DialectOptions, error) {
+	o := DialectOptions{}
+	var err error
+
+	if o.Delimiter, err = parseDialectChar("delimiter", delimiter); err != nil {
+		return o, err
+	}
+	if o.Quote, err = parseDialectChar("quote", quote); err != nil {
+		return o, err
+	}
+	if o.Comment, err = parseDialectChar("comment", comment); err != nil {
+		return o, err
+	}
+	if o.LazyQuotes, err = parseDialectBool("lazy_quotes", lazyQuotes); err != nil {
+		return o, err
+	}
+	if o.TrimLeadingSpace, err = parseDialectBool("trim_leading_space", trimLeadingSpace); err != nil {
+		return o, err
+	}
+	if o.HasHeader, err = parseDialectBool("has_header", hasHeader); err != nil {
+		return o, err
+	}
+
+	if o.Quote != "" && o.Quote[0] >= utf8.RuneSelf {
+		return o, fmt.Errorf("invalid dialect quote <%s>: must be a single-byte character", o.Quote)
+	}
+
+	return o, nil
+}
+
+/*
+DetectDialect sniffs the dialect of a CSV content from its first bytes, once transcoded to UTF-8.
+
+The delimiter giving the most consistent number of columns wins, the quote
+character is the one found around fields, and the first record is considered
+as a header unless it looks like data. Explicit options override the result.
+*/
+func DetectDialect(r io.Reader, options DialectOptions) (Dialect, error) {
+	sample := make([]byte, dialectSampleSize)
+	n, err := io.ReadFull(r, sample)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Dialect{}, err
+	}
+
+	d := SniffDialect(sample[:n], n < dialectSampleSize)
+	return d.apply(options), nil
+}
+
+/*
+SniffDialect guesses the dialect of a CSV sample.
+
+When complete is false, the last line of the sample is considered as truncated and is ignored.
+*/
+func SniffDialect(sample []byte, complete bool) Dialect {
+	d := DefaultDialect()
+
+	if !complete {
+		if i := bytes.LastIndexByte(sample, '\n'); i > 0 {
+			sample = sample[:i+1]
+		}
+	}
+	if len(bytes.TrimSpace(sample)) == 0 {
+		return d
+	}
+
+	d.Quote = sniffQuote(sample)
+	d.Delimiter = bestDelimiter(sample, d)
+
+	if records := sampleRecords(sample, d, 1); len(records) > 0 {
+		d.HasHeader = !looksLikeData(records[0])
+	}
+
+	return d
+}
+
+// bestDelimiter returns the candidate delimiter splitting the sample into the most consistent records.
+func bestDelimiter(sample []byte, d Dialect) rune {
+	best, bestScore := delimiterCandidates[0], 0
+	for _, delimiter := range delimiterCandidates {
+		d.Delimiter = delimiter
+		if score := dialectScore(sample, d); score > bestScore {
+			best, bestScore = delimiter, score
+		}
+	}
+	return best
+}
+
+/*
+dialectScore rates how well a dialect splits the sample.
+
+Records must all have the same number of fields, and more than one,
+so the score is the number of fields multiplied by the number of records.
+*/
+func dialectScore(sample []byte, d Dialect) int {
+	records := sampleRecords(sample, d, 0)
+	if len(records) == 0 {
+		return 0
+	}
+
+	fields := len(records[0])
+	if fields < 2 {
+		return 0
+	}
+	for _, r := range records[1:] {
+		if len(r) != fields {
+			return 0
+		}
+	}
+	return fields * len(records)
+}
+
+// sampleRecords parses at most max records (all when max is zero) of the sample with the given dialect.
+func sampleRecords(sample []byte, d Dialect, max int) [][]string {
+	reader := d.NewReader(bytes.NewReader(sample))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var records [][]string
+	for max == 0 || len(records) < max {
+		record, err := reader.Read()
+		if err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// sniffQuote returns the quote character enclosing fields of the sample, double quote by default.
+func sniffQuote(sample []byte) rune {
+	count := func(q byte) int {
+		n := 0
+		for i, b := range sample {
+			if b != q {
+				continue
+			}
+			if i == 0 || isFieldBoundary(sample[i-1]) || i == len(sample)-1 || isFieldBoundary(sample[i+1]) {
+				n++
+			}
+		}
+		return n
+	}
+
+	if single, double := count('\''), count('"'); single > double && single%2 == 0 {
+		return '\''
+	}
+	return '"'
+}
+
+func isFieldBoundary(b byte) bool {
+	for _, d := range delimiterCandidates {
+		if rune(b) == d {
+			return true
+		}
+	}
+	return b == '\n' || b == '\r'
+}
+
+// looksLikeData checks if a record holds a numeric value, which headers are not expected to contain.
+func looksLikeData(record []string) bool {
+	for _, v := range record {
+		v = strings.TrimSpace(v)
+		v = strings.NewReplacer(" ", "", "+", "", "-", "", ".", "", "(", "", ")", "").Replace(v)
+		if v == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(v, 10, 64); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// apply overrides the sniffed dialect with explicit options.
+func (d Dialect) apply(o DialectOptions) Dialect {
+	if o.Delimiter != "" {
+		d.Delimiter, _ = utf8.DecodeRuneInString(o.Delimiter)
+	}
+	if o.Quote != "" {
+		d.Quote, _ = utf8.DecodeRuneInString(o.Quote)
+	}
+	if o.Comment != "" {
+		d.Comment, _ = utf8.DecodeRuneInString(o.Comment)
+	}
+	if o.LazyQuotes != nil {
+		d.LazyQuotes = *o.LazyQuotes
+	}
+	if o.TrimLeadingSpace != nil {
+		d.TrimLeadingSpace = *o.TrimLeadingSpace
+	}
+	if o.HasHeader != nil {
+		d.HasHeader = *o.HasHeader
+	}
+	return d
+}
+
+/*
+NewReader creates a CSV reader configured with the dialect.
+
+The standard reader only knows double quotes, so other quote characters are swapped
+with double quotes on the fly. Values read must be restored with Restore.
+*/
+func (d Dialect) NewReader(r io.Reader) *csv.Reader {
+	if d.Quote != 0 && d.Quote != '"' {
+		r = &quoteSwapReader{r: r, quote: byte(d.Quote)}
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = d.Delimiter
+	reader.Comment = d.Comment
+	reader.LazyQuotes = d.LazyQuotes
+	reader.TrimLeadingSpace = d.TrimLeadingSpace
+	return reader
+}
+
+// Restore swaps back the quote characters of a record read with a custom quote character.
+func (d Dialect) Restore(record []string) []string {
+	if d.Quote == 0 || d.Quote == '"' {
+		return record
+	}
+	for i, v := range record {
+		record[i] = string(swapQuotes([]byte(v), byte(d.Quote)))
+	}
+	return record
+}
+
+// Validate checks that the dialect can be used by the CSV reader.
+func (d Dialect) Validate() error {
+	if d.Delimiter == 0 || d.Delimiter == '\r' || d.Delimiter == '\n' || d.Delimiter == utf8.RuneError {
+		return fmt.Errorf("invalid dialect delimiter <%q>", d.Delimiter)
+	}
+	if d.Quote == d.Delimiter || d.Comment == d.Delimiter {
+		return errors.New("invalid dialect: delimiter, quote and comment must differ")
+	}
+	if d.Quote >= utf8.RuneSelf {
+		return fmt.Errorf("invalid dialect quote <%q>: must be a single-byte character", d.Quote)
+	}
+	return nil
+}
+
+// Describe returns a human readable version of the dialect.
+func (d Dialect) Describe() map[string]any {
+	desc := map[string]any{
+		"delimiter":          string(d.Delimiter),
+		"quote":              string(d.Quote),
+		"lazy_quotes":        d.LazyQuotes,
+		"trim_leading_space": d.TrimLeadingSpace,
+		"has_header":         d.HasHeader,
+	}
+	if d.Comment != 0 {
+		desc["comment"] = string(d.Comment)
+	}
+	return desc
+}
+
+// quoteSwapReader swaps a custom quote character with double quotes.
+type quoteSwapReader struct {
+	r     io.Reader
+	quote byte
+}
+
+func (q *quoteSwapReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	swapQuotes(p[:n], q.quote)
+	return n, err
+}
+
+func swapQuotes(b []byte, quote byte) []byte {
+	for i, c := range b {
+		switch c {
+		case quote:
+			b[i] = '"'
+		case '"':
+			b[i] = quote
+		}
+	}
+	return b
+}
+
+func parseDialectChar(name, value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return "", nil
+	case "comma":
+		return ",", nil
+	case "semicolon":
+		return ";", nil
+	case "tab", `\t`:
+		return "\t", nil
+	case "pipe":
+		return "|", nil
+	case "space":
+		return " ", nil
+	}
+
+	if utf8.RuneCountInString(value) != 1 {
+		return "", fmt.Errorf("invalid dialect %s <%s>: expected a single character", name, value)
+	}
+	return value, nil
+}
+
+func parseDialectBool(name, value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dialect %s <%s>: expected a boolean", name, value)
+	}
+	return &b, nil
+}
+
+// syntheticHeader names the columns of a file without header: "column_1", "column_2", ...
+func syntheticHeader(columns int) []string {
+	header := make([]string, columns)
+	for i := range header {
+		header[i] = fmt.Sprintf("column_%d", i+1)
+	}
+	return header
+}
@@ -0,0 +1,57 @@
+package phonebook
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSniffDialect_Delimiters(t *testing.T) {
+	cases := map[rune]string{
+		';':  "Phone;Lastname;Firstname\n0701020300;Doe;John\n0701020301;Smith;Jane\n",
+		',':  "Phone,Lastname,Firstname\n0701020300,Doe,John\n0701020301,\"Smith, Jr\",Jane\n",
+		'\t': "Phone\tLastname\tFirstname\n0701020300\tDoe\tJohn\n",
+		'|':  "Phone|Lastname|Firstname\n0701020300|Doe|John\n",
+	}
+
+	for delimiter, sample := range cases {
+		d := SniffDialect([]byte(sample), true)
+		assert.Equal(t, string(delimiter), string(d.Delimiter))
+		assert.True(t, d.HasHeader)
+	}
+}
+
+func TestSniffDialect_WithoutHeader(t *testing.T) {
+	d := SniffDialect([]byte("0701020300,Doe,John\n0701020301,Smith,Jane\n"), true)
+	assert.Equal(t, ',', d.Delimiter)
+	assert.False(t, d.HasHeader)
+}
+
+func TestSniffDialect_SingleQuote(t *testing.T) {
+	d := SniffDialect([]byte("'Phone','Lastname','Firstname'\n'0701020300','O''Neil','John'\n"), true)
+	assert.Equal(t, '\'', d.Quote)
+	assert.Equal(t, ',', d.Delimiter)
+
+	reader := d.NewReader(strings.NewReader("'0701020300','O''Neil','say \"hi\"'\n"))
+	record, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0701020300", "O'Neil", `say "hi"`}, d.Restore(record))
+}
+
+func TestParseDialectOptions(t *testing.T) {
+	o, err := ParseDialectOptions("tab", "", "#", "true", "", "false")
+	assert.NoError(t, err)
+
+	d := DefaultDialect().apply(o)
+	assert.Equal(t, '\t', d.Delimiter)
+	assert.Equal(t, '#', d.Comment)
+	assert.True(t, d.LazyQuotes)
+	assert.False(t, d.HasHeader)
+
+	_, err = ParseDialectOptions(";;", "", "", "", "", "")
+	assert.Error(t, err)
+
+	_, err = ParseDialectOptions("", "", "", "maybe", "", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+package phonebook
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// emailMaxLength is the maximum length of an email address.
+const emailMaxLength = 254
+
+/*
+ValidateEmail checks the syntax of an email address and returns it trimmed. An empty address is valid.
+
+Only bare addresses are accepted: display names and comments, allowed by RFC 5322, are refused.
+*/
+func ValidateEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+	if len(email) > emailMaxLength {
+		return "", fmt.Errorf("invalid email <%s>: must not exceed %d characters", email, emailMaxLength)
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return "", fmt.Errorf("invalid email <%s>", email)
+	}
+
+	// Addresses without domain name, such as "john@localhost", are not deliverable
+	_, domain, _ := strings.Cut(addr.Address, "@")
+	if !strings.Contains(domain, ".") && !strings.HasPrefix(domain, "[") {
+		return "", fmt.Errorf("invalid email <%s>: domain has no top-level domain", email)
+	}
+	return email, nil
+}
@@ -0,0 +1,33 @@
+package phonebook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEmail(t *testing.T) {
+	for input, expected := range map[string]string{
+		"":                          "",
+		" john.doe@example.com ":    "john.doe@example.com",
+		"jane+news@mail.example.fr": "jane+news@mail.example.fr",
+		"paul@[192.168.0.1]":        "paul@[192.168.0.1]",
+	} {
+		email, err := ValidateEmail(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, email)
+	}
+
+	for _, input := range []string{
+		"john.doe",
+		"john@",
+		"@example.com",
+		"john doe@example.com",
+		"John <john@example.com>",
+		"john@localhost",
+		"john@example.com, jane@example.com",
+	} {
+		_, err := ValidateEmail(input)
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,199 @@
+package phonebook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// encodingSampleSize is the number of bytes read from the start of a file to detect its encoding.
+const encodingSampleSize = 64 << 10
+
+// Character encodings supported for uploaded files.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingWindows1252 = "windows-1252"
+	EncodingISO88591    = "iso-8859-1"
+	EncodingISO885915   = "iso-8859-15"
+)
+
+// encodingAliases maps the accepted encoding names to the supported encodings.
+var encodingAliases = map[string]string{
+	"utf-8":        EncodingUTF8,
+	"utf8":         EncodingUTF8,
+	"utf-16le":     EncodingUTF16LE,
+	"utf-16":       EncodingUTF16LE,
+	"utf16":        EncodingUTF16LE,
+	"utf-16be":     EncodingUTF16BE,
+	"windows-1252": EncodingWindows1252,
+	"cp1252":       EncodingWindows1252,
+	"iso-8859-1":   EncodingISO88591,
+	"latin1":       EncodingISO88591,
+	"latin-1":      EncodingISO88591,
+	"iso-8859-15":  EncodingISO885915,
+	"latin9":       EncodingISO885915,
+}
+
+// ParseEncoding validates an encoding name sent along an upload. An empty name lets the encoding be detected.
+func ParseEncoding(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", nil
+	}
+
+	enc, ok := encodingAliases[name]
+	if !ok {
+		return "", fmt.Errorf("unsupported encoding <%s>", name)
+	}
+	return enc, nil
+}
+
+// DetectFileEncoding detects the character encoding from the first bytes of a file.
+func DetectFileEncoding(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return DetectReaderEncoding(f)
+}
+
+// DetectReaderEncoding detects the character encoding from the first bytes of a reader.
+func DetectReaderEncoding(r io.Reader) (string, error) {
+	sample := make([]byte, encodingSampleSize)
+	n, err := io.ReadFull(r, sample)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return DetectEncoding(sample[:n], n < encodingSampleSize), nil
+}
+
+/*
+DetectEncoding guesses the character encoding of a sample.
+
+A byte order mark is trusted first. Otherwise, text where every other byte is
+zero is UTF-16, valid text is UTF-8 and anything else falls back to Windows-1252,
+which is what spreadsheet softwares export by default.
+
+When complete is false, the sample may end in the middle of a character.
+*/
+func DetectEncoding(sample []byte, complete bool) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+
+	if enc := detectUTF16(sample); enc != "" {
+		return enc
+	}
+
+	if !complete {
+		// Drop a character cut at the end of the sample
+		for i := 0; i < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); i++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	if utf8.Valid(sample) {
+		return EncodingUTF8
+	}
+
+	return EncodingWindows1252
+}
+
+// detectUTF16 recognises UTF-16 text without byte order mark from the position of its zero bytes.
+func detectUTF16(sample []byte) string {
+	if len(sample) < 2 {
+		return ""
+	}
+
+	var even, odd int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+
+	half := len(sample) / 2
+	switch {
+	case odd > half*3/4 && even == 0:
+		return EncodingUTF16LE
+	case even > half*3/4 && odd == 0:
+		return EncodingUTF16BE
+	}
+	return ""
+}
+
+/*
+asciiCompatible tells whether ASCII characters of the encoding are single bytes which never
+appear within other characters, so that the file can be split on its raw bytes.
+*/
+func asciiCompatible(encoding string) bool {
+	switch encoding {
+	case EncodingUTF8, EncodingWindows1252, EncodingISO88591, EncodingISO885915:
+		return true
+	}
+	return false
+}
+
+// textEncoding returns the decoder of a supported encoding.
+func textEncoding(name string) encoding.Encoding {
+	switch name {
+	case EncodingUTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	case EncodingUTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	case EncodingWindows1252:
+		return charmap.Windows1252
+	case EncodingISO88591:
+		return charmap.ISO8859_1
+	case EncodingISO885915:
+		return charmap.ISO8859_15
+	}
+	return unicode.UTF8BOM
+}
+
+/*
+NewDecodedReader transcodes the reader from the given encoding to UTF-8.
+
+Byte order marks are removed, so they never end up in the first header.
+*/
+func NewDecodedReader(r io.Reader, encoding string) io.Reader {
+	return transform.NewReader(r, textEncoding(encoding).NewDecoder())
+}
+
+// decodedFile is an opened file transcoded to UTF-8.
+type decodedFile struct {
+	io.Reader
+	file io.Closer
+}
+
+// newDecodedFile transcodes an opened file to UTF-8 from the given encoding.
+func newDecodedFile(f io.ReadCloser, encoding string) *decodedFile {
+	return &decodedFile{Reader: NewDecodedReader(f, encoding), file: f}
+}
+
+func (d *decodedFile) Close() error {
+	return d.file.Close()
+}
@@ -0,0 +1,63 @@
+package phonebook
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const encodingSample = "Phone;Prénom;Nom\n0701020300;Jérôme;Lefèvre\n"
+
+func TestDetectEncoding(t *testing.T) {
+	utf16le, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String(encodingSample)
+	utf16be, _ := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder().String(encodingSample)
+	cp1252, _ := charmap.Windows1252.NewEncoder().String(encodingSample)
+
+	assert.Equal(t, EncodingUTF8, DetectEncoding([]byte(encodingSample), true))
+	assert.Equal(t, EncodingUTF8, DetectEncoding([]byte("\xEF\xBB\xBF"+encodingSample), true))
+	assert.Equal(t, EncodingUTF16LE, DetectEncoding([]byte(utf16le), true))
+	assert.Equal(t, EncodingUTF16BE, DetectEncoding([]byte(utf16be), true))
+	assert.Equal(t, EncodingWindows1252, DetectEncoding([]byte(cp1252), true))
+}
+
+func TestDetectEncoding_TruncatedSample(t *testing.T) {
+	sample := []byte(encodingSample)
+	cut := strings.Index(encodingSample, "é") + 1 // Cut within the two bytes of "é"
+
+	assert.Equal(t, EncodingUTF8, DetectEncoding(sample[:cut], false))
+	assert.Equal(t, EncodingWindows1252, DetectEncoding(sample[:cut], true))
+}
+
+func TestNewDecodedReader(t *testing.T) {
+	utf16le, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String(encodingSample)
+	cp1252, _ := charmap.Windows1252.NewEncoder().String(encodingSample)
+
+	cases := map[string]string{
+		EncodingUTF8:        "\xEF\xBB\xBF" + encodingSample,
+		EncodingUTF16LE:     utf16le,
+		EncodingWindows1252: cp1252,
+	}
+
+	for enc, content := range cases {
+		decoded, err := io.ReadAll(NewDecodedReader(strings.NewReader(content), enc))
+		assert.NoError(t, err)
+		assert.Equal(t, encodingSample, string(decoded), enc)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	enc, err := ParseEncoding("Latin1")
+	assert.NoError(t, err)
+	assert.Equal(t, EncodingISO88591, enc)
+
+	enc, err = ParseEncoding("")
+	assert.NoError(t, err)
+	assert.Empty(t, enc)
+
+	_, err = ParseEncoding("ebcdic")
+	assert.Error(t, err)
+}
@@ -2,14 +2,21 @@ package phonebook
 
 import (
 	"context"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"go-csv-import/internal/db"
+	"go-csv-import/internal/repository"
 	"log/slog"
+	"net"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/hashicorp/go-multierror"
 )
 
+// maxImportAttempts is the number of times an import failing on transient errors is processed before it fails.
+const maxImportAttempts = 3
+
 // FileError represents an error that occurred while processing a file.
 type FileError struct {
 	FilePath string // Path to the file that caused the error
@@ -32,6 +39,78 @@ func (e *FileError) Unwrap() error {
 	return e.Err
 }
 
+// RowError represents an invalid row of a file, which can be skipped to import the other rows.
+type RowError struct {
+	Line  int    // Line number of the row in the source file
+	Entry string // File of the row within the uploaded zip archive, if any
+	Raw   string // Raw content of the row
+	Err   error
+}
+
+// NewRowError creates a new RowError instance for the row at the specified line.
+func NewRowError(line int, raw string, err error) *RowError {
+	return &RowError{
+		Line: line,
+		Raw:  raw,
+		Err:  err,
+	}
+}
+
+func (e *RowError) Error() string {
+	if e.Entry != "" {
+		return fmt.Sprintf("invalid row at line %d of %s: %v", e.Line, e.Entry, e.Err)
+	}
+	return fmt.Sprintf("invalid row at line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Codes of the errors of custom field values, reported with rejected rows.
+const (
+	FieldErrorRequired  = "required"   // The field is required and the value is empty
+	FieldErrorMaxLength = "max_length" // The value is too long
+	FieldErrorType      = "type"       // The value cannot be converted to the type of the field
+	FieldErrorEnum      = "enum"       // The value is not one of the allowed values
+)
+
+// FieldError represents an invalid value of a custom field, which rejects its row.
+type FieldError struct {
+	Field string // Name of the custom field
+	Code  string // Kind of error, to group rejected rows
+	Value string // Invalid value
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("invalid field <%s>: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+/*
+IsTransient tells whether an import failed on errors which may not happen again: timeouts, interruptions,
+lost database connections, lock wait timeouts and deadlocks. Every error of the FileParts must be transient.
+*/
+func IsTransient(err error) bool {
+	if errs, ok := err.(*multierror.Error); ok {
+		for _, e := range errs.Errors {
+			if !IsTransient(e) {
+				return false
+			}
+		}
+		return len(errs.Errors) > 0
+	}
+
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.As(err, &netErr) || repository.IsLockError(err)
+}
+
 func (q *PhonebookHandler) printTypedErrors(err error, file *FileMessage) {
 	if errs, ok := err.(*multierror.Error); ok {
 		for _, e := range errs.Errors {
@@ -41,6 +120,12 @@ func (q *PhonebookHandler) printTypedErrors(err error, file *FileMessage) {
 				continue
 			}
 
+			var re *RowError
+			if errors.As(e, &re) {
+				slog.Error("Invalid row", "line", re.Line, "raw", re.Raw, "error", re.Err)
+				continue
+			}
+
 			var de *db.DbError
 			if errors.As(e, &de) {
 				slog.Error("Database error", "error", de)
@@ -63,6 +148,8 @@ func (q *PhonebookHandler) printTypedErrors(err error, file *FileMessage) {
 
 		if ie, ok := err.(*FileError); ok {
 			slog.Error("Error processing single file", "file", file.FilePath, "error", ie.Err)
+		} else if re, ok := err.(*RowError); ok {
+			slog.Error("Invalid row for single file", "line", re.Line, "raw", re.Raw, "error", re.Err)
 		} else if de, ok := err.(*db.DbError); ok {
 			slog.Error("Database error for single file", "error", de.Err)
 		} else if errors.Is(err, context.Canceled) {
@@ -3,6 +3,8 @@ package phonebook
 import (
 	"errors"
 	"go-csv-import/internal/logger"
+	"go-csv-import/internal/model"
+	"go-csv-import/internal/repository"
 	"os"
 )
 
@@ -11,6 +13,209 @@ type FileMessage struct {
 	Uuid     string `json:"uuid"`     // Unique identifier for the message and database record
 	FilePath string `json:"filepath"` // Uploaded file
 	MaxRows  int    `json:"max_rows"` // Max number of file rows to process by worker
+
+	Template        string `json:"template,omitempty"`         // Template applied by the worker to the options not set by the upload
+	TemplateVersion int    `json:"template_version,omitempty"` // Version of the template, resolved when the file has been uploaded
+
+	Format         string                  `json:"format,omitempty"`          // Format of the file: csv, ndjson, json, xlsx, vcf or fixed
+	Sheet          string                  `json:"sheet,omitempty"`           // Sheet of the Excel workbook to import, the first one when empty
+	VCardPhones    string                  `json:"vcard_phones,omitempty"`    // Phone rule of vCards with several phones: all or primary
+	Layout         *model.Layout           `json:"layout,omitempty"`          // Fields of fixed-width lines, as stored when the file has been uploaded
+	Mapping        ColumnMapping           `json:"mapping,omitempty"`         // Custom source headers to contact fields mapping
+	Fields         []model.FieldDefinition `json:"fields,omitempty"`          // Custom fields of the phonebook, as stored when the file has been uploaded
+	DialectOptions DialectOptions          `json:"dialect_options,omitempty"` // Dialect settings requested with the upload
+	Dialect        *Dialect                `json:"dialect,omitempty"`         // Dialect of the file, detected once for every FilePart
+	Encoding       string                  `json:"encoding,omitempty"`        // Character encoding of the file, requested or detected
+
+	ContinueOnError bool   `json:"continue_on_error,omitempty"` // Skip invalid rows instead of abandoning the FilePart
+	Country         string `json:"country,omitempty"`           // Default country of national phone numbers
+	PhoneValidation string `json:"phone_validation,omitempty"`  // Phone validation mode: strict or lenient
+
+	Mode      repository.ImportMode `json:"mode,omitempty"`      // How contacts already imported are handled: insert, upsert or skip-existing
+	Phonebook string                `json:"phonebook,omitempty"` // Name of the contact list to import into, which scopes the natural key
+	Replace   bool                  `json:"replace,omitempty"`   // Remove the other contacts of the phonebook once the import succeeds
+	DryRun    bool                  `json:"dry_run,omitempty"`   // Run the whole import but roll back every insert
+	Staging   string                `json:"staging,omitempty"`   // Load contacts into a staging table committed at once: atomic or review
+	Loader    string                `json:"loader,omitempty"`    // Writes contacts with GORM inserts or LOAD DATA, the configured loader when empty
+
+	MaxParallelism int `json:"max_parallelism,omitempty"` // Max number of FileParts processed at once, only limited by the worker pool when 0
+
+	Compression string `json:"compression,omitempty"` // Compression of the uploaded file: gzip or zip
+	MaxRatio    int    `json:"max_ratio,omitempty"`   // Max ratio between uncompressed and compressed sizes
+	MaxEntries  int    `json:"max_entries,omitempty"` // Max number of files within a zip archive
+	MaxSize     int64  `json:"max_size,omitempty"`    // Max uncompressed size in bytes of the contents of an archive
+
+	Attempts int `json:"attempts,omitempty"` // Number of failures on transient errors, after which the message has been published again
+
+	mapping    *Mapping         // Column mapping resolved once for every FilePart
+	rejects    *RejectWriter    // Rejected rows of the import, when ContinueOnError is set
+	loaded     bool             // Every FilePart has been imported, so that a failure is not retried
+	normalizer *PhoneNormalizer // Phone numbers normalizer of the import
+	sizer      *BatchSizer      // Number of contacts by query, adjusted to the insert latency
+}
+
+// ResolveEncoding detects the file character encoding, unless it has been requested or already detected.
+// Excel workbooks have no character encoding.
+func (j *FileMessage) ResolveEncoding() (string, error) {
+	if j.Encoding == "" && j.Format != FormatXLSX {
+		f, err := j.openSource()
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		enc, err := DetectReaderEncoding(f)
+		if err != nil {
+			return "", err
+		}
+		j.Encoding = enc
+	}
+	return j.Encoding, nil
+}
+
+// ResolveDialect detects the file dialect, unless it has already been detected,
+// so that every FilePart is parsed the same way. JSON, Excel, vCard and fixed-width files have no dialect.
+func (j *FileMessage) ResolveDialect() (Dialect, error) {
+	if _, err := j.ResolveEncoding(); err != nil {
+		return Dialect{}, err
+	}
+	if !j.isCSV() {
+		return Dialect{}, nil
+	}
+
+	if j.Dialect == nil {
+		f, err := j.openDecodedSource()
+		if err != nil {
+			return Dialect{}, err
+		}
+		defer f.Close()
+
+		d, err := DetectDialect(f, j.DialectOptions)
+		if err != nil {
+			return Dialect{}, err
+		}
+		j.Dialect = &d
+	}
+
+	return *j.Dialect, j.Dialect.Validate()
+}
+
+// ReadHeader reads the column headers of the file, or names them by position when the file has no header.
+// The headers of JSON files are the keys of their first object, and those of Excel files the first row of the sheet.
+// vCard properties are read as the phone, firstname and lastname columns, and fixed-width lines as the fields of their layout.
+func (j *FileMessage) ReadHeader() ([]string, error) {
+	dialect, err := j.ResolveDialect()
+	if err != nil {
+		return nil, err
+	}
+	switch j.Format {
+	case FormatXLSX:
+		return j.readSheetHeader()
+	case FormatVCF:
+		return append([]string(nil), vCardHeader...), nil
+	case FormatFixed:
+		if j.Layout == nil {
+			return nil, errors.New("a layout is required to read fixed-width lines")
+		}
+		return layoutHeader(j.Layout), nil
+	}
+
+	f, err := j.openDecodedSource()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if !j.isCSV() {
+		return readJSONHeader(f, j.Format)
+	}
+	return readHeader(dialect.NewReader(f), dialect)
+}
+
+// ResolveMapping resolves the contact fields and the custom fields of the phonebook against the headers of the file.
+func (j *FileMessage) ResolveMapping(header []string) (*Mapping, error) {
+	m, err := ResolveMapping(header, j.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.ResolveFields(j.Fields); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CountRows counts the data records of the file, the header excluded. A quoted field holding newlines counts once.
+func (j *FileMessage) CountRows() (int, error) {
+	if j.Format == FormatXLSX {
+		return j.countSheetRows()
+	}
+
+	f, err := j.openDecodedSource()
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	switch j.Format {
+	case FormatJSON:
+		return countJSONArray(f)
+	case FormatVCF:
+		return countVCards(f, j.VCardPhones)
+	case FormatFixed:
+		count, err := countRecords(f, j.scanDialect())
+		return max(count-j.headerRecords(), 0), err
+	case FormatNDJSON:
+		return countRecords(f, j.scanDialect())
+	}
+
+	_, count, err := scanRecords(f, j.dialect())
+	return count, err
+}
+
+// openDecodedSource opens the first CSV content of the upload, transcoded to UTF-8.
+func (j *FileMessage) openDecodedSource() (*decodedFile, error) {
+	f, err := j.openSource()
+	if err != nil {
+		return nil, err
+	}
+	return newDecodedFile(f, j.Encoding), nil
+}
+
+// isCSV tells whether the file holds delimited records, rather than JSON objects, spreadsheet rows, vCards or fixed-width lines.
+func (j *FileMessage) isCSV() bool {
+	return j.Format == "" || j.Format == FormatCSV
+}
+
+// hasHeader tells whether the first record of the file is a header.
+func (j *FileMessage) hasHeader() bool {
+	return j.headerRecords() > 0
+}
+
+// headerRecords returns the number of header records before the first data record.
+func (j *FileMessage) headerRecords() int {
+	switch {
+	case j.Format == FormatFixed && j.Layout != nil:
+		return j.Layout.SkipLines
+	case j.isCSV() && j.dialect().HasHeader:
+		return 1
+	}
+	return 0
+}
+
+// scanDialect returns the dialect splitting the raw file into records: JSON Lines and fixed-width records are lines.
+func (j *FileMessage) scanDialect() Dialect {
+	if j.Format == FormatNDJSON || j.Format == FormatFixed {
+		return Dialect{}
+	}
+	return j.dialect()
+}
+
+// dialect returns the resolved dialect of the file, or the default one before it is resolved.
+func (j *FileMessage) dialect() Dialect {
+	if j.Dialect == nil {
+		return DefaultDialect()
+	}
+	return *j.Dialect
 }
 
 // Safe removes temporary file by checking that file exists and is not a directory
@@ -25,5 +230,23 @@ func (j *FileMessage) Remove() error {
 	}
 
 	logger.Debug("Removing FileMessage", "file", j.FilePath)
+	if err := os.RemoveAll(j.entriesDir()); err != nil {
+		return err
+	}
 	return os.Remove(j.FilePath)
 }
+
+// entriesDir returns the directory of the decompressed contents of a compressed upload.
+func (j *FileMessage) entriesDir() string {
+	return j.FilePath + ".entries"
+}
+
+/*
+Retryable tells whether the import failed on transient errors only, and can be published again to resume it
+from its checkpoints, until it has failed maxImportAttempts times.
+
+Failures once every FilePart has been imported, while committing staged contacts or replacing the phonebook, are not retried.
+*/
+func (j *FileMessage) Retryable(err error) bool {
+	return err != nil && !j.loaded && j.Attempts+1 < maxImportAttempts && IsTransient(err)
+}
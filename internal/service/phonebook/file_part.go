@@ -1,37 +1,49 @@
 package phonebook
 
 import (
-	"errors"
 	"fmt"
-	"go-csv-import/internal/logger"
+	"go-csv-import/internal/model"
+	"io"
 	"os"
 	"time"
 )
 
+// FilePart is a range of records of the uploaded file, parsed by its own goroutine.
 type FilePart struct {
 	Uuid        string        // Unique identifier for the message and database record
-	FilePath    string        // File to treat
+	FilePath    string        // Uploaded file
+	Entry       string        // File within the uploaded archive, decompressed to FilePath, if any
+	Encoding    string        // Character encoding of the file
+	Offset      int64         // Byte offset of the first record of the range
+	Size        int64         // Number of bytes of the range
+	Header      bool          // The range starts with the header record of the file
+	LineOffset  int           // Number of lines to add to a line of the range to get the line of the uploaded file
+	Rows        int           // Number of records of the range, the header excluded
 	TotalRows   int           // Total number of rows in the file
 	ProcessTime time.Duration // Time taken to process the file
 	Error       error         // Error that occurred during processing, if any
+
+	mapping *Mapping // Column mapping of the compressed content, which has its own header
+
+	checkpoint *model.Checkpoint // Progress committed along with the contacts of the range, unless on dry run
+	read       int               // Number of records read from the range, rejected rows included
 }
 
-// Safe removes temporary file by checking that file exists and is not a directory
-func (f *FilePart) Remove() error {
-	i, err := os.Stat(f.FilePath)
+// Open reads the range of the uploaded file, or of the decompressed content, transcoded to UTF-8.
+func (f *FilePart) Open() (io.ReadCloser, error) {
+	file, err := os.Open(f.FilePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if i.IsDir() {
-		return errors.New("cannot remove directory")
-	}
-
-	logger.Debug("Removing FilePart", "file", f.FilePath)
-	return os.Remove(f.FilePath)
+	section := io.NewSectionReader(file, f.Offset, f.Size)
+	return &decodedFile{Reader: NewDecodedReader(section, f.Encoding), file: file}, nil
 }
 
 // PrintStat returns a formatted string with job statistics
 func (f *FilePart) PrintStat() string {
-	return fmt.Sprintf("FilePart %s has been treated in %0.3f sec with a total of %d rows", f.FilePath, f.ProcessTime.Seconds(), f.TotalRows)
+	if f.Entry != "" {
+		return fmt.Sprintf("FilePart %s [%s] has been treated in %0.3f sec with a total of %d rows", f.FilePath, f.Entry, f.ProcessTime.Seconds(), f.TotalRows)
+	}
+	return fmt.Sprintf("FilePart %s [%d-%d] has been treated in %0.3f sec with a total of %d rows", f.FilePath, f.Offset, f.Offset+f.Size, f.ProcessTime.Seconds(), f.TotalRows)
 }
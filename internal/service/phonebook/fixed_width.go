@@ -0,0 +1,170 @@
+package phonebook
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-csv-import/internal/model"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Trimming rules of layout fields.
+const (
+	TrimBoth  = "both"  // Padding is removed from both sides, the default
+	TrimLeft  = "left"  // Padding is removed before the value, right-aligned fields
+	TrimRight = "right" // Padding is removed after the value, left-aligned fields
+	TrimNone  = "none"  // The value is kept as sliced
+)
+
+// layoutNameMaxLength is the maximum length of a layout name.
+const layoutNameMaxLength = 100
+
+/*
+ParseLayout decodes and validates the JSON definition of a fixed-width layout.
+
+	{"skip_lines": 1, "fields": [{"name": "phone", "start": 1, "width": 15, "trim": "right"}, ...]}
+*/
+func ParseLayout(name string, raw []byte) (*model.Layout, error) {
+	layout := &model.Layout{}
+	if err := json.Unmarshal(raw, layout); err != nil {
+		return nil, fmt.Errorf("invalid layout: %w", err)
+	}
+	layout.Name = name
+
+	if err := ValidateLayout(layout); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// ValidateLayout checks that every field of the layout can be sliced from a line.
+func ValidateLayout(layout *model.Layout) error {
+	if name := strings.TrimSpace(layout.Name); name == "" || utf8.RuneCountInString(name) > layoutNameMaxLength {
+		return fmt.Errorf("invalid layout: name must have 1 to %d characters", layoutNameMaxLength)
+	}
+	if layout.SkipLines < 0 {
+		return errors.New("invalid layout: skip_lines must not be negative")
+	}
+	if len(layout.Fields) == 0 {
+		return errors.New("invalid layout: at least one field is required")
+	}
+
+	seen := make(map[string]bool)
+	for i, field := range layout.Fields {
+		if strings.TrimSpace(field.Name) == "" {
+			return fmt.Errorf("invalid layout: field %d has no name", i+1)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("invalid layout: field <%s> is declared twice", field.Name)
+		}
+		seen[field.Name] = true
+
+		if field.Start < 1 || field.Width < 1 {
+			return fmt.Errorf("invalid layout: field <%s> must start at column 1 or more with a width of 1 or more", field.Name)
+		}
+		switch field.Trim {
+		case "", TrimBoth, TrimLeft, TrimRight, TrimNone:
+		default:
+			return fmt.Errorf("invalid layout: unknown trim <%s> of field <%s>: expected %s, %s, %s or %s", field.Trim, field.Name, TrimBoth, TrimLeft, TrimRight, TrimNone)
+		}
+		if utf8.RuneCountInString(field.Pad) > 1 {
+			return fmt.Errorf("invalid layout: pad of field <%s> must be a single character", field.Name)
+		}
+	}
+	return nil
+}
+
+// layoutHeader returns the names of the layout fields, which are the header of the file.
+func layoutHeader(layout *model.Layout) []string {
+	header := make([]string, len(layout.Fields))
+	for i, field := range layout.Fields {
+		header[i] = field.Name
+	}
+	return header
+}
+
+/*
+sliceFields slices a line into the fields of the layout.
+
+Columns count characters rather than bytes, once the line is transcoded to UTF-8.
+A line shorter than the layout gives empty or truncated fields.
+*/
+func sliceFields(line string, layout *model.Layout) []string {
+	chars := []rune(line)
+	record := make([]string, len(layout.Fields))
+	for i, field := range layout.Fields {
+		start := min(field.Start-1, len(chars))
+		end := min(start+field.Width, len(chars))
+		record[i] = trimField(string(chars[start:end]), field)
+	}
+	return record
+}
+
+// trimField removes the padding of a field value, following its trimming rule.
+func trimField(value string, field model.LayoutField) string {
+	pad := field.Pad
+	if pad == "" {
+		pad = " "
+	}
+
+	switch field.Trim {
+	case TrimNone:
+		return value
+	case TrimLeft:
+		return strings.TrimLeft(value, pad)
+	case TrimRight:
+		return strings.TrimRight(value, pad)
+	}
+	return strings.Trim(value, pad)
+}
+
+// fixedWidthReader reads the lines of a fixed-width file, sliced into the fields of its layout. Empty lines are ignored.
+type fixedWidthReader struct {
+	reader *bufio.Reader
+	layout *model.Layout
+	skip   int // Header lines left to skip
+	line   int
+	raw    string
+}
+
+func newFixedWidthReader(r io.Reader, layout *model.Layout, header bool) *fixedWidthReader {
+	reader := &fixedWidthReader{reader: bufio.NewReader(r), layout: layout}
+	if header {
+		reader.skip = layout.SkipLines
+	}
+	return reader
+}
+
+func (r *fixedWidthReader) Read() ([]string, error) {
+	for {
+		line, err := r.reader.ReadString('\n')
+		if line == "" && err != nil {
+			return nil, err
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		r.line++
+
+		r.raw = strings.TrimRight(line, "\r\n")
+		if r.raw == "" {
+			continue
+		}
+		if r.skip > 0 {
+			r.skip--
+			continue
+		}
+		return sliceFields(r.raw, r.layout), nil
+	}
+}
+
+func (r *fixedWidthReader) Line() int {
+	return r.line
+}
+
+func (r *fixedWidthReader) Raw([]string) string {
+	return r.raw
+}
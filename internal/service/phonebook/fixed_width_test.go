@@ -0,0 +1,120 @@
+package phonebook
+
+import (
+	"go-csv-import/internal/model"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var partnerLayout = &model.Layout{
+	Name:      "partner",
+	SkipLines: 1,
+	Fields: []model.LayoutField{
+		{Name: "phone", Start: 1, Width: 12, Trim: TrimLeft, Pad: "*"},
+		{Name: "lastname", Start: 13, Width: 10},
+		{Name: "firstname", Start: 23, Width: 8, Trim: TrimRight},
+	},
+}
+
+func TestParseLayout(t *testing.T) {
+	layout, err := ParseLayout("partner", []byte(`{"skip_lines": 1, "fields": [{"name": "phone", "start": 1, "width": 12, "trim": "left", "pad": "*"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "partner", layout.Name)
+	assert.Equal(t, 1, layout.SkipLines)
+	assert.Equal(t, []model.LayoutField{{Name: "phone", Start: 1, Width: 12, Trim: TrimLeft, Pad: "*"}}, layout.Fields)
+
+	for _, raw := range []string{
+		`{"fields": []}`,
+		`{"fields": [{"name": "phone", "start": 0, "width": 12}]}`,
+		`{"fields": [{"name": "phone", "start": 1, "width": 0}]}`,
+		`{"fields": [{"name": "", "start": 1, "width": 12}]}`,
+		`{"fields": [{"name": "phone", "start": 1, "width": 12}, {"name": "phone", "start": 13, "width": 2}]}`,
+		`{"fields": [{"name": "phone", "start": 1, "width": 12, "trim": "center"}]}`,
+		`{"fields": [{"name": "phone", "start": 1, "width": 12, "pad": "**"}]}`,
+		`{"skip_lines": -1, "fields": [{"name": "phone", "start": 1, "width": 12}]}`,
+		`[]`,
+	} {
+		_, err := ParseLayout("partner", []byte(raw))
+		assert.Error(t, err, raw)
+	}
+
+	_, err = ParseLayout("", []byte(`{"fields": [{"name": "phone", "start": 1, "width": 12}]}`))
+	assert.Error(t, err)
+}
+
+func TestSliceFields(t *testing.T) {
+	assert.Equal(t, []string{"0701020300", "Doe", "John"}, sliceFields("**0701020300  Doe     John    ", partnerLayout))
+	assert.Equal(t, []string{"0701020301", "Müller", "Jürgen"}, sliceFields("**0701020301Müller    Jürgen", partnerLayout))
+	assert.Equal(t, []string{"0701020302", "", ""}, sliceFields("0701020302", partnerLayout))
+
+	field := model.LayoutField{Name: "phone", Start: 1, Width: 5, Trim: TrimNone}
+	assert.Equal(t, " 07  ", trimField(" 07  ", field))
+	field.Trim = TrimRight
+	assert.Equal(t, " 07", trimField(" 07  ", field))
+	field.Trim = ""
+	assert.Equal(t, "07", trimField(" 07  ", field))
+}
+
+func TestFixedWidth_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.txt")
+	content := "PHONE       LASTNAME  FIRSTNAME\n" +
+		"**0701020300Doe       John    \n" +
+		"\n" +
+		"**0701020301Smith     Jane    \r\n" +
+		"**0701020302Martin    Paul\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxRows: 2, Format: FormatOf(path), Layout: partnerLayout}
+	assert.Equal(t, FormatFixed, file.Format)
+
+	header, err := file.ReadHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"phone", "lastname", "firstname"}, header)
+
+	count, err := file.CountRows()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	parts, total, err := (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, parts, 2)
+
+	var rows [][]string
+	var lines []int
+	for _, part := range parts {
+		reader, closer, err := file.openRecordReader(&part, header)
+		assert.NoError(t, err)
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			rows = append(rows, record)
+			lines = append(lines, part.LineOffset+reader.Line())
+		}
+		closer.Close()
+	}
+	assert.Equal(t, [][]string{
+		{"0701020300", "Doe", "John"},
+		{"0701020301", "Smith", "Jane"},
+		{"0701020302", "Martin", "Paul"},
+	}, rows)
+	assert.Equal(t, []int{2, 4, 5}, lines)
+}
+
+func TestFixedWidth_WholeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.dat")
+	assert.NoError(t, os.WriteFile(path, []byte("HEADER\n**0701020300Doe       John\n"), 0o644))
+
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxRows: 10, Format: FormatFixed, Layout: partnerLayout, Encoding: EncodingUTF8}
+	parts, total, err := (&ContactUploader{}).wholeFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.True(t, parts[0].Header)
+}
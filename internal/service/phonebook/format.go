@@ -0,0 +1,143 @@
+package phonebook
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Formats of uploaded contact files.
+const (
+	FormatCSV    = "csv"    // Delimited records, with an optional header
+	FormatNDJSON = "ndjson" // JSON Lines: a JSON object per line
+	FormatJSON   = "json"   // A top-level JSON array of objects
+	FormatXLSX   = "xlsx"   // A sheet of an Excel workbook, with a header row
+	FormatVCF    = "vcf"    // vCards, version 3.0 or 4.0
+	FormatFixed  = "fixed"  // Fixed-width lines, sliced following a layout
+)
+
+// FormatOf returns the format of an uploaded file from its name, CSV unless it is a JSON, an Excel, a vCard or a fixed-width file.
+func FormatOf(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".jsonl", ".ndjson":
+		return FormatNDJSON
+	case ".json":
+		return FormatJSON
+	case ".xlsx":
+		return FormatXLSX
+	case ".vcf":
+		return FormatVCF
+	case ".txt", ".dat", ".fwf":
+		return FormatFixed
+	}
+	return FormatCSV
+}
+
+/*
+RecordReader reads the records of a FilePart, as values ordered like the header of the file.
+
+Records which cannot be read are returned as a *RecordError, so that the next records can still be read.
+*/
+type RecordReader interface {
+	Read() ([]string, error)
+	Line() int                  // Line of the last record read, within the FilePart
+	Raw(record []string) string // Raw content of the last record read, to report it
+}
+
+// RecordError is a record which cannot be read, at a line within the FilePart.
+type RecordError struct {
+	Line int
+	Raw  string
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("invalid record at line %d: %v", e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// openRecordReader opens a FilePart and creates its RecordReader, closed with the returned Closer.
+func (j *FileMessage) openRecordReader(part *FilePart, header []string) (RecordReader, io.Closer, error) {
+	if j.Format == FormatXLSX {
+		r, err := j.openSheetReader(header)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, r, nil
+	}
+
+	f, err := part.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := j.newRecordReader(f, part, header)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return reader, f, nil
+}
+
+// newRecordReader creates the RecordReader of a FilePart following the format of the upload.
+func (j *FileMessage) newRecordReader(r io.Reader, part *FilePart, header []string) (RecordReader, error) {
+	switch j.Format {
+	case FormatNDJSON:
+		return newNDJSONReader(r, header), nil
+	case FormatJSON:
+		return newJSONArrayReader(r, header)
+	case FormatVCF:
+		return newVCardReader(r, j.VCardPhones), nil
+	case FormatFixed:
+		return newFixedWidthReader(r, j.Layout, part.Header), nil
+	}
+
+	reader := newCSVRecordReader(r, *j.Dialect)
+
+	// Skip header, already resolved with the mapping
+	if part.Header {
+		if _, err := reader.reader.Read(); err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+	}
+	return reader, nil
+}
+
+// csvRecordReader reads CSV records following the dialect of the file.
+type csvRecordReader struct {
+	reader  *csv.Reader
+	dialect Dialect
+}
+
+func newCSVRecordReader(r io.Reader, dialect Dialect) *csvRecordReader {
+	reader := dialect.NewReader(r)
+	reader.FieldsPerRecord = -1 // Checked against headers for each row
+	return &csvRecordReader{reader: reader, dialect: dialect}
+}
+
+func (r *csvRecordReader) Read() ([]string, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			return nil, &RecordError{Line: pe.StartLine, Err: pe.Err}
+		}
+		return nil, err
+	}
+	return r.dialect.Restore(record), nil
+}
+
+func (r *csvRecordReader) Line() int {
+	line, _ := r.reader.FieldPos(0)
+	return line
+}
+
+func (r *csvRecordReader) Raw(record []string) string {
+	return formatRecord(record, r.dialect)
+}
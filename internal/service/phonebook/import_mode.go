@@ -0,0 +1,68 @@
+package phonebook
+
+import (
+	"errors"
+	"fmt"
+	"go-csv-import/internal/repository"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// phonebookMaxLength is the maximum length of a phonebook name.
+const phonebookMaxLength = 100
+
+// ParseImportMode validates the import mode requested with an upload, insert by default.
+func ParseImportMode(mode string) (repository.ImportMode, error) {
+	switch m := repository.ImportMode(strings.ToLower(strings.TrimSpace(mode))); m {
+	case "":
+		return repository.ImportModeInsert, nil
+	case repository.ImportModeInsert, repository.ImportModeUpsert, repository.ImportModeSkipExisting:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid import mode <%s>: expected %s, %s or %s", mode,
+		repository.ImportModeInsert, repository.ImportModeUpsert, repository.ImportModeSkipExisting)
+}
+
+// ParsePhonebook validates the name of the contact list to import into, the default list when empty.
+func ParsePhonebook(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > phonebookMaxLength {
+		return "", fmt.Errorf("invalid phonebook: name must not exceed %d characters", phonebookMaxLength)
+	}
+	return name, nil
+}
+
+/*
+ParseReplace validates the replace option requested with an upload.
+
+Replacing is scoped to a named phonebook, so that an upload never removes the contacts of other lists.
+With legacy replace enabled, uploads without phonebook nor replace option replace the default phonebook.
+*/
+func ParseReplace(replace string, phonebook string, legacy bool) (bool, error) {
+	if replace == "" {
+		return legacy && phonebook == "", nil
+	}
+
+	r, err := strconv.ParseBool(replace)
+	if err != nil {
+		return false, fmt.Errorf("invalid replace <%s>: expected a boolean", replace)
+	}
+	if r && phonebook == "" {
+		return false, errors.New("invalid replace: a phonebook is required to be replaced")
+	}
+	return r, nil
+}
+
+/*
+ValidateReplace checks that the replace option can be used with the import mode.
+
+Contacts skipped by skip-existing imports keep the identifier of the import which wrote them:
+replacing the phonebook would delete the contacts matched by the file.
+*/
+func ValidateReplace(replace bool, mode repository.ImportMode) error {
+	if replace && mode == repository.ImportModeSkipExisting {
+		return fmt.Errorf("invalid replace: not allowed with the %s mode, use %s to replace a phonebook with existing contacts", mode, repository.ImportModeUpsert)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package phonebook
+
+import (
+	"go-csv-import/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReplace(t *testing.T) {
+	replace, err := ParseReplace("true", "customers", false)
+	assert.NoError(t, err)
+	assert.True(t, replace)
+
+	// Replacing is scoped to a named phonebook
+	_, err = ParseReplace("true", "", false)
+	assert.Error(t, err)
+
+	replace, err = ParseReplace("", "", false)
+	assert.NoError(t, err)
+	assert.False(t, replace)
+
+	// Legacy replace only applies to uploads without phonebook nor replace option
+	replace, err = ParseReplace("", "", true)
+	assert.NoError(t, err)
+	assert.True(t, replace)
+
+	replace, err = ParseReplace("", "customers", true)
+	assert.NoError(t, err)
+	assert.False(t, replace)
+
+	replace, err = ParseReplace("false", "", true)
+	assert.NoError(t, err)
+	assert.False(t, replace)
+}
+
+func TestValidateReplace(t *testing.T) {
+	assert.NoError(t, ValidateReplace(true, repository.ImportModeInsert))
+	assert.NoError(t, ValidateReplace(true, repository.ImportModeUpsert))
+	assert.NoError(t, ValidateReplace(false, repository.ImportModeSkipExisting))
+
+	// Skipped contacts would be deleted by the replace
+	assert.Error(t, ValidateReplace(true, repository.ImportModeSkipExisting))
+}
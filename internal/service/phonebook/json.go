@@ -0,0 +1,342 @@
+package phonebook
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+/*
+readJSONHeader reads the keys of the first object of a JSON content, in their order.
+
+They are the header of the whole file: keys missing from the next objects give empty values,
+and keys which are not in the first object are ignored.
+*/
+func readJSONHeader(r io.Reader, format string) ([]string, error) {
+	var first []byte
+	if format == FormatNDJSON {
+		scanner := NewRecordScanner(r, Dialect{})
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("failed to read header: file has no JSON object")
+		}
+		first = bytes.TrimSpace(scanner.Record())
+	} else {
+		dec := json.NewDecoder(r)
+		if err := readArrayStart(dec); err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		if !dec.More() {
+			return nil, errors.New("failed to read header: JSON array is empty")
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		first = raw
+	}
+
+	header, err := objectKeys(first)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	return header, nil
+}
+
+// readArrayStart reads the opening bracket of a top-level JSON array.
+func readArrayStart(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return errors.New("expected a top-level JSON array of objects")
+	}
+	return nil
+}
+
+// objectKeys returns the keys of a JSON object in their order, duplicates removed.
+func objectKeys(raw []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("expected a JSON object")
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// jsonRecord returns the values of a JSON object ordered like the header.
+func jsonRecord(raw []byte, header []string) ([]string, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, errors.New("expected a JSON object")
+		}
+		return nil, err
+	}
+
+	record := make([]string, len(header))
+	for i, key := range header {
+		record[i] = jsonValue(object[key])
+	}
+	return record, nil
+}
+
+// jsonValue returns a JSON value as text: strings are unquoted, numbers like phones are kept as written, and null is empty.
+func jsonValue(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return ""
+	}
+
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+	}
+	return string(raw)
+}
+
+// ndjsonReader reads a JSON object per line, empty lines are ignored.
+type ndjsonReader struct {
+	reader *bufio.Reader
+	header []string
+	line   int
+	raw    []byte
+}
+
+func newNDJSONReader(r io.Reader, header []string) *ndjsonReader {
+	return &ndjsonReader{reader: bufio.NewReader(r), header: header}
+}
+
+func (r *ndjsonReader) Read() ([]string, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		r.line++
+
+		r.raw = bytes.TrimSpace(line)
+		if len(r.raw) == 0 {
+			continue
+		}
+
+		record, err := jsonRecord(r.raw, r.header)
+		if err != nil {
+			return nil, &RecordError{Line: r.line, Raw: string(r.raw), Err: err}
+		}
+		return record, nil
+	}
+}
+
+func (r *ndjsonReader) Line() int {
+	return r.line
+}
+
+func (r *ndjsonReader) Raw([]string) string {
+	return string(r.raw)
+}
+
+/*
+jsonArrayReader reads the objects of a JSON array.
+
+A FilePart is either the whole array, or a range of its objects, starting at an object:
+the range is then read as an array of its own. Objects are located by their position in the array, as lines.
+*/
+type jsonArrayReader struct {
+	dec     *json.Decoder
+	header  []string
+	started bool
+	index   int
+	raw     json.RawMessage
+}
+
+func newJSONArrayReader(r io.Reader, header []string) (*jsonArrayReader, error) {
+	reader := bufio.NewReader(r)
+	first, err := peekNonSpace(reader)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	var content io.Reader = reader
+	if first != '[' {
+		content = io.MultiReader(bytes.NewReader([]byte("[")), reader, bytes.NewReader([]byte("]")))
+	}
+	return &jsonArrayReader{dec: json.NewDecoder(content), header: header}, nil
+}
+
+// peekNonSpace returns the first byte of the reader which is not a space, without consuming it.
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			r.Discard(1)
+		default:
+			return b[0], nil
+		}
+	}
+}
+
+func (r *jsonArrayReader) Read() ([]string, error) {
+	if !r.started {
+		if err := readArrayStart(r.dec); err != nil {
+			return nil, err
+		}
+		r.started = true
+	}
+
+	if !r.dec.More() {
+		if _, err := r.dec.Token(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	r.raw = nil
+	if err := r.dec.Decode(&r.raw); err != nil {
+		return nil, err
+	}
+	r.index++
+
+	record, err := jsonRecord(r.raw, r.header)
+	if err != nil {
+		return nil, &RecordError{Line: r.index, Raw: string(r.raw), Err: err}
+	}
+	return record, nil
+}
+
+func (r *jsonArrayReader) Line() int {
+	return r.index
+}
+
+func (r *jsonArrayReader) Raw([]string) string {
+	return string(r.raw)
+}
+
+/*
+splitJSONArray splits a top-level JSON array into ranges of objects, in a single pass over the file.
+
+Offsets are found on the raw bytes, so files which are not UTF-8 encoded are read as a single range.
+*/
+func (c *ContactUploader) splitJSONArray(file *FileMessage) ([]FilePart, int, error) {
+	if file.Encoding != EncodingUTF8 {
+		return c.wholeFile(file)
+	}
+
+	f, err := os.Open(file.FilePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	// A byte order mark shifts the offsets of the decoder
+	reader := bufio.NewReader(f)
+	var bom int64
+	if b, err := reader.Peek(3); err == nil && bytes.Equal(b, []byte("\xEF\xBB\xBF")) {
+		reader.Discard(3)
+		bom = 3
+	}
+
+	dec := json.NewDecoder(reader)
+	if err := readArrayStart(dec); err != nil {
+		return nil, 0, err
+	}
+
+	var parts []FilePart
+	var end int64
+	total := 0
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, 0, err
+		}
+		objectEnd := bom + dec.InputOffset()
+		start := objectEnd - int64(len(raw))
+
+		if total%file.MaxRows == 0 {
+			// Ranges end with their last object, without the comma following it
+			if len(parts) > 0 {
+				last := &parts[len(parts)-1]
+				last.Size = end - last.Offset
+			}
+
+			parts = append(parts, FilePart{
+				Uuid:       file.Uuid,
+				FilePath:   file.FilePath,
+				Encoding:   file.Encoding,
+				Offset:     start,
+				LineOffset: total,
+			})
+		}
+		parts[len(parts)-1].Rows++
+		end = objectEnd
+		total++
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(parts) > 0 {
+		last := &parts[len(parts)-1]
+		last.Size = end - last.Offset
+	}
+
+	return parts, total, nil
+}
+
+// countJSONArray counts the objects of a top-level JSON array.
+func countJSONArray(r io.Reader) (int, error) {
+	dec := json.NewDecoder(r)
+	if err := readArrayStart(dec); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, nil
+}
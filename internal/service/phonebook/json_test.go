@@ -0,0 +1,134 @@
+package phonebook
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readJSONParts returns the records of every FilePart with their line, and the raw content of invalid records.
+func readJSONParts(t *testing.T, file *FileMessage, parts []FilePart, header []string) ([][]string, []int, []string) {
+	var rows [][]string
+	var lines []int
+	var invalid []string
+	for _, part := range parts {
+		f, err := part.Open()
+		assert.NoError(t, err)
+
+		reader, err := file.newRecordReader(f, &part, header)
+		assert.NoError(t, err)
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			var re *RecordError
+			if errors.As(err, &re) {
+				invalid = append(invalid, re.Raw)
+				continue
+			}
+			assert.NoError(t, err)
+			rows = append(rows, record)
+			lines = append(lines, part.LineOffset+reader.Line())
+		}
+		f.Close()
+	}
+	return rows, lines, invalid
+}
+
+func TestSplitFile_NDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.jsonl")
+	content := `{"phone_number": "0701020300", "Prénom": "John", "nom": null}` + "\n" +
+		"\n" +
+		`{"phone_number": 33701020301, "nom": "Smith", "Prénom": "Jane", "tags": ["vip"]}` + "\r\n" +
+		`["not", "an", "object"]` + "\n" +
+		`{"phone_number": "0701020302", "Prénom": "Paul"}`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxRows: 2, Format: FormatOf(path)}
+	_, err := file.ResolveDialect()
+	assert.NoError(t, err)
+	assert.Nil(t, file.Dialect)
+
+	header, err := file.ReadHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"phone_number", "Prénom", "nom"}, header)
+
+	mapping, err := ResolveMapping(header, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"phone": "phone_number", "firstname": "Prénom", "lastname": "nom"}, mapping.Describe())
+
+	parts, total, err := (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Len(t, parts, 2)
+
+	rows, lines, invalid := readJSONParts(t, file, parts, header)
+	assert.Equal(t, [][]string{
+		{"0701020300", "John", ""},
+		{"33701020301", "Jane", "Smith"},
+		{"0701020302", "Paul", ""},
+	}, rows)
+	assert.Equal(t, []int{1, 3, 5}, lines)
+	assert.Equal(t, []string{`["not", "an", "object"]`}, invalid)
+}
+
+func TestSplitFile_JSONArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.json")
+	content := "\xEF\xBB\xBF[\n" +
+		`  {"phone": "0701020300", "firstname": "John", "lastname": "Doe"},` + "\n" +
+		`  {"phone": "0701020301", "firstname": "Jane", "lastname": "Smith"},` + "\n" +
+		`  42,` + "\n" +
+		`  {"lastname": "Martin", "phone": "0701020302"}` + "\n" +
+		"]\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxRows: 2, Format: FormatJSON}
+	_, err := file.ResolveDialect()
+	assert.NoError(t, err)
+
+	header, err := file.ReadHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"phone", "firstname", "lastname"}, header)
+
+	parts, total, err := (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Len(t, parts, 2)
+
+	rows, lines, invalid := readJSONParts(t, file, parts, header)
+	assert.Equal(t, [][]string{
+		{"0701020300", "John", "Doe"},
+		{"0701020301", "Jane", "Smith"},
+		{"0701020302", "", "Martin"},
+	}, rows)
+	assert.Equal(t, []int{1, 2, 4}, lines)
+	assert.Equal(t, []string{"42"}, invalid)
+
+	// Files which cannot be split are read as a whole array
+	assert.NoError(t, os.WriteFile(path, []byte(strings.TrimPrefix(content, "\xEF\xBB\xBF")), 0o644))
+	file.Encoding = EncodingWindows1252
+	parts, total, err = (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Len(t, parts, 1)
+
+	rows, _, _ = readJSONParts(t, file, parts, header)
+	assert.Len(t, rows, 3)
+}
+
+func TestReadJSONHeader_Errors(t *testing.T) {
+	_, err := readJSONHeader(strings.NewReader(`{"phone": "0701020300"}`), FormatJSON)
+	assert.ErrorContains(t, err, "top-level JSON array")
+
+	_, err = readJSONHeader(strings.NewReader(`[]`), FormatJSON)
+	assert.ErrorContains(t, err, "empty")
+
+	_, err = readJSONHeader(strings.NewReader("\n\n"), FormatNDJSON)
+	assert.ErrorContains(t, err, "no JSON object")
+}
@@ -0,0 +1,67 @@
+package phonebook
+
+import (
+	"context"
+	"fmt"
+	"go-csv-import/internal/model"
+	"go-csv-import/internal/repository"
+	"strings"
+)
+
+// Loaders writing the contacts of an import.
+const (
+	LoaderInsert   = "insert"    // GORM multi-row INSERT statements, for every import mode
+	LoaderPrepared = "prepared"  // Multi-row INSERT statements prepared with database/sql in insert mode, GORM upserts otherwise
+	LoaderLoadData = "load-data" // MySQL LOAD DATA LOCAL INFILE streamed from memory, for the insert mode only
+)
+
+// ParseLoader validates the loader requested with an upload, the configured loader when empty.
+func ParseLoader(loader string) (string, error) {
+	switch l := strings.ToLower(strings.TrimSpace(loader)); l {
+	case "", LoaderInsert, LoaderPrepared, LoaderLoadData:
+		return l, nil
+	}
+	return "", fmt.Errorf("invalid loader <%s>: expected %s, %s or %s", loader, LoaderInsert, LoaderPrepared, LoaderLoadData)
+}
+
+// ValidateLoader checks that the loader can write contacts with the import mode.
+func ValidateLoader(loader string, mode repository.ImportMode) error {
+	if loader == LoaderLoadData && mode != "" && mode != repository.ImportModeInsert {
+		return fmt.Errorf("invalid loader: %s only applies to the %s mode", LoaderLoadData, repository.ImportModeInsert)
+	}
+	return nil
+}
+
+/*
+resolveLoader sets the loader of the import, the configured one unless requested with the upload.
+
+Imports which cannot use the configured loader fall back to GORM inserts.
+*/
+func (c *ContactUploader) resolveLoader(file *FileMessage) {
+	if file.Loader != "" {
+		return
+	}
+
+	file.Loader = c.HttpConfig.Loader
+	if ValidateLoader(file.Loader, file.Mode) != nil {
+		file.Loader = LoaderInsert
+	}
+}
+
+// writeBatch writes the contacts of a batch with the loader and the mode of the import.
+// Contacts of insert imports whose natural key is already taken are inserted without key.
+func (c *ContactUploader) writeBatch(ctx context.Context, message *FileMessage, contacts []*model.Contact) (repository.BatchResult, error) {
+	if message.Mode.IsInsert() {
+		if err := c.Repository.ReleaseTakenKeys(ctx, contacts); err != nil {
+			return repository.BatchResult{}, err
+		}
+	}
+
+	switch message.Loader {
+	case LoaderLoadData:
+		return c.Repository.LoadBatch(ctx, contacts)
+	case LoaderPrepared:
+		return c.Prepared.SaveBatch(ctx, message.Mode, contacts)
+	}
+	return c.Repository.SaveBatch(ctx, message.Mode, contacts)
+}
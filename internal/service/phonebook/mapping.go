@@ -0,0 +1,303 @@
+package phonebook
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-csv-import/internal/model"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ContactField is a contact attribute that a source column can be mapped to.
+type ContactField string
+
+const (
+	FieldPhone     ContactField = "phone"
+	FieldFirstname ContactField = "firstname"
+	FieldLastname  ContactField = "lastname"
+	FieldEmail     ContactField = "email"
+	FieldCompany   ContactField = "company"
+	FieldTitle     ContactField = "title"
+)
+
+// ContactFields lists every field a source column can be mapped to, in display order.
+var ContactFields = []ContactField{FieldPhone, FieldFirstname, FieldLastname, FieldEmail, FieldCompany, FieldTitle}
+
+// requiredFields lists the fields that must be resolved for a file to be imported.
+var requiredFields = []ContactField{FieldPhone, FieldFirstname, FieldLastname}
+
+/*
+fieldAliases lists the built-in header names recognised for each field.
+
+Aliases are written in their normalized form (see normalizeHeader),
+so "Prénom", "PRENOM" and "prenom" all match the "prenom" alias.
+*/
+var fieldAliases = map[ContactField][]string{
+	FieldPhone: {
+		"phone", "phone number", "phonenumber", "phone no", "mobile", "mobile phone", "mobile number",
+		"cell", "cellphone", "cell phone", "telephone", "tel", "gsm", "msisdn",
+		"portable", "numero", "numero de telephone", "num tel",
+	},
+	FieldFirstname: {
+		"firstname", "first name", "first", "given name", "givenname", "forename", "prenom",
+	},
+	FieldLastname: {
+		"lastname", "last name", "last", "surname", "family name", "familyname", "nom", "nom de famille",
+	},
+	FieldEmail: {
+		"email", "e mail", "mail", "email address", "e mail address", "courriel", "adresse email", "adresse mail",
+	},
+	FieldCompany: {
+		"company", "company name", "organization", "organisation", "org", "employer", "societe", "entreprise",
+	},
+	FieldTitle: {
+		"title", "job title", "jobtitle", "position", "role", "fonction", "poste",
+	},
+}
+
+// ColumnMapping maps a source header to the contact field it feeds.
+type ColumnMapping map[string]ContactField
+
+// Mapping is the column mapping resolved against the headers of a file.
+type Mapping struct {
+	Header  []string                       // Source headers, in file order
+	Columns map[ContactField]int           // Column index of each resolved field
+	Fields  map[int]*model.FieldDefinition // Custom field of each column holding one, by column index
+}
+
+/*
+ParseColumnMapping decodes a JSON object of source headers to contact fields.
+
+An empty string returns an empty mapping, so the built-in aliases apply to every field.
+
+	{"phone_number": "phone", "Prénom": "firstname"}
+*/
+func ParseColumnMapping(raw string) (ColumnMapping, error) {
+	m := ColumnMapping{}
+	if strings.TrimSpace(raw) == "" {
+		return m, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return nil, fmt.Errorf("invalid column mapping: %w", err)
+	}
+
+	for header, field := range m {
+		if !field.IsValid() {
+			return nil, fmt.Errorf("invalid column mapping: unknown field <%s> for column <%s>", field, header)
+		}
+	}
+
+	return m, nil
+}
+
+// IsValid checks that the field is a known contact field.
+func (f ContactField) IsValid() bool {
+	for _, field := range ContactFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ResolveMapping finds the column of each contact field within the file headers.
+
+Columns declared in the custom mapping take precedence, matched exactly first
+and then case- and accent-insensitively. Remaining fields are matched against
+the built-in aliases. An error is returned if a required field cannot be found,
+if several columns are mapped to the same field, or if a column is mapped to several fields.
+*/
+func ResolveMapping(header []string, custom ColumnMapping) (*Mapping, error) {
+	m := &Mapping{
+		Header:  header,
+		Columns: make(map[ContactField]int, len(ContactFields)),
+	}
+
+	normalized := make([]string, len(header))
+	for i, h := range header {
+		normalized[i] = normalizeHeader(h)
+	}
+
+	for source, field := range custom {
+		if !field.IsValid() {
+			return nil, fmt.Errorf("unknown field <%s> for column <%s>", field, source)
+		}
+
+		idx := indexOf(header, source)
+		if idx < 0 {
+			idx = indexOf(normalized, normalizeHeader(source))
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("mapped column <%s> is missing", source)
+		}
+
+		if prev, exists := m.Columns[field]; exists && prev != idx {
+			return nil, fmt.Errorf("field <%s> is mapped by several columns", field)
+		}
+		for other, i := range m.Columns {
+			if i == idx && other != field {
+				return nil, fmt.Errorf("column <%s> is mapped to several fields", header[idx])
+			}
+		}
+		m.Columns[field] = idx
+	}
+
+	for _, field := range ContactFields {
+		if _, exists := m.Columns[field]; exists {
+			continue
+		}
+
+		for _, alias := range fieldAliases[field] {
+			if idx := indexOf(normalized, alias); idx >= 0 && !m.isMapped(idx) {
+				m.Columns[field] = idx
+				break
+			}
+		}
+	}
+
+	for _, field := range requiredFields {
+		if _, exists := m.Columns[field]; !exists {
+			return nil, fmt.Errorf("columns <%s> is missing", field)
+		}
+	}
+
+	return m, nil
+}
+
+// Value returns the row value of the field, or an empty string if the field is not mapped.
+func (m *Mapping) Value(row []string, field ContactField) string {
+	idx, ok := m.Columns[field]
+	if !ok || idx >= len(row) {
+		return ""
+	}
+	return row[idx]
+}
+
+/*
+ResolveFields finds the column of each custom field within the file headers,
+matched exactly first and then case- and accent-insensitively.
+
+An error is returned if a required field cannot be found, or if its column is already mapped to a contact field.
+*/
+func (m *Mapping) ResolveFields(fields []model.FieldDefinition) error {
+	normalized := make([]string, len(m.Header))
+	for i, h := range m.Header {
+		normalized[i] = normalizeHeader(h)
+	}
+
+	m.Fields = make(map[int]*model.FieldDefinition, len(fields))
+	for i := range fields {
+		field := &fields[i]
+		idx := indexOf(m.Header, field.Name)
+		if idx < 0 {
+			idx = indexOf(normalized, normalizeHeader(field.Name))
+		}
+		if idx < 0 {
+			if field.Required {
+				return fmt.Errorf("columns <%s> is missing", field.Name)
+			}
+			continue
+		}
+
+		if m.isMapped(idx) {
+			return fmt.Errorf("column <%s> is mapped to a contact field and to custom field <%s>", m.Header[idx], field.Name)
+		}
+		if prev, exists := m.Fields[idx]; exists {
+			return fmt.Errorf("column <%s> is matched by custom fields <%s> and <%s>", m.Header[idx], prev.Name, field.Name)
+		}
+		m.Fields[idx] = field
+	}
+	return nil
+}
+
+/*
+Attributes returns the non-empty values of the columns which are not mapped to a field.
+
+Values of custom fields are converted to their type and named after their definition,
+other columns are kept as strings named after their header.
+A *FieldError is returned for the first value which does not match its definition.
+*/
+func (m *Mapping) Attributes(row []string) (map[string]any, error) {
+	var attributes map[string]any
+	for i, value := range row {
+		if i >= len(m.Header) || m.isMapped(i) {
+			continue
+		}
+
+		name, typed := m.Header[i], any(value)
+		if field, ok := m.Fields[i]; ok {
+			v, err := convertField(field, value)
+			if err != nil {
+				return nil, err
+			}
+			name, typed = field.Name, v
+		}
+		if name == "" || typed == nil || typed == "" {
+			continue
+		}
+
+		if attributes == nil {
+			attributes = make(map[string]any)
+		}
+		attributes[name] = typed
+	}
+	return attributes, nil
+}
+
+// Describe returns the resolved mapping as field names to source headers, to be displayed to users.
+// Custom fields are described by their name.
+func (m *Mapping) Describe() map[string]string {
+	d := make(map[string]string, len(m.Columns)+len(m.Fields))
+	for field, idx := range m.Columns {
+		d[string(field)] = m.Header[idx]
+	}
+	for idx, field := range m.Fields {
+		d[field.Name] = m.Header[idx]
+	}
+	return d
+}
+
+// isMapped checks if the column is already used by a field.
+func (m *Mapping) isMapped(idx int) bool {
+	for _, i := range m.Columns {
+		if i == idx {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHeader lowercases a header, strips its accents and collapses separators to single spaces.
+func normalizeHeader(h string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	s, _, err := transform.String(t, h)
+	if err != nil {
+		s = h
+	}
+
+	s = strings.ToLower(s)
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '-', '.', '/':
+			return ' '
+		}
+		return r
+	}, s)
+
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
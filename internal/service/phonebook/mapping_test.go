@@ -0,0 +1,82 @@
+package phonebook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveMapping_DefaultHeaders(t *testing.T) {
+	m, err := ResolveMapping([]string{"Phone", "Lastname", "Firstname"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"phone": "Phone", "firstname": "Firstname", "lastname": "Lastname"}, m.Describe())
+}
+
+func TestResolveMapping_Aliases(t *testing.T) {
+	m, err := ResolveMapping([]string{"MOBILE", "Prénom", "Last Name"}, nil)
+	assert.NoError(t, err)
+
+	row := []string{"0701020300", "Jérôme", "Doe"}
+	assert.Equal(t, "0701020300", m.Value(row, FieldPhone))
+	assert.Equal(t, "Jérôme", m.Value(row, FieldFirstname))
+	assert.Equal(t, "Doe", m.Value(row, FieldLastname))
+}
+
+func TestResolveMapping_OptionalFields(t *testing.T) {
+	m, err := ResolveMapping([]string{"Phone", "Prénom", "Nom", "E-mail", "Société", "Job Title", "Customer Id", "Segment", ""}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"phone": "Phone", "firstname": "Prénom", "lastname": "Nom",
+		"email": "E-mail", "company": "Société", "title": "Job Title",
+	}, m.Describe())
+
+	row := []string{"0701020300", "Jérôme", "Doe", "jerome@example.com", "ACME", "CTO", "C-42", "", "ignored"}
+	assert.Equal(t, "jerome@example.com", m.Value(row, FieldEmail))
+	attributes, err := m.Attributes(row)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"Customer Id": "C-42"}, attributes)
+
+	attributes, err = m.Attributes([]string{"0701020300", "Jérôme", "Doe", "", "", "", "", "", ""})
+	assert.NoError(t, err)
+	assert.Nil(t, attributes)
+}
+
+func TestResolveMapping_CustomMapping(t *testing.T) {
+	custom := ColumnMapping{"phone_number": FieldPhone, "GIVEN": FieldFirstname}
+	m, err := ResolveMapping([]string{"Phone_Number", "given", "nom"}, custom)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"phone": "Phone_Number", "firstname": "given", "lastname": "nom"}, m.Describe())
+}
+
+func TestResolveMapping_MissingColumn(t *testing.T) {
+	_, err := ResolveMapping([]string{"Phone", "Firstname"}, nil)
+	assert.EqualError(t, err, "columns <lastname> is missing")
+
+	_, err = ResolveMapping([]string{"Phone", "Firstname", "Lastname"}, ColumnMapping{"Mobile": FieldPhone})
+	assert.EqualError(t, err, "mapped column <Mobile> is missing")
+}
+
+func TestResolveMapping_ColumnMappedTwice(t *testing.T) {
+	// Both keys resolve to the same column, which would feed the lastname with the firstname
+	custom := ColumnMapping{"Prénom": FieldFirstname, "prenom": FieldLastname}
+	_, err := ResolveMapping([]string{"Phone", "Prénom", "Nom"}, custom)
+	assert.EqualError(t, err, "column <Prénom> is mapped to several fields")
+
+	// Keys resolving to the same column for the same field are accepted
+	m, err := ResolveMapping([]string{"Phone", "Prénom", "Nom"}, ColumnMapping{"Prénom": FieldFirstname, "PRENOM": FieldFirstname})
+	assert.NoError(t, err)
+	assert.Equal(t, "Prénom", m.Describe()["firstname"])
+}
+
+func TestParseColumnMapping(t *testing.T) {
+	m, err := ParseColumnMapping(`{"phone_number": "phone"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, ColumnMapping{"phone_number": FieldPhone}, m)
+
+	m, err = ParseColumnMapping("")
+	assert.NoError(t, err)
+	assert.Empty(t, m)
+
+	_, err = ParseColumnMapping(`{"email": "mail"}`)
+	assert.Error(t, err)
+}
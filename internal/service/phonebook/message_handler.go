@@ -16,8 +16,10 @@ import (
 type MessageType string
 
 const (
-	MessageTypeUpload MessageType = "upload"
-	MessageTypeDelete MessageType = "delete"
+	MessageTypeUpload  MessageType = "upload"
+	MessageTypeDelete  MessageType = "delete"
+	MessageTypeCommit  MessageType = "commit"  // Commit the staged contacts of an import waiting for review
+	MessageTypeDiscard MessageType = "discard" // Discard the staged contacts of an import waiting for review
 )
 
 type MessageHandlerFunc func(ctx context.Context, msg rabbit.Delivery) (ack bool, err error)
@@ -34,8 +36,10 @@ type MessageHandler struct {
 func (p *PhonebookHandler) NewMessageHandler() *MessageHandler {
 	return &MessageHandler{
 		handlers: map[MessageType]MessageHandlerFunc{
-			MessageTypeUpload: p.handleMessageInsertPhonebook,
-			MessageTypeDelete: p.handleMessageDeletePhonebook,
+			MessageTypeUpload:  p.handleMessageInsertPhonebook,
+			MessageTypeDelete:  p.handleMessageDeletePhonebook,
+			MessageTypeCommit:  p.handleMessageCommitStaging,
+			MessageTypeDiscard: p.handleMessageDiscardStaging,
 		},
 	}
 }
@@ -61,7 +65,20 @@ func (p *PhonebookHandler) handleMessageInsertPhonebook(ctx context.Context, msg
 	start := time.Now()
 	logger.Info("Treating file", "file", file.FilePath)
 
-	if erru := p.Uploader.Upload(ctx, file); erru != nil {
+	erru := p.Uploader.Upload(ctx, file)
+	if file.Retryable(erru) {
+		// The file and the checkpoints are kept for the next attempt to resume the import
+		file.Attempts++
+		logger.Warn("Retrying import after a transient error", "uuid", file.Uuid, "attempt", file.Attempts, "error", erru)
+		errp := p.Publish(file, MessageTypeUpload)
+		if errp == nil {
+			return true, nil
+		}
+		logger.Error("Cannot publish import again", "uuid", file.Uuid, "error", errp)
+		p.Uploader.abandonRetry(ctx, file)
+	}
+
+	if erru != nil {
 		p.ProgressStore.SetError(file.Uuid, erru)
 		p.printTypedErrors(erru, file)
 	} else {
@@ -101,3 +118,40 @@ func (p *PhonebookHandler) handleMessageDeletePhonebook(ctx context.Context, msg
 	logger.Info("Contacts successful deleted ", "time", time.Since(start))
 	return true, nil
 }
+
+func (p *PhonebookHandler) handleMessageCommitStaging(ctx context.Context, msg rabbit.Delivery) (ack bool, err error) {
+	var file *FileMessage
+	message := amqp.NewJsonMessageDecoder(msg.Body)
+	if errd := message.Decode(&file); errd != nil {
+		logger.Error("Decode AMQP message", "body", msg.Body, "error", errd)
+		return true, fmt.Errorf("cannot decode AMQP message for FileMessage")
+	}
+
+	start := time.Now()
+	logger.Info("Committing staged contacts...", "uuid", file.Uuid)
+
+	if err := p.Uploader.CommitStaging(ctx, file.Uuid, msg.Redelivered); err != nil {
+		logger.Error("Cannot commit staged contacts", "uuid", file.Uuid, "error", err)
+		return true, err
+	}
+
+	logger.Info("Staged contacts successful committed", "uuid", file.Uuid, "time", time.Since(start))
+	return true, nil
+}
+
+func (p *PhonebookHandler) handleMessageDiscardStaging(ctx context.Context, msg rabbit.Delivery) (ack bool, err error) {
+	var file *FileMessage
+	message := amqp.NewJsonMessageDecoder(msg.Body)
+	if errd := message.Decode(&file); errd != nil {
+		logger.Error("Decode AMQP message", "body", msg.Body, "error", errd)
+		return true, fmt.Errorf("cannot decode AMQP message for FileMessage")
+	}
+
+	logger.Info("Discarding staged contacts...", "uuid", file.Uuid)
+
+	if err := p.Uploader.DiscardStaging(ctx, file.Uuid); err != nil {
+		logger.Error("Cannot discard staged contacts", "uuid", file.Uuid, "error", err)
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,96 @@
+package phonebook
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// Phone validation modes, deciding what happens to numbers that cannot be normalized.
+const (
+	PhoneValidationStrict  = "strict"  // Numbers are rejected as row errors
+	PhoneValidationLenient = "lenient" // Numbers are imported without normalized value
+)
+
+// ParseCountry validates a default country as an ISO 3166-1 alpha-2 region known by the numbering plans.
+func ParseCountry(country string) (string, error) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if country == "" {
+		return "", nil
+	}
+
+	if !phonenumbers.GetSupportedRegions()[country] {
+		return "", fmt.Errorf("unsupported country <%s>: expected an ISO 3166-1 alpha-2 code", country)
+	}
+	return country, nil
+}
+
+// ParsePhoneValidation validates a phone validation mode. An empty mode lets the configured default apply.
+func ParsePhoneValidation(mode string) (string, error) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "", PhoneValidationStrict, PhoneValidationLenient:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid phone validation <%s>: expected %s or %s", mode, PhoneValidationStrict, PhoneValidationLenient)
+}
+
+/*
+PhoneNormalizer parses phone numbers into the E.164 format.
+
+Numbers without international prefix are parsed as numbers of the default country.
+Numbering plans are compiled in the binary, so no network access is needed.
+*/
+type PhoneNormalizer struct {
+	Country string // Default ISO 3166-1 alpha-2 country of national numbers
+	Strict  bool   // Return an error for numbers which cannot be normalized
+}
+
+// NewPhoneNormalizer creates a PhoneNormalizer for the default country and validation mode.
+func NewPhoneNormalizer(country string, validation string) *PhoneNormalizer {
+	return &PhoneNormalizer{
+		Country: strings.ToUpper(country),
+		Strict:  validation != PhoneValidationLenient,
+	}
+}
+
+/*
+Normalize returns the E.164 form of a phone number, like "+33701020300".
+
+"07 01 02 03 00", "+33 7 01 02 03 00" and "0033-7-01-02-03-00" give the same number for France.
+In lenient mode, numbers which cannot be normalized give an empty string without error.
+*/
+func (n *PhoneNormalizer) Normalize(raw string) (string, error) {
+	e164, err := n.normalize(raw)
+	if err != nil && !n.Strict {
+		return "", nil
+	}
+	return e164, err
+}
+
+func (n *PhoneNormalizer) normalize(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", errors.New("phone number is empty")
+	}
+
+	number, err := phonenumbers.Parse(raw, n.Country)
+	if err != nil {
+		return "", fmt.Errorf("invalid phone number <%s>: %w", raw, err)
+	}
+
+	// Only the length is checked, as numbering plans of ranges not allocated yet may be outdated
+	if !phonenumbers.IsPossibleNumber(number) {
+		return "", fmt.Errorf("impossible phone number <%s> for country %s", raw, n.regionOf(number))
+	}
+
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
+
+// regionOf returns the region of the number, or the default country when it cannot be found.
+func (n *PhoneNormalizer) regionOf(number *phonenumbers.PhoneNumber) string {
+	if region := phonenumbers.GetRegionCodeForNumber(number); region != "" && region != "ZZ" {
+		return region
+	}
+	return n.Country
+}
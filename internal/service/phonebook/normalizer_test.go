@@ -0,0 +1,44 @@
+package phonebook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhoneNormalizer_Normalize(t *testing.T) {
+	n := NewPhoneNormalizer("FR", PhoneValidationStrict)
+
+	for _, raw := range []string{"07 01 02 03 00", "+33701020300", "0033-7-01-02-03-00", "07.01.02.03.00"} {
+		e164, err := n.Normalize(raw)
+		assert.NoError(t, err, raw)
+		assert.Equal(t, "+33701020300", e164, raw)
+	}
+
+	e164, err := n.Normalize("+1 650-253-0000")
+	assert.NoError(t, err)
+	assert.Equal(t, "+16502530000", e164)
+}
+
+func TestPhoneNormalizer_Impossible(t *testing.T) {
+	n := NewPhoneNormalizer("FR", PhoneValidationStrict)
+
+	for _, raw := range []string{"", "not a phone", "071111100001", "0701"} {
+		_, err := n.Normalize(raw)
+		assert.Error(t, err, raw)
+	}
+
+	lenient := NewPhoneNormalizer("FR", PhoneValidationLenient)
+	e164, err := lenient.Normalize("071111100001")
+	assert.NoError(t, err)
+	assert.Empty(t, e164)
+}
+
+func TestParseCountry(t *testing.T) {
+	country, err := ParseCountry("be")
+	assert.NoError(t, err)
+	assert.Equal(t, "BE", country)
+
+	_, err = ParseCountry("XX")
+	assert.Error(t, err)
+}
@@ -2,73 +2,274 @@ package phonebook
 
 import (
 	"context"
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"go-csv-import/internal/logger"
 	"go-csv-import/internal/model"
+	"go-csv-import/internal/repository"
+	"strings"
+	"time"
 )
 
-func (c *ContactUploader) combine(header []string, row []string) (map[string]string, error) {
-	if len(header) != len(row) {
-		return nil, errors.New("header and row slices mismatch")
-	}
+/*
+readHeader reads the column headers from the CSV reader.
 
-	r := make(map[string]string, len(header))
-	for i, k := range header {
-		r[k] = row[i]
+When the dialect has no header, the first record is data: headers are named by position.
+*/
+func readHeader(reader *csv.Reader, dialect Dialect) ([]string, error) {
+	record, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
+	record = dialect.Restore(record)
 
-	logger.Trace("Row combined", "row", fmt.Sprintf("%#v", r))
-	return r, nil
+	if dialect.HasHeader {
+		return record, nil
+	}
+	return syntheticHeader(len(record)), nil
 }
 
-func (c *ContactUploader) createContactFromRow(file *FilePart, header []string, row []string) (*model.Contact, error) {
-	r, err := c.combine(header, row)
+// createContactFromRow maps a record to a contact, then normalizes and validates it.
+func (c *ContactUploader) createContactFromRow(message *FileMessage, file *FilePart, mapping *Mapping, row []string) (*model.Contact, error) {
+	contact, err := c.mapContact(message, file, mapping, row)
 	if err != nil {
-		return &model.Contact{}, err
+		return contact, err
 	}
+	return contact, c.validateContact(message, mapping, row, contact)
+}
 
-	required := []string{"Phone", "Firstname", "Lastname"}
-	for i := 0; i < len(required); i++ {
-		key := required[i]
-		if _, exists := r[key]; exists {
-			continue
-		} else {
-			return &model.Contact{}, fmt.Errorf("columns <%s> is missing", key)
-		}
+// mapContact maps the values of a record to the fields of a contact.
+func (c *ContactUploader) mapContact(message *FileMessage, file *FilePart, mapping *Mapping, row []string) (*model.Contact, error) {
+	if len(mapping.Header) != len(row) {
+		return &model.Contact{}, errors.New("header and row slices mismatch")
 	}
 
 	return &model.Contact{
 		ReqId:     file.Uuid,
-		Phone:     r["Phone"],
-		Firstname: r["Firstname"],
-		Lastname:  r["Lastname"],
+		Phonebook: message.Phonebook,
+		Phone:     mapping.Value(row, FieldPhone),
+		Firstname: mapping.Value(row, FieldFirstname),
+		Lastname:  mapping.Value(row, FieldLastname),
+		Email:     mapping.Value(row, FieldEmail),
+		Company:   mapping.Value(row, FieldCompany),
+		Title:     mapping.Value(row, FieldTitle),
 	}, nil
 }
 
-func (c *ContactUploader) handleBatchInsert(ctx context.Context, file *FilePart, batch *Batch, header []string, row []string, force bool) error {
+// validateContact normalizes the phone number, validates the email and converts the custom fields of a mapped contact.
+func (c *ContactUploader) validateContact(message *FileMessage, mapping *Mapping, row []string, contact *model.Contact) error {
+	e164, err := message.normalizer.Normalize(contact.Phone)
+	if err != nil {
+		return err
+	}
+
+	email, err := ValidateEmail(contact.Email)
+	if err != nil {
+		return err
+	}
+
+	attributes, err := mapping.Attributes(row)
+	if err != nil {
+		return err
+	}
+	contact.PhoneE164, contact.Email, contact.Attributes = e164, email, attributes
+
+	// Contacts without normalized phone cannot be matched, so they are always inserted.
+	// Contacts of insert imports are keyed too, so that later imports match them.
+	if e164 != "" {
+		contact.NaturalKey = model.NewNaturalKey(message.Phonebook, e164)
+	}
+	return nil
+}
+
+/*
+rejectRow handles an invalid row.
+
+The row error is returned to abandon the FilePart, unless the import continues on error:
+the row is then recorded as rejected and the next rows are processed.
+*/
+func (c *ContactUploader) rejectRow(message *FileMessage, file *FilePart, rowErr *RowError) error {
+	rowErr.Entry = file.Entry
+	if !message.ContinueOnError || message.rejects == nil {
+		return rowErr
+	}
+
+	logger.Debug("Row rejected", "line", rowErr.Line, "error", rowErr.Err)
+	if err := message.rejects.Write(rowErr); err != nil {
+		return NewFileError(message.rejects.FilePath, fmt.Errorf("failed to write rejected row: %w", err))
+	}
+	c.ProgressStore.Reject(message.Uuid, 1)
+	if file.Entry != "" {
+		c.ProgressStore.IncrementEntry(message.Uuid, file.Entry, 1)
+	}
+
+	return nil
+}
+
+// formatRecord writes back a record with the dialect of its file, to report its raw content.
+func formatRecord(record []string, dialect Dialect) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Comma = dialect.Delimiter
+	w.Write(record)
+	w.Flush()
+	return strings.TrimRight(b.String(), "\r\n")
+}
+
+func (c *ContactUploader) handleBatchInsert(ctx context.Context, message *FileMessage, file *FilePart, batch *Batch, force bool) error {
 	var err error
-	if len(row) > 0 {
-		c, err := c.createContactFromRow(file, header, row)
-		if err != nil {
+	size := c.HttpConfig.BatchInsert
+	if message.sizer != nil {
+		size = message.sizer.Size()
+	}
+
+	// The last checkpoint of a FilePart is committed even without contacts, to mark it as done
+	if batch.IsReached(size) || (force && (batch.Length > 0 || file.checkpoint != nil)) {
+		//time.Sleep(6 * time.Second)
+		logger.Trace("Batch insert contacts", "total", batch.Length, "force", force, "mode", message.Mode)
+		if err := c.insertBatch(ctx, message, file, batch, force); err != nil {
 			return err
 		}
-		logger.Trace("Contact model created", "contact", fmt.Sprintf("%#v", c))
+		batch.Reset()
+	}
 
-		batch.Append(c)
+	return err
+}
+
+/*
+insertBatch writes the contacts of a batch, by queries of the batch size, kept under max_allowed_packet.
+
+A query failing on a lock wait timeout or a deadlock shrinks the batch size: its contacts and the next ones
+are written again by smaller queries, until the batch has failed maxBatchAttempts times.
+A query of an insert import failing on a natural key taken meanwhile by a concurrent import is written again as well.
+Each query commits the checkpoint of the rows read until its last contact.
+*/
+func (c *ContactUploader) insertBatch(ctx context.Context, message *FileMessage, file *FilePart, batch *Batch, force bool) error {
+	contacts, reads := batch.Contacts, batch.Reads
+	size := uint(len(contacts))
+	if message.sizer != nil && len(contacts) > 0 {
+		size = min(size, message.sizer.Estimate(contacts))
 	}
 
-	if batch.IsReached(c.HttpConfig.BatchInsert) || (force && batch.Length > 0) {
-		//time.Sleep(6 * time.Second)
-		logger.Trace("Batch insert contacts", "total", batch.Length, "force", force)
-		err = c.Repository.InsertBatch(ctx, batch.Contacts)
-		if err != nil {
+	for attempt := 1; ; {
+		n := min(int(size), len(contacts))
+		read, done := file.read, force
+		if n < len(contacts) {
+			read, done = reads[n-1], false
+		}
+
+		if err := c.Pool.AcquireWriter(ctx); err != nil {
 			return err
 		}
+		start := time.Now()
+		res, err := c.saveBatch(ctx, message, file, contacts[:n], read, done)
+		c.Pool.ReleaseWriter()
+		if message.sizer != nil && n > 0 {
+			if adjusted, changed := message.sizer.Observe(contacts[:n], time.Since(start), err); changed {
+				c.ProgressStore.SetBatchSize(file.Uuid, int64(adjusted))
+				logger.Debug("Batch size adjusted", "size", adjusted, "latency", time.Since(start), "error", err)
+			}
+		}
 
-		c.ProgressStore.Increment(file.Uuid, int64(batch.Length))
-		batch.Reset()
+		if err != nil {
+			locked := repository.IsLockError(err) && message.sizer != nil
+			taken := repository.IsDuplicateKeyError(err) && message.Mode.IsInsert()
+			if !(locked || taken) || attempt >= maxBatchAttempts {
+				return err
+			}
+			attempt++
+			if locked {
+				size = min(message.sizer.Size(), uint(max(n/2, 1)))
+			}
+			logger.Warn("Retrying batch", "uuid", file.Uuid, "size", size, "attempt", attempt, "error", err)
+			select {
+			case <-time.After(time.Duration(attempt) * batchRetryDelay):
+			case <-ctx.Done():
+				return err
+			}
+			continue
+		}
+
+		c.ProgressStore.IncrementBatch(file.Uuid, res.Inserted, res.Updated, res.Skipped)
+		if file.Entry != "" {
+			c.ProgressStore.IncrementEntry(file.Uuid, file.Entry, res.Inserted+res.Updated+res.Skipped)
+		}
+		contacts, reads = contacts[n:], reads[n:]
+		if len(contacts) == 0 {
+			return nil
+		}
+		if message.sizer != nil {
+			size = message.sizer.Size()
+		}
+	}
+}
+
+/*
+saveBatch writes the contacts of a FilePart following the import mode, with the loader of the import,
+the last of them being the read-th row read from the FilePart.
+
+The checkpoint of the FilePart is saved within the same transaction: a resumed import skips
+the rows read before the last committed batch, so that they are neither lost nor written twice.
+Rows rejected before the checkpoint are written to the rejected rows file before the transaction commits.
+*/
+func (c *ContactUploader) saveBatch(ctx context.Context, message *FileMessage, file *FilePart, contacts []*model.Contact, read int, done bool) (repository.BatchResult, error) {
+	if message.DryRun {
+		return c.dryRunBatch(ctx, message, contacts)
+	}
+	if file.checkpoint == nil {
+		return c.writeBatch(ctx, message, contacts)
 	}
 
-	return err
+	var res repository.BatchResult
+	checkpoint := *file.checkpoint
+	err := repository.Transaction(ctx, func(ctx context.Context) error {
+		if len(contacts) > 0 {
+			var err error
+			if res, err = c.writeBatch(ctx, message, contacts); err != nil {
+				return err
+			}
+		}
+
+		checkpoint.ReadRows = read
+		checkpoint.Inserted += res.Inserted
+		checkpoint.Updated += res.Updated
+		checkpoint.Skipped += res.Skipped
+		checkpoint.Done = done
+
+		// Rows rejected before the checkpoint must not be lost by a crash once it is committed
+		if message.rejects != nil {
+			if err := message.rejects.Flush(); err != nil {
+				return NewFileError(message.rejects.FilePath, fmt.Errorf("failed to write rejected rows: %w", err))
+			}
+		}
+		return c.Checkpoints.Save(ctx, &checkpoint)
+	})
+	if err != nil {
+		return repository.BatchResult{}, err
+	}
+	*file.checkpoint = checkpoint
+	return res, nil
+}
+
+// errDryRun rolls back the transaction of a batch written by a dry run.
+var errDryRun = errors.New("dry run rolled back")
+
+/*
+dryRunBatch writes the contacts of a batch of a dry run within a transaction which is rolled back at once,
+so that the locks taken on contacts are released after each batch instead of blocking the imports into the same phonebook.
+*/
+func (c *ContactUploader) dryRunBatch(ctx context.Context, message *FileMessage, contacts []*model.Contact) (repository.BatchResult, error) {
+	var res repository.BatchResult
+	err := repository.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if res, err = c.writeBatch(ctx, message, contacts); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		return res, nil
+	}
+	return repository.BatchResult{}, err
 }
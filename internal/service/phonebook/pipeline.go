@@ -0,0 +1,320 @@
+package phonebook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/handlers/worker"
+	"go-csv-import/internal/logger"
+	"go-csv-import/internal/model"
+	"io"
+	"sync"
+	"time"
+)
+
+// Stages of the pipeline importing the records of a FilePart, whose activity is reported with the progress of the import.
+const (
+	StageRead     = "read"     // Records read from the file
+	StageParse    = "parse"    // Records mapped to the fields of contacts
+	StageValidate = "validate" // Phone numbers normalized, emails and custom fields validated
+	StageWrite    = "write"    // Contacts written to the database by batch, invalid rows rejected
+)
+
+// Stages lists the stages of the import pipeline, in processing order.
+var Stages = []string{StageRead, StageParse, StageValidate, StageWrite}
+
+// pipelineRow is a record of a FilePart passed from stage to stage.
+type pipelineRow struct {
+	seq     int      // Position of the record within the FilePart, as rows are written in reading order
+	line    int      // Line of the record within the FilePart
+	raw     string   // Raw content of the record, to report it when rejected
+	record  []string // Values of the record, ordered like the header
+	contact *model.Contact
+	err     *RowError // Invalid row, passed on by the next stages for the writer to reject it in order
+}
+
+/*
+pipeline imports the records of a FilePart through stages connected by bounded queues:
+
+	read → parse → validate → write
+
+Records are read by a single goroutine, then mapped and validated by the goroutines of their stages.
+The writer batches contacts in reading order, so that the checkpoint of each batch counts the rows read before it,
+and writes each batch with a writer slot of the worker pool.
+
+A full queue blocks the stage before it, and the rows read but not yet written are bounded:
+a slow database slows down the reading of the file instead of buffering it.
+*/
+type pipeline struct {
+	uploader *ContactUploader
+	message  *FileMessage
+	file     *FilePart
+	mapping  *Mapping
+	reader   RecordReader
+
+	parsers    int                              // Goroutines of the parse stage
+	validators int                              // Goroutines of the validate stage
+	queue      int                              // Rows waiting before each stage
+	inflight   chan struct{}                    // Rows read and not yet written
+	stages     map[string]*worker.StageProgress // Activity of the stages, nil when the progress of the import is unknown
+	start      time.Time
+}
+
+// newPipeline creates the pipeline of a FilePart whose records are read from reader, with the stage settings of the worker.
+func (c *ContactUploader) newPipeline(message *FileMessage, file *FilePart, mapping *Mapping, reader RecordReader) *pipeline {
+	p := &pipeline{
+		uploader:   c,
+		message:    message,
+		file:       file,
+		mapping:    mapping,
+		reader:     reader,
+		parsers:    max(int(c.HttpConfig.StageParsers), 1),
+		validators: max(int(c.HttpConfig.StageValidators), 1),
+		queue:      max(int(c.HttpConfig.StageQueue), 1),
+		stages:     make(map[string]*worker.StageProgress, len(Stages)),
+		start:      time.Now(),
+	}
+
+	// Rows waiting for an earlier row to be written are bounded as well
+	p.inflight = make(chan struct{}, 3*p.queue+p.parsers+p.validators)
+	for _, stage := range Stages {
+		p.stages[stage] = c.ProgressStore.Stage(message.Uuid, stage)
+	}
+	return p
+}
+
+// run imports the records of the FilePart, and returns the error which stopped a stage, if any.
+func (p *pipeline) run(ctx context.Context) error {
+	stageCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	read := make(chan *pipelineRow, p.queue)
+	parsed := make(chan *pipelineRow, p.queue)
+	validated := make(chan *pipelineRow, p.queue)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(read)
+		if err := p.read(stageCtx, read); err != nil {
+			cancel(err)
+		}
+	}()
+	p.runStage(stageCtx, &wg, StageParse, p.parsers, read, parsed, StageValidate, p.parse)
+	p.runStage(stageCtx, &wg, StageValidate, p.validators, parsed, validated, StageWrite, p.validate)
+
+	err := p.write(ctx, stageCtx, validated)
+	cancel(err)
+	wg.Wait()
+
+	// Rows left in the queues of a stopped pipeline are not waiting anymore
+	p.drain(read, StageParse)
+	p.drain(parsed, StageValidate)
+	p.drain(validated, StageWrite)
+	return err
+}
+
+// read reads the records of the FilePart in order, as long as the rows in flight are under the limit.
+func (p *pipeline) read(ctx context.Context, out chan<- *pipelineRow) error {
+	stage := p.stages[StageRead]
+	stage.Start()
+	defer stage.Stop()
+
+	for seq := 0; ; seq++ {
+		select {
+		case p.inflight <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+
+		start := time.Now()
+		record, err := p.reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		row := &pipelineRow{seq: seq}
+		if err != nil {
+			var re *RecordError
+			if !errors.As(err, &re) {
+				return NewFileError(p.file.FilePath, fmt.Errorf("failed to read row: %w", err))
+			}
+			row.err = NewRowError(p.file.LineOffset+re.Line, re.Raw, fmt.Errorf("failed to read row: %w", re.Err))
+		} else {
+			// Readers only know the line and the raw content of the last record read
+			row.record, row.line, row.raw = record, p.reader.Line(), p.reader.Raw(record)
+			logger.Trace("RAW line", "line", fmt.Sprintf("%#v", record))
+		}
+		stage.Done(1, time.Since(start))
+
+		if !p.send(ctx, out, row, StageParse) {
+			return nil
+		}
+	}
+}
+
+// parse maps the values of a record to the fields of a contact.
+func (p *pipeline) parse(row *pipelineRow) {
+	contact, err := p.uploader.mapContact(p.message, p.file, p.mapping, row.record)
+	if err != nil {
+		row.err = NewRowError(p.file.LineOffset+row.line, row.raw, err)
+		return
+	}
+	row.contact = contact
+}
+
+// validate normalizes and validates a mapped contact.
+func (p *pipeline) validate(row *pipelineRow) {
+	if err := p.uploader.validateContact(p.message, p.mapping, row.record, row.contact); err != nil {
+		row.err = NewRowError(p.file.LineOffset+row.line, row.raw, err)
+		return
+	}
+	logger.Trace("Contact model created", "contact", fmt.Sprintf("%#v", row.contact))
+}
+
+// runStage starts the goroutines of a stage, which process the valid rows of in and pass every row to the next stage.
+// The queue of the next stage is closed once they have all ended.
+func (p *pipeline) runStage(ctx context.Context, wg *sync.WaitGroup, name string, workers int, in <-chan *pipelineRow, out chan<- *pipelineRow, next string, process func(*pipelineRow)) {
+	stage := p.stages[name]
+
+	var stageWg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		stageWg.Add(1)
+		go func() {
+			defer stageWg.Done()
+			stage.Start()
+			defer stage.Stop()
+
+			for row := range in {
+				stage.Dequeue()
+				if row.err == nil {
+					start := time.Now()
+					process(row)
+					stage.Done(1, time.Since(start))
+				}
+				if !p.send(ctx, out, row, next) {
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		stageWg.Wait()
+		close(out)
+	}()
+}
+
+// send queues a row for the next stage, and tells whether it has been queued before the pipeline stopped.
+func (p *pipeline) send(ctx context.Context, out chan<- *pipelineRow, row *pipelineRow, next string) bool {
+	stage := p.stages[next]
+	stage.Enqueue()
+	select {
+	case out <- row:
+		return true
+	case <-ctx.Done():
+		stage.Dequeue()
+		return false
+	}
+}
+
+// drain empties the closed queue of a stage.
+func (p *pipeline) drain(queue <-chan *pipelineRow, name string) {
+	for range queue {
+		p.stages[name].Dequeue()
+	}
+}
+
+/*
+write rejects the invalid rows and writes the contacts by batch, in reading order,
+then forces the last batch once every row has been processed.
+
+Rows processed out of order by the previous stages wait for the rows read before them.
+*/
+func (p *pipeline) write(ctx context.Context, stageCtx context.Context, in <-chan *pipelineRow) error {
+	stage := p.stages[StageWrite]
+	stage.Start()
+	defer stage.Stop()
+
+	batch := NewBatch()
+	pending := make(map[int]*pipelineRow)
+	next := 0
+
+	for {
+		var row *pipelineRow
+		select {
+		case <-stageCtx.Done():
+			return p.failure(ctx, stageCtx)
+		case r, ok := <-in:
+			if !ok {
+				return p.flush(ctx, stageCtx, batch)
+			}
+			row = r
+		}
+		stage.Dequeue()
+
+		pending[row.seq] = row
+		for r, ok := pending[next]; ok; r, ok = pending[next] {
+			delete(pending, next)
+			next++
+
+			start := time.Now()
+			err := p.writeRow(stageCtx, batch, r)
+			stage.Done(1, time.Since(start))
+			<-p.inflight
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeRow rejects an invalid row, or appends its contact to the batch, written once full.
+func (p *pipeline) writeRow(ctx context.Context, batch *Batch, row *pipelineRow) error {
+	p.file.read++
+	if row.err != nil {
+		if err := p.uploader.rejectRow(p.message, p.file, row.err); err != nil {
+			return NewFileError(p.file.FilePath, err)
+		}
+		return nil
+	}
+
+	batch.Append(row.contact, p.file.read)
+	if err := p.uploader.handleBatchInsert(ctx, p.message, p.file, batch, false); err != nil {
+		return db.NewDbError(err)
+	}
+
+	p.file.TotalRows++
+	p.file.ProcessTime = time.Since(p.start)
+	return nil
+}
+
+// flush writes the last batch of the FilePart, unless a stage has failed.
+func (p *pipeline) flush(ctx context.Context, stageCtx context.Context, batch *Batch) error {
+	if err := p.failure(ctx, stageCtx); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err := p.uploader.handleBatchInsert(stageCtx, p.message, p.file, batch, true)
+	p.stages[StageWrite].Done(0, time.Since(start))
+	if err != nil {
+		return db.NewDbError(fmt.Errorf("error while forcing insert batch contacts: %w", err))
+	}
+	return nil
+}
+
+// failure returns the error which stopped the stages: the end of the FilePart context, or the failure of a stage.
+func (p *pipeline) failure(ctx context.Context, stageCtx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w on FilePart", err)
+	}
+	if stageCtx.Err() != nil {
+		return context.Cause(stageCtx)
+	}
+	return nil
+}
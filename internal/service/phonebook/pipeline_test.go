@@ -0,0 +1,140 @@
+package phonebook
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"go-csv-import/internal/config"
+	"go-csv-import/internal/handlers/worker"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sliceReader reads records from memory, a record per line, and fails with err once they have been read.
+type sliceReader struct {
+	records [][]string
+	line    int
+	err     error
+}
+
+func (r *sliceReader) Read() ([]string, error) {
+	if r.line == len(r.records) {
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, io.EOF
+	}
+	r.line++
+	return r.records[r.line-1], nil
+}
+
+func (r *sliceReader) Line() int {
+	return r.line
+}
+
+func (r *sliceReader) Raw(record []string) string {
+	return strings.Join(record, ",")
+}
+
+// newTestPipeline creates the pipeline of a FilePart rejecting its invalid rows, whose contacts are never written.
+func newTestPipeline(t *testing.T, reader RecordReader) (*pipeline, *FileMessage, *FilePart) {
+	mapping, err := ResolveMapping([]string{"phone", "lastname", "firstname"}, nil)
+	assert.NoError(t, err)
+
+	store := worker.NewMessageProgressStore()
+	store.Init("uuid", 1000)
+	store.InitStages("uuid", Stages...)
+
+	message := &FileMessage{
+		Uuid:            "uuid",
+		FilePath:        filepath.Join(t.TempDir(), "contacts.csv"),
+		ContinueOnError: true,
+		normalizer:      NewPhoneNormalizer("FR", PhoneValidationStrict),
+	}
+	message.rejects = NewRejectWriter(message)
+
+	c := &ContactUploader{
+		HttpConfig:    &config.HttpConfig{BatchInsert: 3000, StageParsers: 3, StageValidators: 4, StageQueue: 2},
+		ProgressStore: store,
+	}
+	file := &FilePart{Uuid: "uuid", FilePath: message.FilePath, LineOffset: 1}
+	return c.newPipeline(message, file, mapping, reader), message, file
+}
+
+func TestPipeline_RejectsInReadingOrder(t *testing.T) {
+	reader := &sliceReader{}
+	for i := 0; i < 500; i++ {
+		reader.records = append(reader.records, []string{"invalid-" + strconv.Itoa(i), "Doe", "John"})
+	}
+	reader.records[10] = []string{"0701020300"} // Rejected by the parse stage
+
+	p, message, file := newTestPipeline(t, reader)
+	assert.NoError(t, p.run(context.Background()))
+	assert.NoError(t, message.rejects.Close())
+	assert.Equal(t, 500, file.read)
+	assert.Equal(t, 0, file.TotalRows)
+
+	f, err := os.Open(message.rejects.FilePath)
+	assert.NoError(t, err)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, rows, 501)
+	for i, row := range rows[1:] {
+		assert.Equal(t, strconv.Itoa(i+2), row[0])
+	}
+	assert.Equal(t, "header and row slices mismatch", rows[11][1])
+
+	resp, _, ok := p.uploader.ProgressStore.Response("uuid")
+	assert.True(t, ok)
+	assert.EqualValues(t, 500, resp.Rejected)
+	assert.EqualValues(t, 500, resp.Stages[StageRead].Processed)
+	assert.EqualValues(t, 500, resp.Stages[StageParse].Processed)
+	assert.EqualValues(t, 499, resp.Stages[StageValidate].Processed)
+	assert.EqualValues(t, 500, resp.Stages[StageWrite].Processed)
+	for _, stage := range Stages {
+		assert.Zero(t, resp.Stages[stage].Queued, stage)
+		assert.Zero(t, resp.Stages[stage].Workers, stage)
+	}
+}
+
+func TestPipeline_ReadError(t *testing.T) {
+	failure := errors.New("disk failure")
+	reader := &sliceReader{records: [][]string{{"invalid", "Doe", "John"}}, err: failure}
+
+	p, _, file := newTestPipeline(t, reader)
+	err := p.run(context.Background())
+
+	var fileErr *FileError
+	assert.ErrorAs(t, err, &fileErr)
+	assert.ErrorIs(t, err, failure)
+	assert.LessOrEqual(t, file.read, 1)
+}
+
+func TestPipeline_RowErrorStopsImport(t *testing.T) {
+	reader := &sliceReader{records: [][]string{{"invalid", "Doe", "John"}, {"0701020300", "Doe", "Jane"}}}
+
+	p, message, _ := newTestPipeline(t, reader)
+	message.ContinueOnError = false
+	err := p.run(context.Background())
+
+	var rowErr *RowError
+	assert.ErrorAs(t, err, &rowErr)
+	assert.Equal(t, 2, rowErr.Line)
+}
+
+func TestPipeline_Cancelled(t *testing.T) {
+	reader := &sliceReader{records: [][]string{{"invalid", "Doe", "John"}}}
+
+	p, _, _ := newTestPipeline(t, reader)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, p.run(ctx), context.Canceled)
+}
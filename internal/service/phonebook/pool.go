@@ -0,0 +1,176 @@
+package phonebook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+WorkerPool limits the FileParts parsed and the batches written at once by the worker, whatever the number of imports.
+
+Each FilePart holds a parsing slot for each goroutine of its parse and validate stages, as they share the CPU cores:
+a FilePart holding more slots than the pool still runs, alone.
+Parsing slots are granted to the imports in turn, so that an import split into many FileParts
+does not hold every slot while another one waits: each import gets its share as soon as a slot is released.
+An import never runs more FileParts at once than its max parallelism.
+*/
+type WorkerPool struct {
+	Parsers   int // Max number of parsing slots held at once
+	PartSlots int // Parsing slots held by each FilePart
+	Writers   int // Max number of batches written to the database at once
+
+	mu      sync.Mutex
+	running int           // Parsing slots held by the FileParts being parsed
+	imports []*PoolImport // Imports using the pool, in turn order
+	next    int           // Position of the import served first by the next slot
+
+	writers chan struct{}
+}
+
+// PoolImport is an import sharing the WorkerPool.
+type PoolImport struct {
+	Uuid           string
+	MaxParallelism int // Max number of FileParts of the import parsed at once, unlimited when 0
+
+	running int             // FileParts being parsed, guarded by the pool mutex
+	waiting []chan struct{} // FileParts waiting for a slot, in arrival order, guarded by the pool mutex
+}
+
+func NewWorkerPool(parsers int, partSlots int, writers int) *WorkerPool {
+	return &WorkerPool{
+		Parsers:   parsers,
+		PartSlots: max(partSlots, 1),
+		Writers:   writers,
+		writers:   make(chan struct{}, writers),
+	}
+}
+
+// MaxParts returns the max number of FileParts parsed at once.
+func (p *WorkerPool) MaxParts() int {
+	return max(p.Parsers/p.PartSlots, 1)
+}
+
+// Register adds an import to the pool, before its FileParts acquire slots.
+func (p *WorkerPool) Register(uuid string, maxParallelism int) *PoolImport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	imp := &PoolImport{Uuid: uuid, MaxParallelism: maxParallelism}
+	p.imports = append(p.imports, imp)
+	return imp
+}
+
+// Unregister removes an import from the pool, once its FileParts have released their slots.
+func (p *WorkerPool) Unregister(imp *PoolImport) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if i := slices.Index(p.imports, imp); i >= 0 {
+		p.imports = slices.Delete(p.imports, i, i+1)
+		if p.next > i {
+			p.next--
+		}
+	}
+}
+
+// Acquire waits for a slot to parse a FilePart of the import. The slot must be released once the FilePart is processed.
+func (p *WorkerPool) Acquire(ctx context.Context, imp *PoolImport) error {
+	granted := make(chan struct{})
+
+	p.mu.Lock()
+	imp.waiting = append(imp.waiting, granted)
+	p.dispatch()
+	p.mu.Unlock()
+
+	select {
+	case <-granted:
+		return nil
+	case <-ctx.Done():
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i := slices.Index(imp.waiting, granted); i >= 0 {
+		imp.waiting = slices.Delete(imp.waiting, i, i+1)
+		return ctx.Err()
+	}
+
+	// The slot has been granted while the context was cancelled
+	p.release(imp)
+	return ctx.Err()
+}
+
+// Release frees the slot of a FilePart of the import, for the next import in turn.
+func (p *WorkerPool) Release(imp *PoolImport) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.release(imp)
+}
+
+func (p *WorkerPool) release(imp *PoolImport) {
+	imp.running--
+	p.running -= p.PartSlots
+	p.dispatch()
+}
+
+// dispatch grants the free slots to the waiting FileParts, one import after the other.
+func (p *WorkerPool) dispatch() {
+	for p.running == 0 || p.running+p.PartSlots <= p.Parsers {
+		imp := p.nextImport()
+		if imp == nil {
+			return
+		}
+
+		granted := imp.waiting[0]
+		imp.waiting = imp.waiting[1:]
+		imp.running++
+		p.running += p.PartSlots
+		close(granted)
+	}
+}
+
+// nextImport returns the next import in turn with a FilePart waiting and under its max parallelism, or nil.
+func (p *WorkerPool) nextImport() *PoolImport {
+	for n := 0; n < len(p.imports); n++ {
+		i := (p.next + n) % len(p.imports)
+		imp := p.imports[i]
+		if len(imp.waiting) > 0 && (imp.MaxParallelism == 0 || imp.running < imp.MaxParallelism) {
+			p.next = (i + 1) % len(p.imports)
+			return imp
+		}
+	}
+	return nil
+}
+
+// AcquireWriter waits for a slot to write a batch to the database. The slot must be released once the batch is written.
+func (p *WorkerPool) AcquireWriter(ctx context.Context) error {
+	select {
+	case p.writers <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReleaseWriter frees the slot of a batch written to the database.
+func (p *WorkerPool) ReleaseWriter() {
+	<-p.writers
+}
+
+// ParseMaxParallelism validates the max number of FileParts of an upload processed at once, unlimited when empty.
+func ParseMaxParallelism(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid max_parallelism <%s>: expected a positive number", value)
+	}
+	return n, nil
+}
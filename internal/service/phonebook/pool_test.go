@@ -0,0 +1,115 @@
+package phonebook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// acquireAsync acquires a slot for the import in a goroutine, and returns the channel notified once granted.
+func acquireAsync(p *WorkerPool, imp *PoolImport) <-chan struct{} {
+	granted := make(chan struct{})
+	go func() {
+		if p.Acquire(context.Background(), imp) == nil {
+			close(granted)
+		}
+	}()
+	return granted
+}
+
+// isGranted tells whether the slot is granted within a short delay.
+func isGranted(granted <-chan struct{}) bool {
+	select {
+	case <-granted:
+		return true
+	case <-time.After(50 * time.Millisecond):
+		return false
+	}
+}
+
+func TestWorkerPool_Fairness(t *testing.T) {
+	pool := NewWorkerPool(2, 1, 1)
+	large := pool.Register("large", 0)
+	small := pool.Register("small", 0)
+
+	// The first import takes every free slot, then waits with more FileParts
+	assert.NoError(t, pool.Acquire(context.Background(), large))
+	assert.NoError(t, pool.Acquire(context.Background(), large))
+	largeWaiting := acquireAsync(pool, large)
+	assert.False(t, isGranted(largeWaiting))
+
+	// The other import is served first, although it waits for less time
+	smallWaiting := acquireAsync(pool, small)
+	time.Sleep(10 * time.Millisecond)
+	pool.Release(large)
+	assert.True(t, isGranted(smallWaiting))
+	assert.False(t, isGranted(largeWaiting))
+
+	pool.Release(small)
+	assert.True(t, isGranted(largeWaiting))
+}
+
+func TestWorkerPool_MaxParallelism(t *testing.T) {
+	pool := NewWorkerPool(4, 1, 1)
+	imp := pool.Register("limited", 1)
+
+	assert.NoError(t, pool.Acquire(context.Background(), imp))
+	waiting := acquireAsync(pool, imp)
+	assert.False(t, isGranted(waiting))
+
+	pool.Release(imp)
+	assert.True(t, isGranted(waiting))
+}
+
+func TestWorkerPool_AcquireCancelled(t *testing.T) {
+	pool := NewWorkerPool(1, 1, 1)
+	imp := pool.Register("cancelled", 0)
+	assert.NoError(t, pool.Acquire(context.Background(), imp))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Acquire(ctx, imp), context.DeadlineExceeded)
+
+	// The cancelled FilePart does not hold a slot
+	pool.Release(imp)
+	assert.NoError(t, pool.Acquire(context.Background(), imp))
+}
+
+func TestWorkerPool_PartSlots(t *testing.T) {
+	pool := NewWorkerPool(7, 3, 1)
+	assert.Equal(t, 2, pool.MaxParts())
+	imp := pool.Register("stages", 0)
+
+	// Each FilePart holds the slots of its stage goroutines
+	assert.NoError(t, pool.Acquire(context.Background(), imp))
+	assert.NoError(t, pool.Acquire(context.Background(), imp))
+	waiting := acquireAsync(pool, imp)
+	assert.False(t, isGranted(waiting))
+	pool.Release(imp)
+	assert.True(t, isGranted(waiting))
+
+	// A FilePart holding more slots than the pool runs alone
+	pool = NewWorkerPool(2, 3, 1)
+	assert.Equal(t, 1, pool.MaxParts())
+	imp = pool.Register("alone", 0)
+	assert.NoError(t, pool.Acquire(context.Background(), imp))
+	waiting = acquireAsync(pool, imp)
+	assert.False(t, isGranted(waiting))
+	pool.Release(imp)
+	assert.True(t, isGranted(waiting))
+}
+
+func TestParseMaxParallelism(t *testing.T) {
+	n, err := ParseMaxParallelism("")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = ParseMaxParallelism("2")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	_, err = ParseMaxParallelism("0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package phonebook
+
+import (
+	"errors"
+	"fmt"
+	"go-csv-import/internal/model"
+	"io"
+)
+
+// previewMaxErrors is the maximum number of rejected rows listed by a preview.
+const previewMaxErrors = 100
+
+// Preview is the result of an import validated without writing any contact.
+type Preview struct {
+	Contacts []*model.Contact // Contacts of the first rows
+	Summary  PreviewSummary
+}
+
+// PreviewSummary counts the valid and rejected rows of a preview.
+type PreviewSummary struct {
+	Total    int            `json:"total"`            // Rows of the file
+	Checked  int            `json:"checked"`          // Rows validated: the whole file, or its first rows when sampled
+	Valid    int            `json:"valid"`            // Rows which would be imported
+	Rejected int            `json:"rejected"`         // Rows which would be rejected
+	Sampled  bool           `json:"sampled"`          // Only the first rows have been validated
+	Errors   []PreviewError `json:"errors,omitempty"` // First rejected rows
+}
+
+// PreviewError is a row rejected by a preview, reported like the rejected rows of an import.
+type PreviewError struct {
+	Line   string `json:"line"` // Line of the row, as "entry:line" within a zip archive
+	Reason string `json:"reason"`
+	Raw    string `json:"raw"`
+	Field  string `json:"field,omitempty"` // Custom field of the invalid value, if any
+	Code   string `json:"code,omitempty"`  // Error code of the invalid value, if any
+}
+
+/*
+Preview parses the rows of an upload as the worker would, without writing to the database nor publishing it.
+
+The contacts of the first rows are returned, and sample rows are validated: every row when sample is 0.
+The dialect, the encoding and the mapping of the file must have been resolved beforehand.
+*/
+func (p *PhonebookHandler) Preview(file *FileMessage, mapping *Mapping, rows int, sample int) (*Preview, error) {
+	c := &ContactUploader{HttpConfig: p.HttpConfig}
+
+	parts, total, err := c.splitFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error splitting file: %w", err)
+	}
+	file.mapping = mapping
+	file.normalizer = c.newPhoneNormalizer(file)
+
+	preview := &Preview{Summary: PreviewSummary{Total: total}}
+	for i := range parts {
+		if sample > 0 && preview.Summary.Checked >= sample {
+			break
+		}
+		if err := c.previewPart(file, &parts[i], preview, rows, sample); err != nil {
+			return nil, err
+		}
+	}
+	preview.Summary.Sampled = preview.Summary.Checked < total
+
+	return preview, nil
+}
+
+// previewPart validates the rows of a FilePart until the preview has checked sample rows.
+func (c *ContactUploader) previewPart(message *FileMessage, file *FilePart, preview *Preview, rows int, sample int) error {
+	mapping := message.mapping
+	if file.mapping != nil {
+		mapping = file.mapping
+	}
+
+	reader, f, err := message.openRecordReader(file, mapping.Header)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	summary := &preview.Summary
+	for sample == 0 || summary.Checked < sample {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var rowErr *RowError
+		if err != nil {
+			var re *RecordError
+			if !errors.As(err, &re) {
+				return fmt.Errorf("failed to read row: %w", err)
+			}
+			rowErr = NewRowError(file.LineOffset+re.Line, re.Raw, fmt.Errorf("failed to read row: %w", re.Err))
+		} else {
+			contact, err := c.createContactFromRow(message, file, mapping, record)
+			if err != nil {
+				rowErr = NewRowError(file.LineOffset+reader.Line(), reader.Raw(record), err)
+			} else if len(preview.Contacts) < rows {
+				preview.Contacts = append(preview.Contacts, contact)
+			}
+		}
+
+		summary.Checked++
+		if rowErr == nil {
+			summary.Valid++
+			continue
+		}
+
+		summary.Rejected++
+		if len(summary.Errors) < previewMaxErrors {
+			rowErr.Entry = file.Entry
+			summary.Errors = append(summary.Errors, newPreviewError(rowErr))
+		}
+	}
+	return nil
+}
+
+// newPreviewError reports a rejected row with the columns of the rejected rows file.
+func newPreviewError(rowErr *RowError) PreviewError {
+	line, reason, raw, field, code := rejectColumns(rowErr)
+	return PreviewError{Line: line, Reason: reason, Raw: raw, Field: field, Code: code}
+}
@@ -0,0 +1,60 @@
+package phonebook
+
+import (
+	"go-csv-import/internal/config"
+	"go-csv-import/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.csv")
+	content := "Phone;Firstname;Lastname;Tier\n" +
+		"0701020300;John;Doe;gold\n" +
+		"invalid;Jane;Smith;gold\n" +
+		"0701020302;Paul;Martin;bronze\n" +
+		"0701020303;Anna;Durand;silver\n" +
+		"0701020304;Marc;Petit;\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	dialect := DefaultDialect()
+	file := &FileMessage{
+		FilePath: path, MaxRows: 2, Dialect: &dialect, Encoding: EncodingUTF8,
+		Fields: []model.FieldDefinition{{Name: "Tier", Type: FieldTypeEnum, Values: []string{"gold", "silver"}}},
+	}
+	header, err := file.ReadHeader()
+	assert.NoError(t, err)
+	mapping, err := file.ResolveMapping(header)
+	assert.NoError(t, err)
+
+	p := &PhonebookHandler{HttpConfig: &config.HttpConfig{PhoneCountry: "FR", PhoneValidation: PhoneValidationStrict}}
+
+	// Whole file
+	preview, err := p.Preview(file, mapping, 2, 0)
+	assert.NoError(t, err)
+	assert.Len(t, preview.Contacts, 2)
+	assert.Equal(t, "+33701020300", preview.Contacts[0].PhoneE164)
+	assert.Equal(t, map[string]any{"Tier": "silver"}, preview.Contacts[1].Attributes)
+	assert.Equal(t, 5, preview.Summary.Total)
+	assert.Equal(t, 5, preview.Summary.Checked)
+	assert.Equal(t, 3, preview.Summary.Valid)
+	assert.Equal(t, 2, preview.Summary.Rejected)
+	assert.False(t, preview.Summary.Sampled)
+	if assert.Len(t, preview.Summary.Errors, 2) {
+		assert.Equal(t, "3", preview.Summary.Errors[0].Line)
+		assert.Equal(t, "4", preview.Summary.Errors[1].Line)
+		assert.Equal(t, "Tier", preview.Summary.Errors[1].Field)
+		assert.Equal(t, FieldErrorEnum, preview.Summary.Errors[1].Code)
+	}
+
+	// Sample of the first rows
+	preview, err = p.Preview(file, mapping, 10, 3)
+	assert.NoError(t, err)
+	assert.Len(t, preview.Contacts, 1)
+	assert.Equal(t, 3, preview.Summary.Checked)
+	assert.Equal(t, 2, preview.Summary.Rejected)
+	assert.True(t, preview.Summary.Sampled)
+}
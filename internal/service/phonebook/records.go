@@ -0,0 +1,185 @@
+package phonebook
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+RecordScanner splits a CSV stream into raw records, following the dialect quoting rules
+of encoding/csv, so that a quoted field holding newlines is never cut.
+
+Records have no length limit. Empty and comment lines are not records:
+they are kept within the raw bytes of the next record, so that line numbers stay unchanged
+when raw records are written to another file.
+
+The zero Dialect splits lines, like the records of JSON Lines files.
+*/
+type RecordScanner struct {
+	reader  *bufio.Reader
+	dialect Dialect
+
+	raw      []byte // Raw bytes of the current record, with the preceding empty and comment lines
+	start    int    // Line number of the first raw byte
+	lines    int    // Number of lines of the raw bytes
+	read     int    // Number of lines read from the stream
+	offset   int64  // Byte offset of the first raw byte
+	consumed int64  // Number of bytes read from the stream
+	err      error
+}
+
+// NewRecordScanner creates a RecordScanner reading UTF-8 CSV content written with the dialect.
+func NewRecordScanner(r io.Reader, dialect Dialect) *RecordScanner {
+	return &RecordScanner{
+		reader:  bufio.NewReader(r),
+		dialect: dialect,
+	}
+}
+
+// Scan advances to the next record, which is then available through Record.
+// It returns false at the end of the stream or on error.
+func (s *RecordScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	s.raw = s.raw[:0]
+	s.start = s.read + 1
+	s.lines = 0
+	s.offset = s.consumed
+
+	inRecord, inQuotes := false, false
+	for {
+		from := len(s.raw)
+		eof, err := s.readLine()
+		if err != nil {
+			s.err = err
+			return false
+		}
+		line := s.raw[from:]
+		if len(line) == 0 {
+			// End of the stream, lines skipped before are not a record
+			return inRecord
+		}
+		s.read++
+		s.lines++
+
+		if !inRecord {
+			if s.skipped(line) {
+				if eof {
+					return false
+				}
+				continue
+			}
+			inRecord = true
+		}
+
+		if inQuotes = s.scanLine(line, inQuotes); !inQuotes || eof {
+			return true
+		}
+	}
+}
+
+// Record returns the raw bytes of the current record, valid until the next call to Scan.
+func (s *RecordScanner) Record() []byte {
+	return s.raw
+}
+
+// StartLine returns the line number of the first raw byte of the current record.
+func (s *RecordScanner) StartLine() int {
+	return s.start
+}
+
+// Offset returns the byte offset, within the stream, of the first raw byte of the current record.
+func (s *RecordScanner) Offset() int64 {
+	return s.offset
+}
+
+// Consumed returns the number of bytes read from the stream, which is the end offset of the current record.
+func (s *RecordScanner) Consumed() int64 {
+	return s.consumed
+}
+
+// Lines returns the number of lines of the current record raw bytes.
+func (s *RecordScanner) Lines() int {
+	return s.lines
+}
+
+// Err returns the first error met while reading the stream.
+func (s *RecordScanner) Err() error {
+	return s.err
+}
+
+// readLine appends the next line to the raw bytes, whatever its length, and tells whether the stream has ended.
+func (s *RecordScanner) readLine() (bool, error) {
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		s.raw = append(s.raw, chunk...)
+		s.consumed += int64(len(chunk))
+		switch {
+		case err == nil:
+			return false, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF):
+			return true, nil
+		default:
+			return false, err
+		}
+	}
+}
+
+// skipped tells whether encoding/csv ignores the line when it starts a record.
+func (s *RecordScanner) skipped(line []byte) bool {
+	switch string(line) {
+	case "\n", "\r\n":
+		return true
+	}
+
+	r, _ := utf8.DecodeRune(line)
+	return s.dialect.Comment != 0 && r == s.dialect.Comment
+}
+
+// scanLine follows the quotes of the line and tells whether a quoted field is still open at its end.
+func (s *RecordScanner) scanLine(line []byte, inQuotes bool) bool {
+	quote, delimiter := s.dialect.Quote, s.dialect.Delimiter
+	fieldStart := !inQuotes
+
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		i += size
+
+		if inQuotes {
+			if r != quote {
+				continue
+			}
+
+			next, nextSize := utf8.DecodeRune(line[i:])
+			switch {
+			case next == quote:
+				i += nextSize // Escaped quote
+			case i == len(line) || next == delimiter || next == '\n' || (next == '\r' && i+1 < len(line) && line[i+1] == '\n'):
+				inQuotes = false
+			case !s.dialect.LazyQuotes:
+				// encoding/csv stops the record on the extraneous quote, and starts the next one on the next line
+				return false
+			}
+			continue
+		}
+
+		switch {
+		case r == delimiter:
+			fieldStart = true
+		case fieldStart && r == quote:
+			inQuotes, fieldStart = true, false
+		case fieldStart && s.dialect.TrimLeadingSpace && unicode.IsSpace(r):
+		default:
+			fieldStart = false
+		}
+	}
+
+	return inQuotes
+}
@@ -0,0 +1,64 @@
+package phonebook
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scanRaw returns the raw records of the content with their start line.
+func scanRaw(t *testing.T, content string, dialect Dialect) ([]string, []int) {
+	scanner := NewRecordScanner(strings.NewReader(content), dialect)
+	var records []string
+	var lines []int
+	for scanner.Scan() {
+		records = append(records, string(scanner.Record()))
+		lines = append(lines, scanner.StartLine())
+	}
+	assert.NoError(t, scanner.Err())
+	return records, lines
+}
+
+func TestRecordScanner_MultiLineFields(t *testing.T) {
+	content := "Phone;Lastname;Firstname\n0701020300;\"Doe\nof \"\"Paris\"\"\";John\r\n\n# comment\n0701020301;Smith;Jane"
+	dialect := DefaultDialect()
+	dialect.Comment = '#'
+
+	records, lines := scanRaw(t, content, dialect)
+	assert.Equal(t, []string{
+		"Phone;Lastname;Firstname\n",
+		"0701020300;\"Doe\nof \"\"Paris\"\"\";John\r\n",
+		"\n# comment\n0701020301;Smith;Jane",
+	}, records)
+	assert.Equal(t, []int{1, 2, 4}, lines)
+
+	// Records are the ones of encoding/csv
+	reader := dialect.NewReader(strings.NewReader(content))
+	all, err := reader.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, all, len(records))
+}
+
+func TestRecordScanner_Quotes(t *testing.T) {
+	dialect := DefaultDialect()
+
+	// Quotes within unquoted fields are not field delimiters
+	records, _ := scanRaw(t, "0701020300;Doe \"Jr;John\n0701020301;Smith;Jane\n", dialect)
+	assert.Len(t, records, 2)
+
+	// An extraneous quote stops the record at the end of the line
+	records, _ = scanRaw(t, "0701020300;\"Doe\" Jr;John\n0701020301;Smith;Jane\n", dialect)
+	assert.Len(t, records, 2)
+
+	dialect.LazyQuotes = true
+	records, _ = scanRaw(t, "0701020300;\"Doe\" Jr\n\";John\n0701020301;Smith;Jane\n", dialect)
+	assert.Len(t, records, 2)
+}
+
+func TestRecordScanner_LongRecord(t *testing.T) {
+	long := strings.Repeat("a", 200<<10)
+	records, _ := scanRaw(t, "Phone;Lastname\n0701020300;"+long+"\n", DefaultDialect())
+	assert.Len(t, records, 2)
+	assert.Equal(t, "0701020300;"+long+"\n", records[1])
+}
@@ -0,0 +1,120 @@
+package phonebook
+
+import (
+	"encoding/csv"
+	"errors"
+	"go-csv-import/internal/logger"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// RejectsFileName returns the name of the file listing the rows rejected by an import.
+func RejectsFileName(uuid string) string {
+	return uuid + "-rejects.csv"
+}
+
+/*
+RejectWriter records the rows rejected by an import into a CSV file.
+
+The file is created on the first rejected row, so imports without errors leave no file behind.
+It is safe for concurrent use by the goroutines processing the FileParts of the import.
+*/
+type RejectWriter struct {
+	FilePath string // File listing rejected rows
+	Count    int    // Number of rejected rows written
+	Append   bool   // Rows are appended to the file of a resumed import
+
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+}
+
+// NewRejectWriter creates a RejectWriter for the import, next to its uploaded file.
+func NewRejectWriter(file *FileMessage) *RejectWriter {
+	return &RejectWriter{
+		FilePath: filepath.Join(filepath.Dir(file.FilePath), RejectsFileName(file.Uuid)),
+	}
+}
+
+// Write appends the rejected row with its source line number, raw content and reason.
+// Rows of a zip archive are located as "entry:line".
+// Rows rejected by a custom field value also report the field and the error code.
+func (w *RejectWriter) Write(rowErr *RowError) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.writer == nil {
+		flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if w.Append {
+			flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(w.FilePath, flag, 0o666)
+		if err != nil {
+			return err
+		}
+		w.file = f
+		w.writer = csv.NewWriter(f)
+
+		i, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if i.Size() == 0 {
+			if err := w.writer.Write([]string{"line", "reason", "raw", "field", "code"}); err != nil {
+				return err
+			}
+		}
+	}
+
+	line, reason, raw, field, code := rejectColumns(rowErr)
+	w.Count++
+	return w.writer.Write([]string{line, reason, raw, field, code})
+}
+
+// rejectColumns returns the line, reason, raw content, custom field and error code of a rejected row.
+func rejectColumns(rowErr *RowError) (line string, reason string, raw string, field string, code string) {
+	line = strconv.Itoa(rowErr.Line)
+	if rowErr.Entry != "" {
+		line = rowErr.Entry + ":" + line
+	}
+
+	var fieldErr *FieldError
+	if errors.As(rowErr.Err, &fieldErr) {
+		field, code = fieldErr.Field, fieldErr.Code
+	}
+	return line, rowErr.Err.Error(), rowErr.Raw, field, code
+}
+
+// Flush writes the rejected rows buffered so far to the file.
+func (w *RejectWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.writer == nil {
+		return nil
+	}
+
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// Close flushes rejected rows to the file.
+func (w *RejectWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.writer == nil {
+		return nil
+	}
+
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		w.file.Close()
+		return err
+	}
+
+	logger.Debug("Rejected rows written", "file", w.FilePath, "total", w.Count)
+	return w.file.Close()
+}
@@ -0,0 +1,55 @@
+package phonebook
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectWriter(t *testing.T) {
+	dir := t.TempDir()
+	w := NewRejectWriter(&FileMessage{Uuid: "uuid", FilePath: filepath.Join(dir, "contacts.csv")})
+
+	// No file is created without rejected rows
+	assert.NoError(t, w.Close())
+	_, err := os.Stat(w.FilePath)
+	assert.True(t, os.IsNotExist(err))
+
+	raw := formatRecord([]string{"0701020300", "Doe; John"}, DefaultDialect())
+	assert.NoError(t, w.Write(NewRowError(3, raw, errors.New("header and row slices mismatch"))))
+	fieldErr := &FieldError{Field: "Tier", Code: FieldErrorEnum, Value: "bronze", Err: errors.New("expected one of <gold>, got <bronze>")}
+	assert.NoError(t, w.Write(NewRowError(4, "0701020301;bronze", fieldErr)))
+	assert.NoError(t, w.Close())
+
+	content, err := os.ReadFile(filepath.Join(dir, "uuid-rejects.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "line,reason,raw,field,code\n"+
+		"3,header and row slices mismatch,\"0701020300;\"\"Doe; John\"\"\",,\n"+
+		"4,\"invalid field <Tier>: expected one of <gold>, got <bronze>\",0701020301;bronze,Tier,enum\n", string(content))
+}
+
+func TestRejectWriter_Append(t *testing.T) {
+	dir := t.TempDir()
+	file := &FileMessage{Uuid: "uuid", FilePath: filepath.Join(dir, "contacts.csv")}
+
+	w := NewRejectWriter(file)
+	assert.NoError(t, w.Write(NewRowError(2, "0101", errors.New("invalid phone"))))
+	assert.NoError(t, w.Flush())
+
+	content, err := os.ReadFile(w.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "line,reason,raw,field,code\n2,invalid phone,0101,,\n", string(content))
+
+	// A resumed import keeps the rows rejected before the crash
+	resumed := NewRejectWriter(file)
+	resumed.Append = true
+	assert.NoError(t, resumed.Write(NewRowError(5, "0105", errors.New("invalid phone"))))
+	assert.NoError(t, resumed.Close())
+
+	content, err = os.ReadFile(w.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "line,reason,raw,field,code\n2,invalid phone,0101,,\n5,invalid phone,0105,,\n", string(content))
+}
@@ -25,6 +25,12 @@ type PhonebookHandler struct {
 
 // Close closes the AMQP queue and database connection.
 func (p *PhonebookHandler) Close() error {
+	if p.Uploader != nil {
+		if err := p.Uploader.Prepared.Close(); err != nil {
+			slog.Warn("Error closing prepared statements", "error", err)
+		}
+	}
+
 	err := db.Close()
 	if err != nil {
 		slog.Warn("Error closing database connection", "error", err)
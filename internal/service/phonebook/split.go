@@ -0,0 +1,207 @@
+package phonebook
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode/utf8"
+)
+
+/*
+Splits the file into ranges of records, read in place by each goroutine.
+
+Each range will have a maximum number of rows defined by file.MaxRows,
+so that each one is processed in a separate goroutine without memory issues.
+
+Record-aligned byte offsets and the total of rows are computed in a single pass over the file,
+without copying it: quoted fields holding newlines are never cut.
+
+The header is not part of the ranges, as it is resolved once for the whole file.
+
+Files which cannot be split on their raw bytes, like UTF-16 ones, are read as a single range.
+Compressed files cannot be read by ranges: each CSV content is read as a single FilePart.
+*/
+func (c *ContactUploader) splitFile(file *FileMessage) ([]FilePart, int, error) {
+	switch {
+	case file.Compression != "":
+		return c.splitArchive(file)
+	case file.Format == FormatJSON:
+		return c.splitJSONArray(file)
+	case file.Format == FormatXLSX, file.Format == FormatVCF, !file.splittable():
+		return c.wholeFile(file)
+	}
+
+	f, err := os.Open(file.FilePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	scanner := NewRecordScanner(f, file.scanDialect())
+
+	// Skip the header records, unless the file has none
+	for i := 0; i < file.headerRecords(); i++ {
+		if !scanner.Scan() {
+			return nil, 0, scanner.Err()
+		}
+	}
+
+	var parts []FilePart
+	total := 0
+	for scanner.Scan() {
+		if total%file.MaxRows == 0 {
+			if len(parts) > 0 {
+				last := &parts[len(parts)-1]
+				last.Size = scanner.Offset() - last.Offset
+			}
+
+			parts = append(parts, FilePart{
+				Uuid:       file.Uuid,
+				FilePath:   file.FilePath,
+				Encoding:   file.Encoding,
+				Offset:     scanner.Offset(),
+				LineOffset: scanner.StartLine() - 1,
+			})
+		}
+		parts[len(parts)-1].Rows++
+		total++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(parts) > 0 {
+		last := &parts[len(parts)-1]
+		last.Size = scanner.Consumed() - last.Offset
+	}
+
+	return parts, total, nil
+}
+
+// wholeFile returns the whole file as a single range, header included.
+func (c *ContactUploader) wholeFile(file *FileMessage) ([]FilePart, int, error) {
+	total, err := file.CountRows()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	i, err := os.Stat(file.FilePath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	part := FilePart{
+		Uuid:     file.Uuid,
+		FilePath: file.FilePath,
+		Encoding: file.Encoding,
+		Size:     i.Size(),
+		Header:   file.hasHeader(),
+		Rows:     total,
+	}
+	return []FilePart{part}, total, nil
+}
+
+/*
+splitArchive returns a FilePart for each CSV content of a compressed upload.
+
+Each content is decompressed once, to a file next to the upload, while its rows are counted and its own
+column mapping resolved: an archive with an invalid file or exceeding the archive limits is refused before any insert,
+and FileParts read the decompressed files.
+*/
+func (c *ContactUploader) splitArchive(file *FileMessage) ([]FilePart, int, error) {
+	entries, err := file.entries()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dir := file.entriesDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, 0, err
+	}
+
+	dialect := file.dialect()
+	var parts []FilePart
+	total := 0
+	remaining := file.MaxSize
+	for index, entry := range entries {
+		path := filepath.Join(dir, strconv.Itoa(index)+".csv")
+		header, rows, size, err := spoolEntry(entry, path, file.Encoding, dialect, remaining)
+		if err != nil {
+			return nil, 0, fmt.Errorf("entry %s: %w", entry.Name, err)
+		}
+		if file.MaxSize > 0 {
+			remaining = max(remaining-size, 1) // Zero is unlimited, the next content fails on its first byte
+		}
+
+		mapping, err := file.ResolveMapping(header)
+		if err != nil {
+			return nil, 0, fmt.Errorf("entry %s: error resolving column mapping: %w", entry.Name, err)
+		}
+
+		parts = append(parts, FilePart{
+			Uuid:     file.Uuid,
+			FilePath: path,
+			Entry:    entry.Name,
+			Encoding: file.Encoding,
+			Size:     size,
+			Header:   dialect.HasHeader,
+			Rows:     rows,
+			mapping:  mapping,
+		})
+		total += rows
+	}
+
+	return parts, total, nil
+}
+
+// countRecords counts the records of a content.
+func countRecords(r io.Reader, dialect Dialect) (int, error) {
+	scanner := NewRecordScanner(r, dialect)
+	count := 0
+	for scanner.Scan() {
+		count++
+	}
+	return count, scanner.Err()
+}
+
+// scanRecords reads the header from the first record and counts the data records, the header excluded.
+func scanRecords(r io.Reader, dialect Dialect) ([]string, int, error) {
+	scanner := NewRecordScanner(r, dialect)
+	if !scanner.Scan() {
+		return nil, 0, scanner.Err()
+	}
+
+	header, err := readHeader(dialect.NewReader(bytes.NewReader(scanner.Record())), dialect)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count := 1
+	for scanner.Scan() {
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if dialect.HasHeader {
+		count-- // Exclude header record
+	}
+	return header, count, nil
+}
+
+// splittable tells whether the records of the file can be found on its raw bytes, before transcoding.
+func (j *FileMessage) splittable() bool {
+	if j.Encoding == EncodingUTF8 {
+		return true
+	}
+
+	dialect := j.scanDialect()
+	return asciiCompatible(j.Encoding) &&
+		dialect.Delimiter < utf8.RuneSelf &&
+		dialect.Quote < utf8.RuneSelf &&
+		dialect.Comment < utf8.RuneSelf
+}
@@ -0,0 +1,92 @@
+package phonebook
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// readPart returns the records of the range with their line in the uploaded file.
+func readPart(t *testing.T, part FilePart, dialect Dialect) ([][]string, []int) {
+	f, err := part.Open()
+	assert.NoError(t, err)
+	defer f.Close()
+
+	reader := dialect.NewReader(f)
+	if part.Header {
+		_, err := reader.Read()
+		assert.NoError(t, err)
+	}
+
+	var rows [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, record)
+		lines = append(lines, part.LineOffset+line)
+	}
+	return rows, lines
+}
+
+func TestSplitFile_MultiLineFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.csv")
+	content := "Phone;Lastname;Firstname\n" +
+		"0701020300;\"Doe\nJr\";John\n" +
+		"0701020301;Smith;Jane\n" +
+		"0701020302;\"Martin\nSr\";Paul"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	dialect := DefaultDialect()
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxRows: 2, Dialect: &dialect, Encoding: EncodingUTF8}
+
+	parts, total, err := (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, parts, 2)
+
+	var rows [][]string
+	var lines []int
+	for _, part := range parts {
+		assert.False(t, part.Header)
+		r, l := readPart(t, part, dialect)
+		rows, lines = append(rows, r...), append(lines, l...)
+	}
+
+	// Fields are kept whole and lines are numbered as in the uploaded file
+	assert.Equal(t, [][]string{
+		{"0701020300", "Doe\nJr", "John"},
+		{"0701020301", "Smith", "Jane"},
+		{"0701020302", "Martin\nSr", "Paul"},
+	}, rows)
+	assert.Equal(t, []int{2, 4, 5}, lines)
+}
+
+func TestSplitFile_UTF16(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.csv")
+	content, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String(
+		"Phone;Lastname;Firstname\n0701020300;Doe;John\n0701020301;Smith;Jane\n0701020302;Martin;Paul\n")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	dialect := DefaultDialect()
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxRows: 2, Dialect: &dialect, Encoding: EncodingUTF16LE}
+
+	// UTF-16 files cannot be split on their raw bytes
+	parts, total, err := (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, parts, 1)
+	assert.True(t, parts[0].Header)
+
+	rows, lines := readPart(t, parts[0], dialect)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, []int{2, 3, 4}, lines)
+}
@@ -0,0 +1,30 @@
+package phonebook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Staging of the contacts of an import, before they are written to the contacts table.
+const (
+	StagingAtomic = "atomic" // Staged contacts are committed once every FilePart has been imported
+	StagingReview = "review" // Staged contacts wait to be committed or discarded through the API
+)
+
+// Steps of the contacts of a staged import.
+const (
+	StagingStatusLoading    = "loading"    // FileParts are being imported into the staging table
+	StagingStatusReview     = "review"     // Every FilePart has been imported, the contacts wait to be committed or discarded
+	StagingStatusCommitting = "committing" // Contacts are being moved into the contacts table
+	StagingStatusCommitted  = "committed"  // Contacts have been moved into the contacts table
+	StagingStatusDiscarded  = "discarded"  // Contacts have been removed without being committed
+)
+
+// ParseStaging validates the staging requested with an upload, contacts are written directly when empty.
+func ParseStaging(staging string) (string, error) {
+	switch s := strings.ToLower(strings.TrimSpace(staging)); s {
+	case "", StagingAtomic, StagingReview:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid staging <%s>: expected %s or %s", staging, StagingAtomic, StagingReview)
+}
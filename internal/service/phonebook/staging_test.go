@@ -0,0 +1,156 @@
+package phonebook
+
+import (
+	"context"
+	"go-csv-import/internal/config"
+	"go-csv-import/internal/db"
+	"go-csv-import/internal/handlers/worker"
+	"go-csv-import/internal/model"
+	"go-csv-import/internal/repository"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStaging(t *testing.T) {
+	staging, err := ParseStaging("")
+	assert.NoError(t, err)
+	assert.Empty(t, staging)
+
+	staging, err = ParseStaging(" Review ")
+	assert.NoError(t, err)
+	assert.Equal(t, StagingReview, staging)
+
+	staging, err = ParseStaging("atomic")
+	assert.NoError(t, err)
+	assert.Equal(t, StagingAtomic, staging)
+
+	_, err = ParseStaging("later")
+	assert.Error(t, err)
+}
+
+// testDB connects to the database whose DSN is read from TEST_DB_DSN, or skips the test.
+func testDB(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	if err := db.Connect(&config.DbConfig{Dsn: dsn}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.AutoMigrate()
+}
+
+// newStagedImport records an import whose staged contacts have the status, and returns its uuid and its phonebook.
+func newStagedImport(t *testing.T, c *ContactUploader, status string) (string, string) {
+	ctx := context.Background()
+	id := uuid.NewString()
+	phonebook := "test-" + id
+	table := repository.StagingTable(id)
+	t.Cleanup(func() {
+		db.DB.Unscoped().Where("phonebook = ?", phonebook).Delete(&model.Contact{})
+		db.DB.Unscoped().Where("uuid = ?", id).Delete(&model.Import{})
+		c.Staging.Drop(ctx, table)
+	})
+
+	existing := &model.Contact{ReqId: uuid.NewString(), Phonebook: phonebook, Firstname: "Jonh", Phone: "0701020300", PhoneE164: "+33701020300", NaturalKey: model.NewNaturalKey(phonebook, "+33701020300")}
+	assert.NoError(t, db.DB.Create(existing).Error)
+
+	assert.NoError(t, c.Staging.Create(ctx, table))
+	staged := []*model.Contact{
+		{ReqId: id, Phonebook: phonebook, Firstname: "John", Phone: "0701020300", PhoneE164: "+33701020300", NaturalKey: model.NewNaturalKey(phonebook, "+33701020300")},
+		{ReqId: id, Phonebook: phonebook, Firstname: "Jane", Phone: "0701020301", PhoneE164: "+33701020301", NaturalKey: model.NewNaturalKey(phonebook, "+33701020301")},
+	}
+	_, err := c.Repository.SaveBatch(repository.WithTable(ctx, table), repository.ImportModeInsert, staged)
+	assert.NoError(t, err)
+
+	imp := &model.Import{Uuid: id, Phonebook: phonebook, Mode: string(repository.ImportModeUpsert), Staging: StagingReview, StagingStatus: status}
+	assert.NoError(t, c.Imports.Save(ctx, imp))
+	return id, phonebook
+}
+
+func newStagingUploader() *ContactUploader {
+	store := worker.NewMessageProgressStore()
+	return &ContactUploader{
+		HttpConfig:    &config.HttpConfig{BatchInsert: 1000},
+		Repository:    repository.NewContactRepository(),
+		Imports:       repository.NewImportRepository(),
+		Staging:       repository.NewStagingRepository(),
+		ProgressStore: store,
+	}
+}
+
+// phonebookContacts returns the firstnames of the contacts of the phonebook, by phone.
+func phonebookContacts(t *testing.T, phonebook string) map[string]string {
+	var contacts []model.Contact
+	assert.NoError(t, db.DB.Where("phonebook = ?", phonebook).Find(&contacts).Error)
+	firstnames := make(map[string]string, len(contacts))
+	for _, contact := range contacts {
+		firstnames[contact.Phone] = contact.Firstname
+	}
+	assert.Len(t, firstnames, len(contacts))
+	return firstnames
+}
+
+func TestCommitStaging(t *testing.T) {
+	testDB(t)
+	ctx := context.Background()
+	c := newStagingUploader()
+	id, phonebook := newStagedImport(t, c, StagingStatusReview)
+	c.ProgressStore.Init(id, 2)
+
+	assert.NoError(t, c.CommitStaging(ctx, id, false))
+	assert.Equal(t, map[string]string{"0701020300": "John", "0701020301": "Jane"}, phonebookContacts(t, phonebook))
+
+	imp, err := c.Imports.FindByUuid(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, StagingStatusCommitted, imp.StagingStatus)
+	assert.False(t, db.DB.Migrator().HasTable(repository.StagingTable(id)))
+
+	resp, _, ok := c.ProgressStore.Response(id)
+	assert.True(t, ok)
+	assert.EqualValues(t, 1, resp.Inserted)
+	assert.EqualValues(t, 1, resp.Updated)
+
+	// A second commit is refused, and a redelivered upload leaves the import committed
+	assert.Error(t, c.CommitStaging(ctx, id, false))
+	assert.Error(t, c.DiscardStaging(ctx, id))
+	assert.Error(t, c.recordImport(ctx, &FileMessage{Uuid: id, Phonebook: phonebook, Staging: StagingReview}))
+	imp, err = c.Imports.FindByUuid(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, StagingStatusCommitted, imp.StagingStatus)
+	assert.Len(t, phonebookContacts(t, phonebook), 2)
+}
+
+func TestCommitStaging_Redelivered(t *testing.T) {
+	testDB(t)
+	ctx := context.Background()
+	c := newStagingUploader()
+	id, phonebook := newStagedImport(t, c, StagingStatusCommitting)
+
+	// Only a redelivered message commits an import left committing
+	assert.Error(t, c.CommitStaging(ctx, id, false))
+	assert.NoError(t, c.CommitStaging(ctx, id, true))
+	assert.Equal(t, map[string]string{"0701020300": "John", "0701020301": "Jane"}, phonebookContacts(t, phonebook))
+}
+
+func TestDiscardStaging(t *testing.T) {
+	testDB(t)
+	ctx := context.Background()
+	c := newStagingUploader()
+	id, phonebook := newStagedImport(t, c, StagingStatusReview)
+
+	assert.NoError(t, c.DiscardStaging(ctx, id))
+	assert.Equal(t, map[string]string{"0701020300": "Jonh"}, phonebookContacts(t, phonebook))
+
+	imp, err := c.Imports.FindByUuid(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, StagingStatusDiscarded, imp.StagingStatus)
+	assert.False(t, db.DB.Migrator().HasTable(repository.StagingTable(id)))
+
+	assert.Error(t, c.CommitStaging(ctx, id, false))
+	assert.Error(t, c.DiscardStaging(ctx, id))
+}
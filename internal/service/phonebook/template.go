@@ -0,0 +1,161 @@
+package phonebook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-csv-import/internal/model"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// templateNameMaxLength is the maximum length of a template name.
+const templateNameMaxLength = 100
+
+/*
+ParseTemplate decodes and validates the JSON options of an import template.
+
+Options are named after the upload form fields, and unknown options are refused.
+
+	{"mapping": {"Mobile": "phone"}, "delimiter": "tab", "has_header": true, "mode": "upsert", "continue_on_error": true}
+*/
+func ParseTemplate(name string, raw []byte) (*model.Template, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > templateNameMaxLength {
+		return nil, fmt.Errorf("invalid template: name must have 1 to %d characters", templateNameMaxLength)
+	}
+
+	template := &model.Template{Name: name}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&template.Options); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	if _, err := parseTemplateOptions(template.Options); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return template, nil
+}
+
+/*
+ApplyTemplate sets the options of the template which have not been set by the upload.
+
+Upload options always take precedence, so that a template can be adjusted for a single upload.
+The layout of a template is resolved when the file is uploaded, as a layout is sent along the upload.
+*/
+func (j *FileMessage) ApplyTemplate(template *model.Template) error {
+	o, err := parseTemplateOptions(template.Options)
+	if err != nil {
+		return fmt.Errorf("invalid template <%s> version %d: %w", template.Name, template.Version, err)
+	}
+
+	if len(j.Mapping) == 0 {
+		j.Mapping = o.Mapping
+	}
+	if j.DialectOptions.Delimiter == "" {
+		j.DialectOptions.Delimiter = o.DialectOptions.Delimiter
+	}
+	if j.DialectOptions.Quote == "" {
+		j.DialectOptions.Quote = o.DialectOptions.Quote
+	}
+	if j.DialectOptions.Comment == "" {
+		j.DialectOptions.Comment = o.DialectOptions.Comment
+	}
+	if j.DialectOptions.LazyQuotes == nil {
+		j.DialectOptions.LazyQuotes = o.DialectOptions.LazyQuotes
+	}
+	if j.DialectOptions.TrimLeadingSpace == nil {
+		j.DialectOptions.TrimLeadingSpace = o.DialectOptions.TrimLeadingSpace
+	}
+	if j.DialectOptions.HasHeader == nil {
+		j.DialectOptions.HasHeader = o.DialectOptions.HasHeader
+	}
+	if j.Encoding == "" {
+		j.Encoding = o.Encoding
+	}
+	if j.Mode == "" {
+		j.Mode = o.Mode
+	}
+	if !j.ContinueOnError {
+		j.ContinueOnError = o.ContinueOnError
+	}
+	if j.Country == "" {
+		j.Country = o.Country
+	}
+	if j.PhoneValidation == "" {
+		j.PhoneValidation = o.PhoneValidation
+	}
+	if j.Sheet == "" {
+		j.Sheet = o.Sheet
+	}
+	if j.VCardPhones == "" {
+		j.VCardPhones = o.VCardPhones
+	}
+	return nil
+}
+
+// parseTemplateOptions validates the options of a template as the fields of an upload.
+// Options which are not set are left empty, so that the upload or the defaults apply.
+func parseTemplateOptions(options model.TemplateOptions) (*FileMessage, error) {
+	o := &FileMessage{}
+	var err error
+
+	if len(options.Mapping) > 0 {
+		raw, err := json.Marshal(options.Mapping)
+		if err != nil {
+			return nil, err
+		}
+		if o.Mapping, err = ParseColumnMapping(string(raw)); err != nil {
+			return nil, err
+		}
+	}
+
+	o.DialectOptions, err = ParseDialectOptions(
+		options.Delimiter,
+		options.Quote,
+		options.Comment,
+		formatOption(options.LazyQuotes),
+		formatOption(options.TrimLeadingSpace),
+		formatOption(options.HasHeader),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Encoding, err = ParseEncoding(options.Encoding); err != nil {
+		return nil, err
+	}
+	if options.Mode != "" {
+		if o.Mode, err = ParseImportMode(options.Mode); err != nil {
+			return nil, err
+		}
+	}
+	if options.ContinueOnError != nil {
+		o.ContinueOnError = *options.ContinueOnError
+	}
+	if o.Country, err = ParseCountry(options.Country); err != nil {
+		return nil, err
+	}
+	if o.PhoneValidation, err = ParsePhoneValidation(options.PhoneValidation); err != nil {
+		return nil, err
+	}
+	if o.Sheet, err = ParseSheet(options.Sheet); err != nil {
+		return nil, err
+	}
+	if options.VCardPhones != "" {
+		if o.VCardPhones, err = ParseVCardPhones(options.VCardPhones); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// formatOption formats a boolean option as an upload form value, empty when it is not set.
+func formatOption(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
@@ -0,0 +1,67 @@
+package phonebook
+
+import (
+	"go-csv-import/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTemplate(t *testing.T) {
+	template, err := ParseTemplate("weekly", []byte(`{"mapping": {"Mobile": "phone"}, "delimiter": "tab", "has_header": false, "mode": "upsert", "encoding": "latin1"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "weekly", template.Name)
+	assert.Equal(t, map[string]string{"Mobile": "phone"}, template.Options.Mapping)
+	assert.Equal(t, "tab", template.Options.Delimiter)
+	assert.False(t, *template.Options.HasHeader)
+
+	for _, raw := range []string{
+		`{"mapping": {"Mobile": "mobile"}}`,
+		`{"delimiter": "ab"}`,
+		`{"mode": "merge"}`,
+		`{"country": "XX"}`,
+		`{"encoding": "ebcdic"}`,
+		`{"vcard_phones": "first"}`,
+		`{"unknown": true}`,
+		`[]`,
+	} {
+		_, err := ParseTemplate("weekly", []byte(raw))
+		assert.Error(t, err, raw)
+	}
+
+	_, err = ParseTemplate(" ", []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestFileMessage_ApplyTemplate(t *testing.T) {
+	template, err := ParseTemplate("weekly", []byte(`{
+		"mapping": {"Mobile": "phone"},
+		"delimiter": "tab",
+		"has_header": false,
+		"encoding": "latin1",
+		"mode": "upsert",
+		"continue_on_error": true,
+		"country": "be",
+		"vcard_phones": "primary"
+	}`))
+	assert.NoError(t, err)
+
+	// Options of the upload take precedence
+	hasHeader := true
+	file := &FileMessage{
+		DialectOptions: DialectOptions{HasHeader: &hasHeader},
+		Country:        "FR",
+		Mode:           repository.ImportModeSkipExisting,
+	}
+	assert.NoError(t, file.ApplyTemplate(template))
+
+	assert.Equal(t, ColumnMapping{"Mobile": FieldPhone}, file.Mapping)
+	assert.Equal(t, "\t", file.DialectOptions.Delimiter)
+	assert.True(t, *file.DialectOptions.HasHeader)
+	assert.Equal(t, EncodingISO88591, file.Encoding)
+	assert.Equal(t, repository.ImportModeSkipExisting, file.Mode)
+	assert.True(t, file.ContinueOnError)
+	assert.Equal(t, "FR", file.Country)
+	assert.Equal(t, "", file.PhoneValidation)
+	assert.Equal(t, VCardPhonesPrimary, file.VCardPhones)
+}
@@ -2,19 +2,16 @@ package phonebook
 
 import (
 	"context"
-	"encoding/csv"
+	"errors"
 	"fmt"
 	"go-csv-import/internal/config"
 	"go-csv-import/internal/db"
 	"go-csv-import/internal/handlers/worker"
 	"go-csv-import/internal/logger"
+	"go-csv-import/internal/model"
 	"go-csv-import/internal/repository"
-	"go-csv-import/internal/utils"
 	"io"
-	"os"
-	"runtime"
 	"sync"
-	"time"
 
 	"github.com/hashicorp/go-multierror"
 )
@@ -27,6 +24,12 @@ type ContactUploader struct {
 	HttpConfig    *config.HttpConfig
 	DbConfig      *config.DbConfig
 	Repository    *repository.ContactRepository
+	Prepared      *repository.PreparedContactRepository
+	Pool          *WorkerPool // Parsing and writing slots shared by the imports of the worker
+	Templates     *repository.TemplateRepository
+	Imports       *repository.ImportRepository
+	Checkpoints   *repository.CheckpointRepository
+	Staging       *repository.StagingRepository
 	ProgressStore *worker.MessageProgressStore
 }
 
@@ -35,49 +38,464 @@ func NewContactUploader(h *config.HttpConfig, d *config.DbConfig, p *worker.Mess
 		HttpConfig:    h,
 		DbConfig:      d,
 		Repository:    repository.NewContactRepository(),
+		Prepared:      repository.NewPreparedContactRepository(h.BatchInsertMax),
+		Pool:          NewWorkerPool(int(h.WorkerParsers), int(h.StageParsers+h.StageValidators), int(h.WorkerWriters)),
+		Templates:     repository.NewTemplateRepository(),
+		Imports:       repository.NewImportRepository(),
+		Checkpoints:   repository.NewCheckpointRepository(),
+		Staging:       repository.NewStagingRepository(),
 		ProgressStore: p,
 	}
 }
 
-func (c *ContactUploader) reset() {
-	c.Repository.Truncate()
-}
+/*
+Upload imports the contacts of the file, tagged with the upload identifier.
+
+Contacts imported before are kept, unless the upload replaces its phonebook:
+the other contacts of this phonebook are then removed once every row has been imported.
+
+Staged uploads are loaded into their own table, whose contacts are moved into contacts at once
+when every FilePart has been imported: right away with atomic staging, or once committed through the API with review staging.
+
+An import failing on transient errors before every FilePart has been imported keeps its checkpoints and its staging table,
+to be resumed by the next attempt.
+*/
+func (c *ContactUploader) Upload(ctx context.Context, file *FileMessage) (err error) {
+	// Messages published before archive limits were sent get the worker limits
+	if file.MaxRatio == 0 {
+		file.MaxRatio = int(c.HttpConfig.ArchiveMaxRatio)
+	}
+	if file.MaxEntries == 0 {
+		file.MaxEntries = int(c.HttpConfig.ArchiveMaxEntries)
+	}
+	if file.MaxSize == 0 {
+		file.MaxSize = int64(c.HttpConfig.ArchiveMaxSize)
+	}
 
-func (c *ContactUploader) Upload(ctx context.Context, file *FileMessage) error {
-	c.reset()
-	totalRows, err := utils.FileCountRowsCsv(file.FilePath)
+	if err := c.applyTemplate(ctx, file); err != nil {
+		return err
+	}
+	if err := ValidateReplace(file.Replace, file.Mode); err != nil {
+		return NewFileError(file.FilePath, err)
+	}
+	if err := c.recordImport(ctx, file); err != nil {
+		return err
+	}
+	c.resolveLoader(file)
+	logger.Debug("Contacts loader resolved", "loader", file.Loader, "mode", file.Mode)
+
+	dialect, err := file.ResolveDialect()
+	if err != nil {
+		return NewFileError(file.FilePath, fmt.Errorf("error detecting file dialect: %w", err))
+	}
+	logger.Debug("File dialect resolved", "dialect", dialect.Describe(), "encoding", file.Encoding)
+
+	files, totalRows, err := c.splitFile(file)
 	if err != nil {
-		return NewFileError(file.FilePath, fmt.Errorf("error counting file rows: %w", err))
+		return NewFileError(file.FilePath, fmt.Errorf("error splitting file: %w", err))
 	}
 	c.ProgressStore.Init(file.Uuid, int64(totalRows))
+	c.ProgressStore.SetEncoding(file.Uuid, file.Encoding)
+	c.ProgressStore.SetDryRun(file.Uuid, file.DryRun)
+	c.ProgressStore.SetTemplate(file.Uuid, file.Template, file.TemplateVersion)
+	c.ProgressStore.InitStages(file.Uuid, Stages...)
+	for _, part := range files {
+		if part.Entry != "" {
+			c.ProgressStore.InitEntry(file.Uuid, part.Entry, int64(part.Rows))
+		}
+	}
 
-	chunk, err := c.mustChunkFile(file)
+	resumed := false
+	if !file.DryRun {
+		if resumed, err = c.loadCheckpoints(ctx, file, files); err != nil {
+			return db.NewDbError(fmt.Errorf("error reading checkpoints: %w", err))
+		}
+		defer func() {
+			if !file.Retryable(err) {
+				c.deleteCheckpoints(ctx, file)
+			}
+		}()
+	}
+	if resumed {
+		logger.Info("Resuming import from its checkpoints", "uuid", file.Uuid)
+	}
+
+	header, err := file.ReadHeader()
+	if err != nil {
+		return NewFileError(file.FilePath, err)
+	}
+	mapping, err := file.ResolveMapping(header)
 	if err != nil {
-		return NewFileError(file.FilePath, fmt.Errorf("error checking chunk file: %w", err))
+		return NewFileError(file.FilePath, fmt.Errorf("error resolving column mapping: %w", err))
+	}
+	file.mapping = mapping
+	c.ProgressStore.SetMapping(file.Uuid, mapping.Describe())
+	logger.Debug("Column mapping resolved", "mapping", mapping.Describe())
+
+	file.normalizer = c.newPhoneNormalizer(file)
+	logger.Debug("Phone normalizer created", "country", file.normalizer.Country, "strict", file.normalizer.Strict)
+
+	file.sizer = c.newBatchSizer(ctx)
+	c.ProgressStore.SetBatchSize(file.Uuid, int64(file.sizer.Size()))
+
+	load := ctx
+	if file.Staging != "" {
+		table := repository.StagingTable(file.Uuid)
+		if err := c.Staging.Create(ctx, table); err != nil {
+			return db.NewDbError(fmt.Errorf("error creating staging table: %w", err))
+		}
+		load = repository.WithTable(ctx, table)
+		defer c.Prepared.Release(table)
+		c.ProgressStore.SetStaging(file.Uuid, file.Staging, StagingStatusLoading)
+		logger.Debug("Loading contacts into staging table", "table", table, "staging", file.Staging)
+	}
+
+	if file.ContinueOnError {
+		file.rejects = NewRejectWriter(file)
+		file.rejects.Append = resumed
+		defer func() {
+			if err := file.rejects.Close(); err != nil {
+				logger.Error("Cannot write rejected rows", "file", file.rejects.FilePath, "error", err)
+			}
+		}()
 	}
 
-	files := []FilePart{{FilePath: file.FilePath, Uuid: file.Uuid, TotalRows: 0, ProcessTime: 0}}
-	if chunk {
-		files, err = c.chunkFile(file)
-		if err != nil {
-			return NewFileError(file.FilePath, fmt.Errorf("error chunking file: %w", err))
+	if err := c.handleFiles(load, file, files); err != nil {
+		if file.Retryable(err) {
+			return err
 		}
+		if file.Staging != "" {
+			logger.Warn("Staged contacts discarded as the import failed", "uuid", file.Uuid)
+			c.discardStaging(ctx, file.Uuid, file.Staging, StagingStatusLoading)
+		}
+		if file.Replace {
+			logger.Warn("Phonebook not replaced as the import failed", "phonebook", file.Phonebook, "uuid", file.Uuid)
+		}
+		return err
 	}
+	file.loaded = true
 
-	return c.handleFiles(ctx, files)
+	if file.Staging != "" {
+		return c.completeStaging(ctx, file)
+	}
+	if file.Replace && file.DryRun {
+		logger.Info("Phonebook not replaced on dry run", "phonebook", file.Phonebook, "uuid", file.Uuid)
+		return nil
+	}
+	if file.Replace {
+		return c.replacePhonebook(ctx, file)
+	}
+	return nil
 }
 
-func (c *ContactUploader) handleFiles(ctx context.Context, files []FilePart) error {
-	logger.Debug("Processing chunked files")
+// applyTemplate sets the options of the template version of the upload which have not been set by the upload.
+func (c *ContactUploader) applyTemplate(ctx context.Context, file *FileMessage) error {
+	if file.Template == "" {
+		return nil
+	}
+
+	template, err := c.Templates.FindByName(ctx, file.Template, file.TemplateVersion)
+	if err != nil {
+		return db.NewDbError(fmt.Errorf("error reading template <%s>: %w", file.Template, err))
+	}
+	if template == nil {
+		return NewFileError(file.FilePath, fmt.Errorf("template <%s> version %d not found", file.Template, file.TemplateVersion))
+	}
+
+	if err := file.ApplyTemplate(template); err != nil {
+		return NewFileError(file.FilePath, err)
+	}
+	logger.Debug("Template applied", "template", template.Name, "version", template.Version)
+	return nil
+}
+
+// recordImport records the upload with the template version it used, and the options applied when its staged contacts are committed.
+// Dry runs write nothing to the database, and are not recorded.
+func (c *ContactUploader) recordImport(ctx context.Context, file *FileMessage) error {
+	if file.DryRun {
+		return nil
+	}
+
+	imp := &model.Import{
+		Uuid:            file.Uuid,
+		Phonebook:       file.Phonebook,
+		Template:        file.Template,
+		TemplateVersion: file.TemplateVersion,
+		Mode:            string(file.Mode),
+		Replace:         file.Replace,
+		Staging:         file.Staging,
+	}
+	if file.Staging != "" {
+		imp.StagingStatus = StagingStatusLoading
+	}
+	if err := c.Imports.Save(ctx, imp); err != nil {
+		return db.NewDbError(fmt.Errorf("error recording import: %w", err))
+	}
+	if file.Staging == "" {
+		return nil
+	}
+
+	// A message redelivered once its staged contacts were committed or discarded is not imported again
+	saved, err := c.Imports.FindByUuid(ctx, file.Uuid)
+	if err != nil {
+		return db.NewDbError(fmt.Errorf("error reading import: %w", err))
+	}
+	if saved != nil && repository.IsFinalStagingStatus(saved.StagingStatus) {
+		return fmt.Errorf("staged contacts of import <%s> are already %s", file.Uuid, saved.StagingStatus)
+	}
+	return nil
+}
+
+/*
+loadCheckpoints sets the checkpoint of each FilePart, and tells whether the import is resumed.
+
+The progress committed before a worker crash or a transient failure is restored, so that the rows of the resumed
+FileParts are counted once.
+*/
+func (c *ContactUploader) loadCheckpoints(ctx context.Context, file *FileMessage, files []FilePart) (bool, error) {
+	checkpoints, err := c.Checkpoints.FindByUuid(ctx, file.Uuid)
+	if err != nil {
+		return false, err
+	}
+	c.applyCheckpoints(file, files, checkpoints)
+	return len(checkpoints) > 0, nil
+}
+
+// applyCheckpoints sets the saved checkpoint of each FilePart, or a new one, and counts the rows committed before.
+func (c *ContactUploader) applyCheckpoints(file *FileMessage, files []FilePart, checkpoints []model.Checkpoint) {
+	type partKey struct {
+		entry  string
+		offset int64
+	}
+	saved := make(map[partKey]model.Checkpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		saved[partKey{checkpoint.Entry, checkpoint.Offset}] = checkpoint
+	}
+
+	for i := range files {
+		part := &files[i]
+		checkpoint, ok := saved[partKey{part.Entry, part.Offset}]
+		if !ok {
+			part.checkpoint = &model.Checkpoint{Uuid: file.Uuid, Entry: part.Entry, Offset: part.Offset}
+			continue
+		}
+		part.checkpoint = &checkpoint
+
+		// Every row read has been written, merged within its batch, or rejected
+		rejected := int64(checkpoint.ReadRows) - checkpoint.Inserted - checkpoint.Updated - checkpoint.Skipped
+		c.ProgressStore.IncrementBatch(file.Uuid, checkpoint.Inserted, checkpoint.Updated, checkpoint.Skipped)
+		c.ProgressStore.Reject(file.Uuid, rejected)
+		if part.Entry != "" {
+			c.ProgressStore.IncrementEntry(file.Uuid, part.Entry, int64(checkpoint.ReadRows))
+		}
+		logger.Debug("FilePart checkpoint loaded", "entry", part.Entry, "offset", part.Offset, "rows", checkpoint.ReadRows, "done", checkpoint.Done)
+	}
+}
+
+// abandonRetry removes what an import failing on transient errors kept for its next attempt, when it cannot be retried.
+func (c *ContactUploader) abandonRetry(ctx context.Context, file *FileMessage) {
+	c.deleteCheckpoints(ctx, file)
+	if file.Staging != "" {
+		c.discardStaging(ctx, file.Uuid, file.Staging, StagingStatusLoading)
+	}
+}
+
+// deleteCheckpoints removes the checkpoints of the import once it has been processed, successfully or not.
+func (c *ContactUploader) deleteCheckpoints(ctx context.Context, file *FileMessage) {
+	if err := c.Checkpoints.DeleteByUuid(context.WithoutCancel(ctx), file.Uuid); err != nil {
+		logger.Error("Cannot delete checkpoints", "uuid", file.Uuid, "error", err)
+	}
+}
+
+// completeStaging commits the staged contacts of an import with atomic staging, or leaves them for review.
+func (c *ContactUploader) completeStaging(ctx context.Context, file *FileMessage) error {
+	if file.Staging == StagingReview {
+		if _, err := c.Imports.SetStagingStatus(ctx, file.Uuid, StagingStatusLoading, StagingStatusReview); err != nil {
+			return db.NewDbError(fmt.Errorf("error recording staged contacts for review: %w", err))
+		}
+		c.ProgressStore.SetStaging(file.Uuid, file.Staging, StagingStatusReview)
+		logger.Info("Staged contacts ready for review", "uuid", file.Uuid, "table", repository.StagingTable(file.Uuid))
+		return nil
+	}
+
+	imp, err := c.Imports.FindByUuid(ctx, file.Uuid)
+	if err != nil {
+		return db.NewDbError(fmt.Errorf("error reading import: %w", err))
+	}
+	if _, err := c.Imports.SetStagingStatus(ctx, file.Uuid, StagingStatusLoading, StagingStatusCommitting); err != nil {
+		return db.NewDbError(fmt.Errorf("error committing staged contacts: %w", err))
+	}
+	return c.commitStaging(ctx, imp)
+}
+
+/*
+CommitStaging moves the contacts of an import waiting for review into contacts.
+
+The import is claimed first, so that contacts are committed once when the API is called twice.
+A redelivered message commits again an import interrupted while committing, whose transaction has been rolled back.
+*/
+func (c *ContactUploader) CommitStaging(ctx context.Context, uuid string, redelivered bool) error {
+	imp, err := c.Imports.FindByUuid(ctx, uuid)
+	if err != nil {
+		return db.NewDbError(fmt.Errorf("error reading import: %w", err))
+	}
+	if imp == nil || imp.Staging == "" {
+		return fmt.Errorf("import <%s> has no staged contacts", uuid)
+	}
+
+	claimed, err := c.Imports.SetStagingStatus(ctx, uuid, StagingStatusReview, StagingStatusCommitting)
+	if err != nil {
+		return db.NewDbError(fmt.Errorf("error committing staged contacts: %w", err))
+	}
+	if !claimed && !(redelivered && imp.StagingStatus == StagingStatusCommitting) {
+		return fmt.Errorf("staged contacts of import <%s> are %s, not waiting for review", uuid, imp.StagingStatus)
+	}
+	return c.commitStaging(ctx, imp)
+}
+
+// DiscardStaging removes the contacts of an import waiting for review, without committing them.
+func (c *ContactUploader) DiscardStaging(ctx context.Context, uuid string) error {
+	imp, err := c.Imports.FindByUuid(ctx, uuid)
+	if err != nil {
+		return db.NewDbError(fmt.Errorf("error reading import: %w", err))
+	}
+	if imp == nil || imp.Staging == "" {
+		return fmt.Errorf("import <%s> has no staged contacts", uuid)
+	}
+	if imp.StagingStatus != StagingStatusReview {
+		return fmt.Errorf("staged contacts of import <%s> are %s, not waiting for review", uuid, imp.StagingStatus)
+	}
+
+	c.discardStaging(ctx, uuid, imp.Staging, StagingStatusReview)
+	return nil
+}
+
+/*
+commitStaging moves the staged contacts of an import into contacts, and replaces its phonebook if requested,
+within one transaction which also records the import as committed: a failure leaves contacts untouched.
+
+Staged contacts waiting for review can be committed again after a failure, others are discarded.
+*/
+func (c *ContactUploader) commitStaging(ctx context.Context, imp *model.Import) error {
+	table := repository.StagingTable(imp.Uuid)
+	c.ProgressStore.SetStaging(imp.Uuid, imp.Staging, StagingStatusCommitting)
+	logger.Debug("Committing staged contacts", "uuid", imp.Uuid, "table", table, "mode", imp.Mode)
+
+	var result repository.BatchResult
+	var deleted int64
+	err := repository.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if result, err = c.Staging.Commit(ctx, table, repository.ImportMode(imp.Mode)); err != nil {
+			return err
+		}
+		if imp.Replace {
+			if deleted, err = c.Repository.DeletePhonebookExcept(ctx, imp.Phonebook, imp.Uuid, int(c.HttpConfig.BatchInsert)); err != nil {
+				return fmt.Errorf("error replacing phonebook <%s>: %w", imp.Phonebook, err)
+			}
+		}
+		_, err = c.Imports.SetStagingStatus(ctx, imp.Uuid, StagingStatusCommitting, StagingStatusCommitted)
+		return err
+	})
+	if err != nil {
+		if imp.Staging == StagingReview {
+			c.resetStaging(ctx, imp)
+		} else {
+			c.discardStaging(ctx, imp.Uuid, imp.Staging, StagingStatusCommitting)
+		}
+		return db.NewDbError(fmt.Errorf("error committing staged contacts: %w", err))
+	}
+
+	c.ProgressStore.Commit(imp.Uuid, result.Inserted, result.Updated, result.Skipped)
+	c.ProgressStore.Replace(imp.Uuid, deleted)
+	c.ProgressStore.SetStaging(imp.Uuid, imp.Staging, StagingStatusCommitted)
+	logger.Info("Staged contacts committed", "uuid", imp.Uuid, "inserted", result.Inserted, "updated", result.Updated, "skipped", result.Skipped, "replaced", deleted)
+
+	if err := c.Staging.Drop(context.WithoutCancel(ctx), table); err != nil {
+		logger.Error("Cannot drop staging table", "table", table, "error", err)
+	}
+	return nil
+}
+
+// resetStaging sets the staged contacts of an import back to review once they could not be committed.
+func (c *ContactUploader) resetStaging(ctx context.Context, imp *model.Import) {
+	if _, err := c.Imports.SetStagingStatus(context.WithoutCancel(ctx), imp.Uuid, StagingStatusCommitting, StagingStatusReview); err != nil {
+		logger.Error("Cannot set staged contacts back to review", "uuid", imp.Uuid, "error", err)
+	}
+	c.ProgressStore.SetStaging(imp.Uuid, imp.Staging, StagingStatusReview)
+}
+
+// discardStaging records the staged contacts of an import as discarded, then drops its staging table.
+func (c *ContactUploader) discardStaging(ctx context.Context, uuid string, staging string, from string) {
+	ctx = context.WithoutCancel(ctx)
+	if _, err := c.Imports.SetStagingStatus(ctx, uuid, from, StagingStatusDiscarded); err != nil {
+		logger.Error("Cannot record staged contacts as discarded", "uuid", uuid, "error", err)
+	}
+	c.ProgressStore.SetStaging(uuid, staging, StagingStatusDiscarded)
+
+	table := repository.StagingTable(uuid)
+	if err := c.Staging.Drop(ctx, table); err != nil {
+		logger.Error("Cannot drop staging table", "table", table, "error", err)
+		return
+	}
+	logger.Info("Staged contacts discarded", "uuid", uuid)
+}
+
+// replacePhonebook removes the contacts of the phonebook which have not been imported by the upload.
+func (c *ContactUploader) replacePhonebook(ctx context.Context, file *FileMessage) error {
+	logger.Debug("Replacing phonebook", "phonebook", file.Phonebook, "uuid", file.Uuid)
+
+	deleted, err := c.Repository.DeletePhonebookExcept(ctx, file.Phonebook, file.Uuid, int(c.HttpConfig.BatchInsert))
+	c.ProgressStore.Replace(file.Uuid, deleted)
+	if err != nil {
+		return db.NewDbError(fmt.Errorf("error replacing phonebook <%s>: %w", file.Phonebook, err))
+	}
+
+	logger.Info("Phonebook replaced", "phonebook", file.Phonebook, "deleted", deleted)
+	return nil
+}
+
+// newPhoneNormalizer creates the phone normalizer of the import, with the configured defaults unless overridden by the upload.
+func (c *ContactUploader) newPhoneNormalizer(file *FileMessage) *PhoneNormalizer {
+	country := c.HttpConfig.PhoneCountry
+	if file.Country != "" {
+		country = file.Country
+	}
+
+	validation := c.HttpConfig.PhoneValidation
+	if file.PhoneValidation != "" {
+		validation = file.PhoneValidation
+	}
+
+	return NewPhoneNormalizer(country, validation)
+}
+
+// newBatchSizer creates the batch sizer of the import, bounded by the configuration and by max_allowed_packet.
+func (c *ContactUploader) newBatchSizer(ctx context.Context) *BatchSizer {
+	sizer := NewBatchSizer(c.HttpConfig.BatchInsert, c.HttpConfig.BatchInsertMin, c.HttpConfig.BatchInsertMax, c.HttpConfig.BatchLatency)
+
+	maxPacket, err := c.Repository.MaxAllowedPacket(ctx)
+	if err != nil {
+		logger.Warn("Cannot read max_allowed_packet, batches are not limited by the packet size", "error", err)
+		return sizer
+	}
+	sizer.SetMaxPacket(maxPacket)
+	logger.Debug("Batch sizer created", "size", sizer.Size(), "min", sizer.Min, "max", sizer.Max, "target", sizer.Target, "max_packet", maxPacket)
+	return sizer
+}
+
+func (c *ContactUploader) handleFiles(ctx context.Context, message *FileMessage, files []FilePart) error {
+	logger.Debug("Processing file parts", "parts", len(files), "max_parallelism", message.MaxParallelism)
 	logger.Trace("Files to process", "files", fmt.Sprintf("%#v", files))
 
-	// Define max CPU usage to avoir using all CPU cores
-	maxCPU := runtime.NumCPU()
-	if maxCPU > 4 {
-		maxCPU -= 2
+	// FileParts share the slots of the worker pool with the other imports
+	imp := c.Pool.Register(message.Uuid, message.MaxParallelism)
+	defer c.Pool.Unregister(imp)
+
+	// More goroutines would only wait for a slot
+	workers := min(len(files), c.Pool.MaxParts())
+	if message.MaxParallelism > 0 {
+		workers = min(workers, message.MaxParallelism)
 	}
-	logger.Debug("Setting max CPU usage", "maxCPU", maxCPU)
-	runtime.GOMAXPROCS(maxCPU)
 
 	jobs := make(chan FilePart)
 	errs := make(chan error, len(files))
@@ -85,14 +503,25 @@ func (c *ContactUploader) handleFiles(ctx context.Context, files []FilePart) err
 
 	var wg sync.WaitGroup
 
-	logger.Trace("Launching workers", "workers", maxCPU)
+	logger.Trace("Launching workers", "workers", workers)
 	// Consume files
-	for w := 0; w < maxCPU; w++ {
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for file := range jobs {
-				if err := c.uploadFile(ctx, &file); err != nil {
+				if err := c.Pool.Acquire(ctx, imp); err != nil {
+					errs <- fmt.Errorf("file %s: %w", file.FilePath, err)
+					continue
+				}
+				err := c.uploadFile(ctx, message, &file)
+				c.Pool.Release(imp)
+
+				if err != nil {
+					if file.Entry != "" {
+						c.ProgressStore.SetEntryError(file.Uuid, file.Entry, err)
+						err = fmt.Errorf("entry %s: %w", file.Entry, err)
+					}
 					errs <- fmt.Errorf("file %s: %w", file.FilePath, err)
 					continue
 				}
@@ -127,69 +556,53 @@ func (c *ContactUploader) handleFiles(ctx context.Context, files []FilePart) err
 	return aErrs.ErrorOrNil()
 }
 
-func (c *ContactUploader) uploadFile(ctx context.Context, file *FilePart) error {
-	logger.Debug("Start processing routine file", "file", file.FilePath, "uuid", file.Uuid)
+func (c *ContactUploader) uploadFile(ctx context.Context, message *FileMessage, file *FilePart) error {
+	logger.Debug("Start processing routine file", "file", file.FilePath, "offset", file.Offset, "size", file.Size, "uuid", file.Uuid)
 
 	ctxT, cancel := context.WithTimeout(ctx, c.HttpConfig.FileTimeout)
 	defer cancel()
-	defer file.Remove()
 
-	start := time.Now()
+	if file.checkpoint != nil && file.checkpoint.Done {
+		logger.Debug("FilePart already imported before the import was resumed", "entry", file.Entry, "offset", file.Offset)
+		return nil
+	}
+
+	mapping := message.mapping
+	if file.mapping != nil {
+		mapping = file.mapping // Compressed contents have their own header
+	}
 
-	f, err := os.Open(file.FilePath)
+	reader, f, err := message.openRecordReader(file, mapping.Header)
 	if err != nil {
 		return NewFileError(file.FilePath, err)
 	}
 	defer f.Close()
-	defer file.Remove()
-
-	reader := csv.NewReader(f)
-	reader.Comma = ';'
 
-	// Skip header
-	headers, err := reader.Read()
-	if err != nil {
+	if err := skipCommittedRows(reader, file); err != nil {
 		return err
 	}
-	logger.Trace("CSV Headers", "headers", headers)
-
-	batch := NewBatch()
 
 	file.TotalRows = 0
-	for {
-		select {
-		case <-ctxT.Done():
-			return fmt.Errorf("%w on FilePart", ctxT.Err())
-		default:
-		}
+	if err := c.newPipeline(message, file, mapping, reader).run(ctxT); err != nil {
+		return err
+	}
+
+	logger.Debug("End processing routine file", "file", file.FilePath, "uuid", file.Uuid)
+	return nil
+}
 
-		record, err := reader.Read()
+// skipCommittedRows reads the rows read before the last committed batch of a resumed FilePart, rejected rows included.
+func skipCommittedRows(reader RecordReader, file *FilePart) error {
+	for file.checkpoint != nil && file.read < file.checkpoint.ReadRows {
+		_, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
-		if err != nil {
+		var re *RecordError
+		if err != nil && !errors.As(err, &re) {
 			return NewFileError(file.FilePath, fmt.Errorf("failed to read row: %w", err))
 		}
-		logger.Trace("RAW line", "line", fmt.Sprintf("%#v", record))
-
-		//return &FileError{FilePath: file.FilePath, Err: fmt.Errorf("simulate error file")}
-
-		// Batch insert contacts
-		br := c.handleBatchInsert(ctxT, file, batch, headers, record, false)
-		if br != nil {
-			return db.NewDbError(br)
-		}
-
-		file.TotalRows++
-		file.ProcessTime = time.Since(start)
-	}
-
-	// Batch insert contacts
-	br := c.handleBatchInsert(ctxT, file, batch, headers, []string{}, true)
-	if br != nil {
-		return db.NewDbError(fmt.Errorf("error while forcing insert batch contacts: %w", br))
+		file.read++
 	}
-
-	logger.Debug("End processing routine file", "file", file.FilePath, "uuid", file.Uuid)
 	return nil
 }
@@ -0,0 +1,115 @@
+package phonebook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-csv-import/internal/handlers/worker"
+	"go-csv-import/internal/model"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyCheckpoints(t *testing.T) {
+	store := worker.NewMessageProgressStore()
+	store.Init("uuid", 1000)
+	c := &ContactUploader{ProgressStore: store}
+
+	files := []FilePart{{Offset: 0}, {Offset: 512}, {Offset: 1024}}
+	checkpoints := []model.Checkpoint{
+		{Uuid: "uuid", Offset: 0, ReadRows: 100, Inserted: 80, Updated: 10, Skipped: 5, Done: true},
+		{Uuid: "uuid", Offset: 1024, ReadRows: 40, Inserted: 40},
+		{Uuid: "uuid", Offset: 2048, ReadRows: 10, Inserted: 10}, // No longer a FilePart of the file
+	}
+	c.applyCheckpoints(&FileMessage{Uuid: "uuid"}, files, checkpoints)
+
+	assert.Equal(t, 100, files[0].checkpoint.ReadRows)
+	assert.True(t, files[0].checkpoint.Done)
+	assert.Equal(t, &model.Checkpoint{Uuid: "uuid", Offset: 512}, files[1].checkpoint)
+	assert.Equal(t, 40, files[2].checkpoint.ReadRows)
+	assert.False(t, files[2].checkpoint.Done)
+
+	resp, _, ok := store.Response("uuid")
+	assert.True(t, ok)
+	assert.EqualValues(t, 120, resp.Inserted)
+	assert.EqualValues(t, 10, resp.Updated)
+	assert.EqualValues(t, 5, resp.Skipped)
+	assert.EqualValues(t, 5, resp.Rejected)
+}
+
+func TestSkipCommittedRows(t *testing.T) {
+	records := [][]string{{"1"}, {"2"}, {"3"}, {"4"}}
+
+	reader := &sliceReader{records: records}
+	file := &FilePart{checkpoint: &model.Checkpoint{ReadRows: 3}}
+	assert.NoError(t, skipCommittedRows(reader, file))
+	assert.Equal(t, 3, file.read)
+	record, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"4"}, record)
+
+	// Without checkpoint, no row is skipped
+	reader = &sliceReader{records: records}
+	file = &FilePart{}
+	assert.NoError(t, skipCommittedRows(reader, file))
+	assert.Equal(t, 0, file.read)
+	assert.Equal(t, 0, reader.Line())
+}
+
+func TestSkipCommittedRows_ShorterFile(t *testing.T) {
+	reader := &sliceReader{records: [][]string{{"1"}, {"2"}}}
+	file := &FilePart{checkpoint: &model.Checkpoint{ReadRows: 5}}
+	assert.NoError(t, skipCommittedRows(reader, file))
+	assert.Equal(t, 2, file.read)
+}
+
+func TestSkipCommittedRows_Errors(t *testing.T) {
+	// Malformed rows were rejected when read, and are skipped as well
+	reader := &sliceReader{records: [][]string{{"1"}}, err: &RecordError{Line: 2, Err: errors.New("bare quote")}}
+	file := &FilePart{checkpoint: &model.Checkpoint{ReadRows: 3}}
+	assert.NoError(t, skipCommittedRows(reader, file))
+	assert.Equal(t, 3, file.read)
+
+	failure := errors.New("disk failure")
+	reader = &sliceReader{records: [][]string{{"1"}}, err: failure}
+	file = &FilePart{FilePath: "contacts.csv", checkpoint: &model.Checkpoint{ReadRows: 3}}
+	err := skipCommittedRows(reader, file)
+	var fileErr *FileError
+	assert.ErrorAs(t, err, &fileErr)
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, 1, file.read)
+}
+
+func TestIsTransient(t *testing.T) {
+	lock := &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+
+	assert.True(t, IsTransient(context.DeadlineExceeded))
+	assert.True(t, IsTransient(mysql.ErrInvalidConn))
+	assert.True(t, IsTransient(fmt.Errorf("batch: %w", lock)))
+	assert.False(t, IsTransient(duplicate))
+	assert.False(t, IsTransient(errors.New("invalid file")))
+	assert.False(t, IsTransient(nil))
+
+	assert.True(t, IsTransient(multierror.Append(nil, lock, context.DeadlineExceeded)))
+	assert.False(t, IsTransient(multierror.Append(nil, lock, duplicate)))
+	assert.False(t, IsTransient(&multierror.Error{}))
+}
+
+func TestFileMessage_Retryable(t *testing.T) {
+	lock := &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+
+	file := &FileMessage{}
+	assert.True(t, file.Retryable(lock))
+	assert.False(t, file.Retryable(nil))
+	assert.False(t, file.Retryable(errors.New("invalid file")))
+
+	file.Attempts = maxImportAttempts - 1
+	assert.False(t, file.Retryable(lock))
+
+	file = &FileMessage{loaded: true}
+	assert.False(t, file.Retryable(lock))
+}
@@ -0,0 +1,476 @@
+package phonebook
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go-csv-import/internal/model"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Phone rules of vCard imports, for vCards holding several TEL properties.
+const (
+	VCardPhonesAll     = "all"     // A contact for each phone of the vCard
+	VCardPhonesPrimary = "primary" // A contact for the preferred phone of the vCard only
+)
+
+// vCardHeader is the header of the records read from vCards, resolved by the built-in aliases.
+var vCardHeader = []string{
+	string(FieldPhone), string(FieldFirstname), string(FieldLastname),
+	string(FieldEmail), string(FieldCompany), string(FieldTitle),
+}
+
+// vCardLineLength is the maximum length of a vCard line in octets, longer lines are folded.
+const vCardLineLength = 75
+
+// ParseVCardPhones validates the phone rule requested with a vCard upload, a contact for each phone by default.
+func ParseVCardPhones(rule string) (string, error) {
+	switch r := strings.ToLower(strings.TrimSpace(rule)); r {
+	case "":
+		return VCardPhonesAll, nil
+	case VCardPhonesAll, VCardPhonesPrimary:
+		return r, nil
+	}
+	return "", fmt.Errorf("invalid vcard_phones <%s>: expected %s or %s", rule, VCardPhonesAll, VCardPhonesPrimary)
+}
+
+// vCardProperty is a content line of a vCard: "item1.TEL;TYPE=cell,pref:+33701020300".
+type vCardProperty struct {
+	Name   string              // Upper-cased name, without its group
+	Params map[string][]string // Upper-cased parameter names to their lower-cased values
+	Value  string              // Raw value, still escaped
+}
+
+// param tells whether the property has the parameter value, as "TYPE=pref".
+func (p vCardProperty) param(name string, value string) bool {
+	for _, v := range p.Params[name] {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// pref returns the preference of the property, from 1 for the most preferred to 101 for properties without preference:
+// the PREF parameter of version 4.0, or the "pref" TYPE of version 3.0.
+func (p vCardProperty) pref() int {
+	if values, ok := p.Params["PREF"]; ok {
+		if n, err := strconv.Atoi(values[0]); err == nil {
+			return n
+		}
+	}
+	if p.param("TYPE", "pref") {
+		return 1
+	}
+	return 101
+}
+
+/*
+parseVCardProperty parses an unfolded content line.
+
+Parameters without name, like "TEL;CELL:...", are TYPE values. Parameter values may be quoted,
+and hold several values separated by commas.
+*/
+func parseVCardProperty(line string) (vCardProperty, error) {
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return vCardProperty{}, fmt.Errorf("invalid vCard line <%s>: expected a name and a value", line)
+	}
+
+	parts := splitUnquoted(line[:colon], ';')
+	name := parts[0]
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+
+	prop := vCardProperty{Name: strings.ToUpper(name), Params: map[string][]string{}, Value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			key, value = "TYPE", param
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		for _, v := range splitUnquoted(value, ',') {
+			prop.Params[key] = append(prop.Params[key], strings.ToLower(strings.Trim(v, `"`)))
+		}
+	}
+	return prop, nil
+}
+
+// splitUnquoted splits s around sep, except within double quotes.
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitVCardValue splits a structured value around sep, escaped separators excluded. Components are still escaped.
+func splitVCardValue(value string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// unescapeVCardValue unescapes a text value.
+func unescapeVCardValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			if value[i] == 'n' || value[i] == 'N' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// vCardComponent returns the component of a structured value, its list values joined by spaces.
+func vCardComponent(components []string, i int) string {
+	if i >= len(components) {
+		return ""
+	}
+
+	values := splitVCardValue(components[i], ',')
+	for j := range values {
+		values[j] = unescapeVCardValue(values[j])
+	}
+	return strings.TrimSpace(strings.Join(values, " "))
+}
+
+/*
+vCardRecords returns the records of a vCard, version 3.0 or 4.0, ordered like vCardHeader.
+
+Names come from the N property, or from FN as firstname when N is empty. The email is the preferred EMAIL,
+the company the organization name of ORG, and the title TITLE.
+Phones are the TEL properties: each gives a record, unless the primary rule keeps the preferred one,
+with the lowest PREF of version 4.0 or the "pref" TYPE of version 3.0, or else the first one.
+*/
+func vCardRecords(props []vCardProperty, phones string) ([][]string, error) {
+	var firstname, lastname, formatted, company, title string
+	type tel struct {
+		value string
+		pref  int
+	}
+	var tels []tel
+	email := tel{pref: 102}
+	for _, prop := range props {
+		switch prop.Name {
+		case "VERSION":
+			if v := strings.TrimSpace(prop.Value); v != "3.0" && v != "4.0" {
+				return nil, fmt.Errorf("unsupported vCard version <%s>: expected 3.0 or 4.0", v)
+			}
+		case "N":
+			components := splitVCardValue(prop.Value, ';')
+			lastname = vCardComponent(components, 0)
+			firstname = vCardComponent(components, 1)
+		case "FN":
+			formatted = strings.TrimSpace(unescapeVCardValue(prop.Value))
+		case "ORG":
+			company = vCardComponent(splitVCardValue(prop.Value, ';'), 0)
+		case "TITLE":
+			title = strings.TrimSpace(unescapeVCardValue(prop.Value))
+		case "EMAIL":
+			value := strings.TrimSpace(unescapeVCardValue(prop.Value))
+			if pref := prop.pref(); value != "" && pref < email.pref {
+				email = tel{value: value, pref: pref}
+			}
+		case "TEL":
+			value := strings.TrimSpace(unescapeVCardValue(prop.Value))
+			value = strings.TrimPrefix(strings.TrimPrefix(value, "tel:"), "TEL:")
+			if value != "" {
+				tels = append(tels, tel{value: value, pref: prop.pref()})
+			}
+		}
+	}
+
+	if firstname == "" && lastname == "" {
+		firstname = formatted
+	}
+	if len(tels) == 0 {
+		return nil, errors.New("vCard has no TEL")
+	}
+
+	if phones == VCardPhonesPrimary {
+		primary := tels[0]
+		for _, t := range tels[1:] {
+			if t.pref < primary.pref {
+				primary = t
+			}
+		}
+		tels = []tel{primary}
+	}
+
+	var records [][]string
+	seen := make(map[string]bool)
+	for _, t := range tels {
+		if seen[t.value] {
+			continue
+		}
+		seen[t.value] = true
+		records = append(records, []string{t.value, firstname, lastname, email.value, company, title})
+	}
+	return records, nil
+}
+
+/*
+vCardReader reads the contacts of a multi-contact vCard file.
+
+A vCard gives a record for each of its phones, following the phone rule of the upload.
+Records are located by the line of their BEGIN:VCARD, and an invalid vCard is a single record error.
+*/
+type vCardReader struct {
+	reader  *bufio.Reader
+	phones  string
+	line    int      // Physical lines read
+	next    *string  // Physical line read ahead, to unfold content lines
+	start   int      // Line of the current vCard
+	raw     []string // Physical lines of the current vCard
+	pending [][]string
+}
+
+func newVCardReader(r io.Reader, phones string) *vCardReader {
+	return &vCardReader{reader: bufio.NewReader(r), phones: phones}
+}
+
+func (r *vCardReader) Read() ([]string, error) {
+	for len(r.pending) == 0 {
+		props, err := r.readCard()
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := vCardRecords(props, r.phones)
+		if err != nil {
+			return nil, &RecordError{Line: r.start, Raw: r.Raw(nil), Err: err}
+		}
+		r.pending = records
+	}
+
+	record := r.pending[0]
+	r.pending = r.pending[1:]
+	return record, nil
+}
+
+func (r *vCardReader) Line() int {
+	return r.start
+}
+
+func (r *vCardReader) Raw([]string) string {
+	return strings.Join(r.raw, "\n")
+}
+
+// readCard reads the properties of the next vCard, between BEGIN:VCARD and END:VCARD.
+func (r *vCardReader) readCard() ([]vCardProperty, error) {
+	r.raw = r.raw[:0]
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(strings.TrimSpace(line), "BEGIN:VCARD") {
+			break
+		}
+		if strings.TrimSpace(line) != "" {
+			r.start = r.line
+			return nil, &RecordError{Line: r.line, Raw: line, Err: errors.New("expected BEGIN:VCARD")}
+		}
+		r.raw = r.raw[:0]
+	}
+	r.start = r.line
+
+	var props []vCardProperty
+	for {
+		line, err := r.readLine()
+		if errors.Is(err, io.EOF) {
+			return nil, &RecordError{Line: r.start, Raw: r.Raw(nil), Err: errors.New("vCard has no END:VCARD")}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(line), "END:VCARD") {
+			return props, nil
+		}
+
+		prop, err := parseVCardProperty(line)
+		if err != nil {
+			return nil, r.skipCard(err)
+		}
+		props = append(props, prop)
+	}
+}
+
+// skipCard reads the rest of an invalid vCard, so that the next one can still be read.
+func (r *vCardReader) skipCard(cause error) error {
+	for {
+		line, err := r.readLine()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if err != nil || strings.EqualFold(strings.TrimSpace(line), "END:VCARD") {
+			return &RecordError{Line: r.start, Raw: r.Raw(nil), Err: cause}
+		}
+	}
+}
+
+// readLine reads a content line, unfolding the following lines which start with a space or a tab.
+func (r *vCardReader) readLine() (string, error) {
+	line, err := r.readPhysical()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		next, err := r.peekPhysical()
+		if err != nil || next == "" || (next[0] != ' ' && next[0] != '\t') {
+			return line, nil
+		}
+		r.readPhysical()
+		line += next[1:]
+	}
+}
+
+func (r *vCardReader) readPhysical() (string, error) {
+	line, err := r.peekPhysical()
+	if err != nil {
+		return "", err
+	}
+	r.next = nil
+	r.line++
+	r.raw = append(r.raw, line)
+	return line, nil
+}
+
+func (r *vCardReader) peekPhysical() (string, error) {
+	if r.next == nil {
+		line, err := r.reader.ReadString('\n')
+		if line == "" && err != nil {
+			return "", err
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		r.next = &line
+	}
+	return *r.next, nil
+}
+
+// countVCards counts the records of a vCard file, an invalid vCard counting once.
+func countVCards(r io.Reader, phones string) (int, error) {
+	reader := newVCardReader(r, phones)
+	count := 0
+	for {
+		_, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		var re *RecordError
+		if err != nil && !errors.As(err, &re) {
+			return 0, err
+		}
+		count++
+	}
+}
+
+/*
+WriteVCard writes a contact as a vCard 3.0, read by phones and mail clients alike.
+
+The phone is written normalized when it has been, as written in the uploaded file otherwise.
+*/
+func WriteVCard(w io.Writer, contact *model.Contact) error {
+	phone := contact.PhoneE164
+	if phone == "" {
+		phone = contact.Phone
+	}
+
+	formatted := strings.TrimSpace(contact.Firstname + " " + contact.Lastname)
+	if formatted == "" {
+		formatted = phone
+	}
+
+	lines := []string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"N:" + escapeVCardValue(contact.Lastname) + ";" + escapeVCardValue(contact.Firstname) + ";;;",
+		"FN:" + escapeVCardValue(formatted),
+		"TEL;TYPE=CELL:" + escapeVCardValue(phone),
+	}
+	if contact.Email != "" {
+		lines = append(lines, "EMAIL;TYPE=INTERNET:"+escapeVCardValue(contact.Email))
+	}
+	if contact.Company != "" {
+		lines = append(lines, "ORG:"+escapeVCardValue(contact.Company))
+	}
+	if contact.Title != "" {
+		lines = append(lines, "TITLE:"+escapeVCardValue(contact.Title))
+	}
+	lines = append(lines, "END:VCARD")
+	for _, line := range lines {
+		if _, err := io.WriteString(w, foldVCardLine(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapeVCardValue escapes the backslashes, separators and newlines of a text value.
+func escapeVCardValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// foldVCardLine folds a content line longer than vCardLineLength octets, without splitting a character.
+func foldVCardLine(line string) string {
+	var b strings.Builder
+	length := 0
+	for _, c := range line {
+		size := utf8.RuneLen(c)
+		if length+size > vCardLineLength {
+			b.WriteString("\r\n ")
+			length = 1
+		}
+		b.WriteRune(c)
+		length += size
+	}
+	return b.String()
+}
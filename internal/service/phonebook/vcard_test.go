@@ -0,0 +1,164 @@
+package phonebook
+
+import (
+	"bytes"
+	"errors"
+	"go-csv-import/internal/model"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readVCards returns the records of vCards with their line, and the lines of invalid vCards.
+func readVCards(t *testing.T, content string, phones string) ([][]string, []int, []int) {
+	reader := newVCardReader(strings.NewReader(content), phones)
+	var rows [][]string
+	var lines, invalid []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var re *RecordError
+		if errors.As(err, &re) {
+			invalid = append(invalid, re.Line)
+			continue
+		}
+		assert.NoError(t, err)
+		rows = append(rows, record)
+		lines = append(lines, reader.Line())
+	}
+	return rows, lines, invalid
+}
+
+const vCards = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"N:Doe;John;;;\r\n" +
+	"FN:John Doe\r\n" +
+	"EMAIL;TYPE=INTERNET:john@home.example.com\r\n" +
+	"EMAIL;TYPE=INTERNET,PREF:john.doe@example.com\r\n" +
+	"ORG:Example\\, Inc.;Sales\r\n" +
+	"TITLE:Account manager\r\n" +
+	"TEL;TYPE=WORK,VOICE:+33 1 02 03 04 05\r\n" +
+	"item1.TEL;TYPE=CELL,PREF:+33 7 01 02 03 00\r\n" +
+	"END:VCARD\r\n" +
+	"\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:4.0\r\n" +
+	"FN:Jane\r\n" +
+	"  Smith\r\n" +
+	"TEL;VALUE=uri;PREF=2;TYPE=\"voice,home\":tel:+33-1-02-03-04-06\r\n" +
+	"TEL;VALUE=uri;PREF=1;TYPE=cell:tel:+33-7-01-02-03-01\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:2.1\r\n" +
+	"N:Old;Card\r\n" +
+	"TEL;CELL:0701020302\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	`N:O\;Brien;Paul\, Jr;;;` + "\r\n" +
+	"TEL:0701020303\r\n" +
+	"TEL:0701020303\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"FN:No Phone\r\n" +
+	"END:VCARD\r\n"
+
+func TestVCardReader_All(t *testing.T) {
+	rows, lines, invalid := readVCards(t, vCards, VCardPhonesAll)
+	assert.Equal(t, [][]string{
+		{"+33 1 02 03 04 05", "John", "Doe", "john.doe@example.com", "Example, Inc.", "Account manager"},
+		{"+33 7 01 02 03 00", "John", "Doe", "john.doe@example.com", "Example, Inc.", "Account manager"},
+		{"+33-1-02-03-04-06", "Jane Smith", "", "", "", ""},
+		{"+33-7-01-02-03-01", "Jane Smith", "", "", "", ""},
+		{"0701020303", "Paul, Jr", "O;Brien", "", "", ""},
+	}, rows)
+	assert.Equal(t, []int{1, 1, 13, 13, 25}, lines)
+	assert.Equal(t, []int{20, 31}, invalid)
+}
+
+func TestVCardReader_Primary(t *testing.T) {
+	rows, _, _ := readVCards(t, vCards, VCardPhonesPrimary)
+	assert.Equal(t, [][]string{
+		{"+33 7 01 02 03 00", "John", "Doe", "john.doe@example.com", "Example, Inc.", "Account manager"},
+		{"+33-7-01-02-03-01", "Jane Smith", "", "", "", ""},
+		{"0701020303", "Paul, Jr", "O;Brien", "", "", ""},
+	}, rows)
+}
+
+func TestVCardReader_Unterminated(t *testing.T) {
+	_, _, invalid := readVCards(t, "BEGIN:VCARD\nVERSION:3.0\nTEL:0701020300\n", VCardPhonesAll)
+	assert.Equal(t, []int{1}, invalid)
+
+	rows, _, invalid := readVCards(t, "TEL:0701020300\nBEGIN:VCARD\nTEL:0701020301\nEND:VCARD\n", VCardPhonesAll)
+	assert.Equal(t, [][]string{{"0701020301", "", "", "", "", ""}}, rows)
+	assert.Equal(t, []int{1}, invalid)
+}
+
+func TestVCard_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.vcf")
+	assert.NoError(t, os.WriteFile(path, []byte(vCards), 0o644))
+
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxRows: 2, Format: FormatOf(path), VCardPhones: VCardPhonesAll}
+	assert.Equal(t, FormatVCF, file.Format)
+
+	header, err := file.ReadHeader()
+	assert.NoError(t, err)
+	assert.Nil(t, file.Dialect)
+
+	mapping, err := ResolveMapping(header, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, mapping.Columns[FieldPhone])
+
+	count, err := file.CountRows()
+	assert.NoError(t, err)
+	assert.Equal(t, 7, count)
+
+	parts, total, err := (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Len(t, parts, 1)
+	assert.Equal(t, 7, total)
+}
+
+func TestWriteVCard(t *testing.T) {
+	contacts := []*model.Contact{
+		{Phone: "07 01 02 03 00", PhoneE164: "+33701020300", Firstname: "Jérôme", Lastname: "O;Brien", Email: "jerome@example.com", Company: "ACME"},
+		{Phone: "0701", Firstname: strings.Repeat("Long", 20)},
+	}
+
+	var b bytes.Buffer
+	for _, contact := range contacts {
+		assert.NoError(t, WriteVCard(&b, contact))
+	}
+	assert.Contains(t, b.String(), "BEGIN:VCARD\r\nVERSION:3.0\r\nN:O\\;Brien;Jérôme;;;\r\nFN:Jérôme O\\;Brien\r\nTEL;TYPE=CELL:+33701020300\r\nEMAIL;TYPE=INTERNET:jerome@example.com\r\nORG:ACME\r\nEND:VCARD\r\n")
+	for _, line := range strings.Split(b.String(), "\r\n") {
+		assert.LessOrEqual(t, len(line), vCardLineLength)
+	}
+
+	// Exported vCards are imported back
+	rows, _, invalid := readVCards(t, b.String(), VCardPhonesAll)
+	assert.Empty(t, invalid)
+	assert.Equal(t, [][]string{
+		{"+33701020300", "Jérôme", "O;Brien", "jerome@example.com", "ACME", ""},
+		{"0701", strings.Repeat("Long", 20), "", "", "", ""},
+	}, rows)
+}
+
+func TestParseVCardPhones(t *testing.T) {
+	rule, err := ParseVCardPhones("")
+	assert.NoError(t, err)
+	assert.Equal(t, VCardPhonesAll, rule)
+
+	rule, err = ParseVCardPhones(" Primary ")
+	assert.NoError(t, err)
+	assert.Equal(t, VCardPhonesPrimary, rule)
+
+	_, err = ParseVCardPhones("first")
+	assert.Error(t, err)
+}
@@ -0,0 +1,245 @@
+package phonebook
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// sheetNameMaxLength is the maximum length of a sheet name in Excel.
+const sheetNameMaxLength = 31
+
+// ParseSheet validates the name of the spreadsheet sheet to import, the first sheet when empty.
+func ParseSheet(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > sheetNameMaxLength {
+		return "", fmt.Errorf("invalid sheet: name must not exceed %d characters", sheetNameMaxLength)
+	}
+	return name, nil
+}
+
+/*
+openWorkbook opens the uploaded spreadsheet, within the compression ratio and the uncompressed size of the upload.
+
+Worksheets are not loaded in memory: their rows are streamed from the archive, or from a temporary
+file for the largest ones, while they are read.
+*/
+func (j *FileMessage) openWorkbook() (*excelize.File, error) {
+	opts := excelize.Options{}
+	if j.MaxRatio > 0 {
+		i, err := os.Stat(j.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		opts.UnzipSizeLimit = i.Size() * int64(j.MaxRatio)
+	}
+	if j.MaxSize > 0 && (opts.UnzipSizeLimit == 0 || j.MaxSize < opts.UnzipSizeLimit) {
+		opts.UnzipSizeLimit = j.MaxSize
+	}
+
+	wb, err := excelize.OpenFile(j.FilePath, opts)
+	if err != nil {
+		// excelize has no sentinel error for the unzip size limit
+		if strings.HasPrefix(err.Error(), "unzip size exceeds") {
+			return nil, fmt.Errorf("%w: spreadsheet expands more than %d times its compressed size", ErrCompressionRatio, j.MaxRatio)
+		}
+		return nil, fmt.Errorf("invalid spreadsheet: %w", err)
+	}
+	return wb, nil
+}
+
+// sheetName returns the sheet to import: the requested one, or the first sheet of the workbook.
+func (j *FileMessage) sheetName(wb *excelize.File) (string, error) {
+	sheets := wb.GetSheetList()
+	if len(sheets) == 0 {
+		return "", errors.New("spreadsheet has no sheet")
+	}
+	if j.Sheet == "" {
+		return sheets[0], nil
+	}
+
+	for _, sheet := range sheets {
+		if sheet == j.Sheet {
+			return sheet, nil
+		}
+	}
+	return "", fmt.Errorf("sheet <%s> not found: expected one of <%s>", j.Sheet, strings.Join(sheets, ">, <"))
+}
+
+/*
+sheetReader streams the rows of a sheet. Empty rows are ignored and the first row is the header.
+
+Cells are read with their raw value, so that numbers are not formatted by the cell style:
+a phone typed as a number stays "701020300" rather than "7.01E+08".
+*/
+type sheetReader struct {
+	workbook *excelize.File
+	rows     *excelize.Rows
+	header   []string
+	line     int
+}
+
+// openSheet opens the sheet to import, positioned before its first row.
+func (j *FileMessage) openSheet(header []string) (*sheetReader, error) {
+	wb, err := j.openWorkbook()
+	if err != nil {
+		return nil, err
+	}
+
+	sheet, err := j.sheetName(wb)
+	if err != nil {
+		wb.Close()
+		return nil, err
+	}
+
+	rows, err := wb.Rows(sheet)
+	if err != nil {
+		wb.Close()
+		return nil, err
+	}
+	return &sheetReader{workbook: wb, rows: rows, header: header}, nil
+}
+
+// openSheetReader opens the sheet to import, positioned after its header row.
+func (j *FileMessage) openSheetReader(header []string) (*sheetReader, error) {
+	r, err := j.openSheet(header)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.next(); err != nil && !errors.Is(err, errNoRow) {
+		r.Close()
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	return r, nil
+}
+
+// errNoRow is returned when the sheet has no more rows.
+var errNoRow = errors.New("sheet has no more rows")
+
+// next returns the cells of the next row which is not empty.
+func (r *sheetReader) next() ([]string, error) {
+	for r.rows.Next() {
+		r.line++
+		cells, err := r.rows.Columns(excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range cells {
+			cells[i] = cellValue(cells[i])
+		}
+		if strings.Join(cells, "") != "" {
+			return cells, nil
+		}
+	}
+
+	if err := r.rows.Error(); err != nil {
+		return nil, err
+	}
+	return nil, errNoRow
+}
+
+func (r *sheetReader) Read() ([]string, error) {
+	cells, err := r.next()
+	if errors.Is(err, errNoRow) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Trailing empty cells are not stored
+	for len(cells) > len(r.header) && cells[len(cells)-1] == "" {
+		cells = cells[:len(cells)-1]
+	}
+	for len(cells) < len(r.header) {
+		cells = append(cells, "")
+	}
+	return cells, nil
+}
+
+func (r *sheetReader) Line() int {
+	return r.line
+}
+
+func (r *sheetReader) Raw(record []string) string {
+	return formatRecord(record, DefaultDialect())
+}
+
+func (r *sheetReader) Close() error {
+	err := r.rows.Close()
+	if cerr := r.workbook.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readSheetHeader reads the first row of the sheet which is not empty.
+func (j *FileMessage) readSheetHeader() ([]string, error) {
+	r, err := j.openSheet(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	header, err := r.next()
+	if errors.Is(err, errNoRow) {
+		return nil, errors.New("failed to read header: sheet is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	// Trailing empty cells are not stored, but blank headers between columns are kept
+	for len(header) > 0 && header[len(header)-1] == "" {
+		header = header[:len(header)-1]
+	}
+	return header, nil
+}
+
+// countSheetRows counts the rows of the sheet which are not empty, the header excluded.
+func (j *FileMessage) countSheetRows() (int, error) {
+	r, err := j.openSheetReader(nil)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	count := 0
+	for {
+		_, err := r.next()
+		if errors.Is(err, errNoRow) {
+			return count, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+}
+
+/*
+cellValue returns the text of a raw cell value.
+
+Numbers are stored in their shortest floating point form, so that large integers such as phone numbers
+may be stored with an exponent: they are written back as plain integers, "7.0102030E8" giving "701020300".
+*/
+func cellValue(raw string) string {
+	if !strings.ContainsAny(raw, "eE") {
+		return raw
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v != math.Trunc(v) || math.IsInf(v, 0) {
+		return raw
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
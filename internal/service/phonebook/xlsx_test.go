@@ -0,0 +1,143 @@
+package phonebook
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+// writeWorkbook writes a workbook with a sheet of rows for each sheet name.
+func writeWorkbook(t *testing.T, sheets map[string][][]any, order ...string) string {
+	wb := excelize.NewFile()
+	defer wb.Close()
+
+	for i, name := range order {
+		if i == 0 {
+			assert.NoError(t, wb.SetSheetName("Sheet1", name))
+		} else {
+			_, err := wb.NewSheet(name)
+			assert.NoError(t, err)
+		}
+		for r, row := range sheets[name] {
+			cell, err := excelize.CoordinatesToCellName(1, r+1)
+			assert.NoError(t, err)
+			assert.NoError(t, wb.SetSheetRow(name, cell, &row))
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "contacts.xlsx")
+	assert.NoError(t, wb.SaveAs(path))
+	return path
+}
+
+// readSheet returns the records of the sheet with their line.
+func readSheet(t *testing.T, file *FileMessage, header []string) ([][]string, []int) {
+	parts, _, err := (&ContactUploader{}).splitFile(file)
+	assert.NoError(t, err)
+	assert.Len(t, parts, 1)
+
+	reader, closer, err := file.openRecordReader(&parts[0], header)
+	assert.NoError(t, err)
+	defer closer.Close()
+
+	var rows [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		rows = append(rows, record)
+		lines = append(lines, reader.Line())
+	}
+	return rows, lines
+}
+
+func TestXLSX_FirstSheet(t *testing.T) {
+	path := writeWorkbook(t, map[string][][]any{
+		"Contacts": {
+			{"Phone", "Firstname", "Lastname"},
+			{701020300, "John", "Doe"},
+			{},
+			{33701020301, "Jane"},
+			{"+33 7 01 02 03 02", "Paul", "Smith", "extra"},
+		},
+		"Other": {{"Name"}, {"ignored"}},
+	}, "Contacts", "Other")
+
+	file := &FileMessage{Uuid: "uuid", FilePath: path, MaxRows: 2, MaxRatio: 100, Format: FormatOf(path)}
+	assert.Equal(t, FormatXLSX, file.Format)
+
+	dialect, err := file.ResolveDialect()
+	assert.NoError(t, err)
+	assert.Equal(t, Dialect{}, dialect)
+	assert.Empty(t, file.Encoding)
+	assert.Nil(t, file.Dialect)
+
+	header, err := file.ReadHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Phone", "Firstname", "Lastname"}, header)
+
+	count, err := file.CountRows()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	rows, lines := readSheet(t, file, header)
+	assert.Equal(t, [][]string{
+		{"701020300", "John", "Doe"},
+		{"33701020301", "Jane", ""},
+		{"+33 7 01 02 03 02", "Paul", "Smith", "extra"},
+	}, rows)
+	assert.Equal(t, []int{2, 4, 5}, lines)
+}
+
+func TestXLSX_Sheet(t *testing.T) {
+	path := writeWorkbook(t, map[string][][]any{
+		"Summary":  {{"Total"}, {2}},
+		"Contacts": {{"Phone"}, {701020300}, {701020301}},
+	}, "Summary", "Contacts")
+
+	file := &FileMessage{FilePath: path, Format: FormatXLSX, Sheet: "Contacts"}
+	header, err := file.ReadHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Phone"}, header)
+
+	rows, _ := readSheet(t, file, header)
+	assert.Equal(t, [][]string{{"701020300"}, {"701020301"}}, rows)
+
+	file.Sheet = "Missing"
+	_, err = file.ReadHeader()
+	assert.ErrorContains(t, err, "sheet <Missing> not found: expected one of <Summary>, <Contacts>")
+}
+
+func TestXLSX_CompressionRatio(t *testing.T) {
+	path := writeWorkbook(t, map[string][][]any{
+		"Contacts": {{"Phone"}, {701020300}},
+	}, "Contacts")
+
+	file := &FileMessage{FilePath: path, Format: FormatXLSX, MaxRatio: 1}
+	_, err := file.ReadHeader()
+	assert.ErrorIs(t, err, ErrCompressionRatio)
+}
+
+func TestCellValue(t *testing.T) {
+	assert.Equal(t, "701020300", cellValue("7.010203E8"))
+	assert.Equal(t, "33701020301", cellValue("3.3701020301E10"))
+	assert.Equal(t, "1.5E-3", cellValue("1.5E-3"))
+	assert.Equal(t, "0701020300", cellValue("0701020300"))
+	assert.Equal(t, "Jérôme", cellValue("Jérôme"))
+	assert.Equal(t, "Eve", cellValue("Eve"))
+}
+
+func TestParseSheet(t *testing.T) {
+	sheet, err := ParseSheet("  Contacts ")
+	assert.NoError(t, err)
+	assert.Equal(t, "Contacts", sheet)
+
+	_, err = ParseSheet("A sheet name longer than allowed")
+	assert.Error(t, err)
+}
@@ -2,10 +2,14 @@ package utils
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
+	"io"
 	"os"
 )
 
-// FileCountRows counts the number of rows in a file.
+// FileCountRows counts the number of lines in a file, whatever their length.
+// A last line without trailing newline is counted.
 func FileCountRows(filePath string) (int, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
@@ -13,22 +17,29 @@ func FileCountRows(filePath string) (int, error) {
 	}
 	defer f.Close()
 
-	scanner := bufio.NewScanner(f)
+	reader := bufio.NewReader(f)
 	count := 0
-	for scanner.Scan() {
-		count++
-	}
-	return count, scanner.Err()
-}
+	pending := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			pending = true
+		}
+		if bytes.HasSuffix(chunk, []byte("\n")) {
+			count++
+			pending = false
+		}
 
-// FileCountRows counts the number of rows in a file, excluding the header row for CSV files.
-func FileCountRowsCsv(filePath string) (int, error) {
-	count, err := FileCountRows(filePath)
-	if err != nil {
-		return 0, err
-	}
-	if count > 0 {
-		count-- // Exclude header row
+		switch {
+		case err == nil, errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF):
+			if pending {
+				count++
+			}
+			return count, nil
+		default:
+			return count, err
+		}
 	}
-	return count, nil
 }
@@ -1,21 +1,133 @@
 package validation
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"go-csv-import/internal/handlers/worker"
 	"net/http"
 	"path/filepath"
 	"strings"
+	"unicode/utf16"
 )
 
-// Checks if the file has a ".csv" extension
+// ContentSampleSize is the number of first bytes of a file needed to check its content.
+const ContentSampleSize = 512
+
+// Signatures of binary files which cannot be CSV files.
+var binarySignatures = [][]byte{
+	[]byte("\x1F\x8B"),         // gzip
+	[]byte("PK\x03\x04"),       // zip, xlsx, docx
+	[]byte("%PDF"),             // pdf
+	[]byte("\x89PNG"),          // png
+	[]byte("\xFF\xD8\xFF"),     // jpeg
+	[]byte("\xD0\xCF\x11\xE0"), // xls, doc
+	[]byte("\x7FELF"),          // executable
+}
+
+// Checks if the file has a ".csv", ".json", ".jsonl", ".ndjson", ".xlsx", ".vcf" or fixed-width ".txt", ".dat", ".fwf" extension, or is a compressed ".csv.gz" or ".zip" file
 func IsValidCSV(fileName string) error {
-	ext := strings.ToLower(filepath.Ext(fileName))
-	if ext == ".csv" {
+	name := strings.ToLower(fileName)
+	ext := filepath.Ext(name)
+	switch {
+	case ext == ".csv", ext == ".zip", ext == ".json", ext == ".jsonl", ext == ".ndjson", ext == ".xlsx", ext == ".vcf", ext == ".txt", ext == ".dat", ext == ".fwf", strings.HasSuffix(name, ".csv.gz"):
 		return nil
-	} else {
-		return fmt.Errorf("invalid file type: %s. expected a .csv file", ext)
+	default:
+		return fmt.Errorf("invalid file type: %s. expected a .csv, .csv.gz, .zip, .json, .jsonl, .ndjson, .xlsx, .vcf, .txt, .dat or .fwf file", ext)
+	}
+}
+
+// Checks if the first bytes of the file match its extension, so that a renamed file is refused before being processed
+func IsValidContent(fileName string, head []byte) error {
+	if len(head) == 0 {
+		return errors.New("invalid file content: file is empty")
+	}
+
+	name := strings.ToLower(fileName)
+	text := bytes.TrimLeft(decodeText(head), " \t\r\n")
+	switch ext := filepath.Ext(name); {
+	case ext == ".gz":
+		if !bytes.HasPrefix(head, binarySignatures[0]) {
+			return errors.New("invalid file content: expected a gzip file")
+		}
+	case ext == ".zip":
+		if !bytes.HasPrefix(head, []byte("PK\x03\x04")) && !bytes.HasPrefix(head, []byte("PK\x05\x06")) {
+			return errors.New("invalid file content: expected a zip archive")
+		}
+	case ext == ".xlsx":
+		if !bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+			return errors.New("invalid file content: expected an Excel workbook")
+		}
+	case ext == ".json":
+		if !bytes.HasPrefix(text, []byte("[")) {
+			return errors.New("invalid file content: expected a JSON array of objects")
+		}
+	case ext == ".jsonl" || ext == ".ndjson":
+		if !bytes.HasPrefix(text, []byte("{")) {
+			return errors.New("invalid file content: expected a JSON object per line")
+		}
+	case ext == ".vcf":
+		if !bytes.HasPrefix(bytes.ToUpper(text), []byte("BEGIN:VCARD")) {
+			return errors.New("invalid file content: expected vCards")
+		}
+	default:
+		if isBinary(head) {
+			return errors.New("invalid file content: expected a text file")
+		}
+	}
+	return nil
+}
+
+// decodeText returns the first bytes of a text file in UTF-8, without the byte order mark of their encoding.
+// Text without byte order mark is returned as is, as only its first characters are checked.
+func decodeText(head []byte) []byte {
+	var order func(b []byte) uint16
+	switch {
+	case bytes.HasPrefix(head, []byte("\xEF\xBB\xBF")):
+		return head[3:]
+	case bytes.HasPrefix(head, []byte("\xFF\xFE")):
+		order = func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 }
+	case bytes.HasPrefix(head, []byte("\xFE\xFF")):
+		order = func(b []byte) uint16 { return uint16(b[0])<<8 | uint16(b[1]) }
+	default:
+		return head
+	}
+
+	// A trailing odd byte belongs to a character cut by the sample
+	units := make([]uint16, 0, len(head)/2)
+	for i := 2; i+1 < len(head); i += 2 {
+		units = append(units, order(head[i:i+2]))
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// isBinary tells whether the first bytes of a file are not text, whatever its character encoding.
+func isBinary(head []byte) bool {
+	for _, signature := range binarySignatures {
+		if bytes.HasPrefix(head, signature) {
+			return true
+		}
+	}
+
+	if bytes.IndexByte(head, 0) < 0 {
+		return false
+	}
+
+	// UTF-16 text has zero bytes, either at even or at odd positions
+	if bytes.HasPrefix(head, []byte("\xFF\xFE")) || bytes.HasPrefix(head, []byte("\xFE\xFF")) {
+		return false
+	}
+	var even, odd bool
+	for i, b := range head {
+		if b == 0 {
+			if i%2 == 0 {
+				even = true
+			} else {
+				odd = true
+			}
+		}
 	}
+	return even && odd
 }
 
 // Checks if file progress status indicated that contacts can be deleted.
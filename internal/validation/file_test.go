@@ -0,0 +1,41 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidCSV(t *testing.T) {
+	for _, name := range []string{"contacts.csv", "contacts.CSV.GZ", "export.zip", "contacts.json", "contacts.jsonl", "contacts.ndjson", "contacts.xlsx", "contacts.vcf", "contacts.txt", "contacts.DAT"} {
+		assert.NoError(t, IsValidCSV(name), name)
+	}
+	for _, name := range []string{"contacts.doc", "contacts.json.gz", "contacts.xls", "contacts"} {
+		assert.Error(t, IsValidCSV(name), name)
+	}
+}
+
+func TestIsValidContent(t *testing.T) {
+	assert.NoError(t, IsValidContent("contacts.csv", []byte("Phone;Firstname\n0701020300;Jérôme\n")))
+	assert.NoError(t, IsValidContent("contacts.csv", []byte("\xFF\xFEP\x00h\x00")))
+	assert.NoError(t, IsValidContent("contacts.json", []byte("\xEF\xBB\xBF  [{\"phone\": \"0701020300\"}]")))
+	assert.NoError(t, IsValidContent("contacts.jsonl", []byte("{\"phone\": \"0701020300\"}\n")))
+	assert.NoError(t, IsValidContent("contacts.csv.gz", []byte("\x1F\x8B\x08\x00")))
+	assert.NoError(t, IsValidContent("export.zip", []byte("PK\x03\x04")))
+	assert.NoError(t, IsValidContent("contacts.xlsx", []byte("PK\x03\x04")))
+	assert.NoError(t, IsValidContent("contacts.vcf", []byte("\xEF\xBB\xBF\r\nbegin:vcard\r\nVERSION:3.0\r\n")))
+	assert.NoError(t, IsValidContent("contacts.json", []byte("\xFF\xFE[\x00{\x00\"\x00p\x00")))
+	assert.NoError(t, IsValidContent("contacts.ndjson", []byte("\xFE\xFF\x00\n\x00{\x00\"\x00p")))
+
+	// Renamed files are refused
+	assert.Error(t, IsValidContent("contacts.csv", []byte("PK\x03\x04\x14\x00")))
+	assert.Error(t, IsValidContent("contacts.csv", []byte("%PDF-1.7")))
+	assert.Error(t, IsValidContent("contacts.json", []byte("{\"phone\": \"0701020300\"}")))
+	assert.Error(t, IsValidContent("contacts.jsonl", []byte("Phone;Firstname\n")))
+	assert.Error(t, IsValidContent("contacts.json", []byte("\xFF\xFEP\x00h\x00")))
+	assert.Error(t, IsValidContent("contacts.csv.gz", []byte("Phone;Firstname\n")))
+	assert.Error(t, IsValidContent("contacts.xlsx", []byte("\xD0\xCF\x11\xE0")))
+	assert.Error(t, IsValidContent("contacts.vcf", []byte("Phone;Firstname\n")))
+	assert.Error(t, IsValidContent("contacts.txt", []byte("%PDF-1.7")))
+	assert.Error(t, IsValidContent("contacts.csv", nil))
+}